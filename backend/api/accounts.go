@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"mymain/backend/api/accounts"
+)
+
+// accountStore keeps the accounts and which sessions are logged in. main
+// replaces it with one kept in the data directory.
+var accountStore accounts.Store = accounts.NewMemoryStore()
+
+// loginDuration is how long a login lasts.
+const loginDuration = 30 * 24 * time.Hour
+
+// dummyPasswordHash is checked against when a login names an unknown
+// account, so both failures take the same time.
+var dummyPasswordHash, _ = accounts.HashPassword("not a password")
+
+// accountForm is the data of the login and registration forms.
+type accountForm struct {
+	Username string
+	Error    string
+	CSRF     string
+	Return   string
+}
+
+// localPath returns target when it is a path of this site, fallback
+// otherwise, so forms can not send visitors elsewhere.
+func localPath(target string, fallback string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return fallback
+	}
+	return target
+}
+
+// currentUser returns the name of the logged in user.
+func currentUser(r *http.Request) (string, bool) {
+	id, ok := sessions.Id(r)
+	if !ok {
+		return "", false
+	}
+	login, err := accountStore.LoginOf(id, now())
+	if err != nil {
+		if !errors.Is(err, accounts.ErrNotFound) {
+			log.Printf("reading login: %v", err)
+		}
+		return "", false
+	}
+	return login.Username, true
+}
+
+// csrfToken returns the CSRF token forms of the visitor must carry, empty
+// when the visitor has no session yet.
+func csrfToken(r *http.Request) string {
+	id, ok := sessions.Id(r)
+	if !ok {
+		return ""
+	}
+	return sessions.CSRFToken(id)
+}
+
+// checkCSRF tells whether a posted form carries the CSRF token of its
+// session. A form posted without a session, such as the first star, has no
+// token to carry and must come from a page of this site instead, so another
+// site cannot start a session for the visitor.
+func checkCSRF(r *http.Request) bool {
+	id, ok := sessions.Id(r)
+	if !ok {
+		return sameOrigin(r)
+	}
+	return sessions.CheckCSRF(id, r.PostFormValue("csrf_token"))
+}
+
+// sameOrigin tells whether the browser says the request was sent by a page
+// of this site, from Sec-Fetch-Site or else from Origin. Requests saying
+// neither are refused.
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin"
+	}
+	origin, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && origin.Host != "" && origin.Host == r.Host
+}
+
+// logIn starts a new session logged in to the account. Favorites starred
+// before logging in are added to the account.
+func logIn(w http.ResponseWriter, r *http.Request, username string) error {
+	previous, hadSession := sessions.Id(r)
+	id := sessions.Start(w)
+	if err := accountStore.StartLogin(id, accounts.Login{Username: username, Started: now(), Expires: now().Add(loginDuration)}); err != nil {
+		return err
+	}
+	if !hadSession {
+		return nil
+	}
+	if err := accountStore.EndLogin(previous); err != nil {
+		log.Printf("ending previous login: %v", err)
+	}
+	ids, err := favoriteStore.List("session:" + previous)
+	if err != nil {
+		return err
+	}
+	for _, artistId := range ids {
+		if err := favoriteStore.Add("user:"+username, artistId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderAccountForm renders the login or registration form with status.
+func renderAccountForm(w http.ResponseWriter, r *http.Request, page string, status int, form accountForm) {
+	tmpl, err := parsePage(
+		publicUrl+page,
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	tmpl.Execute(w, form)
+}
+
+// startAccountForm serves the login or registration form. The form starts
+// a session so its CSRF token can be checked when it is posted.
+func startAccountForm(w http.ResponseWriter, r *http.Request, page string) {
+	if _, loggedIn := currentUser(r); loggedIn {
+		http.Redirect(w, r, "/account", http.StatusSeeOther)
+		return
+	}
+	id := sessions.Ensure(w, r)
+	renderAccountForm(w, r, page, http.StatusOK, accountForm{
+		CSRF:   sessions.CSRFToken(id),
+		Return: localPath(r.URL.Query().Get("return"), "/account"),
+	})
+}
+
+// postedAccountForm reads the posted login or registration form. ok is
+// false when the form was not sent from a session of this site.
+func postedAccountForm(r *http.Request) (accountForm, string, bool) {
+	id, hasSession := sessions.Id(r)
+	if !hasSession || !sessions.CheckCSRF(id, r.PostFormValue("csrf_token")) {
+		return accountForm{}, "", false
+	}
+	form := accountForm{
+		Username: accounts.NormalizeUsername(r.PostFormValue("username")),
+		CSRF:     sessions.CSRFToken(id),
+		Return:   localPath(r.PostFormValue("return"), "/account"),
+	}
+	return form, r.PostFormValue("password"), true
+}
+
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		startAccountForm(w, r, "register.html")
+		return
+	case http.MethodPost:
+	default:
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	form, password, ok := postedAccountForm(r)
+	if !ok {
+		handleErrorPage(w, r, ForbiddenError)
+		return
+	}
+	if err := accounts.ValidateUsername(form.Username); err != nil {
+		form.Error = err.Error()
+		renderAccountForm(w, r, "register.html", http.StatusBadRequest, form)
+		return
+	}
+	if err := accounts.ValidatePassword(password); err != nil {
+		form.Error = err.Error()
+		renderAccountForm(w, r, "register.html", http.StatusBadRequest, form)
+		return
+	}
+	if password != r.PostFormValue("password_confirm") {
+		form.Error = "the passwords do not match"
+		renderAccountForm(w, r, "register.html", http.StatusBadRequest, form)
+		return
+	}
+
+	hash, err := accounts.HashPassword(password)
+	if err != nil {
+		log.Printf("hashing password: %v", err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+	err = accountStore.Create(accounts.Account{Username: form.Username, PasswordHash: hash, Created: now()})
+	if errors.Is(err, accounts.ErrExists) {
+		form.Error = "this username is taken"
+		renderAccountForm(w, r, "register.html", http.StatusConflict, form)
+		return
+	}
+	if err == nil {
+		err = logIn(w, r, form.Username)
+	}
+	if err != nil {
+		log.Printf("registering %s: %v", form.Username, err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, form.Return, http.StatusSeeOther)
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		startAccountForm(w, r, "login.html")
+		return
+	case http.MethodPost:
+	default:
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	form, password, ok := postedAccountForm(r)
+	if !ok {
+		handleErrorPage(w, r, ForbiddenError)
+		return
+	}
+	account, err := accountStore.Get(form.Username)
+	if err != nil && !errors.Is(err, accounts.ErrNotFound) {
+		log.Printf("reading account %s: %v", form.Username, err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+	hash := account.PasswordHash
+	if err != nil {
+		hash = dummyPasswordHash
+	}
+	if !accounts.CheckPassword(hash, password) || err != nil {
+		form.Error = "wrong username or password"
+		renderAccountForm(w, r, "login.html", http.StatusUnauthorized, form)
+		return
+	}
+
+	if err := logIn(w, r, account.Username); err != nil {
+		log.Printf("logging in %s: %v", account.Username, err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+	http.Redirect(w, r, form.Return, http.StatusSeeOther)
+}
+
+// handleLogout ends the login and the session; anonymous favorites of the
+// session are gone with it, those of the account stay.
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+	if !checkCSRF(r) {
+		handleErrorPage(w, r, ForbiddenError)
+		return
+	}
+	if id, ok := sessions.Id(r); ok {
+		if err := accountStore.EndLogin(id); err != nil {
+			log.Printf("logging out: %v", err)
+			handleErrorPage(w, r, InternalServerError)
+			return
+		}
+	}
+	sessions.Clear(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func handleAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	username, loggedIn := currentUser(r)
+	if !loggedIn {
+		http.Redirect(w, r, "/login?return=/account", http.StatusSeeOther)
+		return
+	}
+	account, err := accountStore.Get(username)
+	if err != nil {
+		log.Printf("reading account %s: %v", username, err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"account.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	templateData := struct {
+		Username  string
+		Created   string
+		Favorites int
+		CSRF      string
+	}{
+		Username:  account.Username,
+		Created:   account.Created.Format(dateLayout),
+		Favorites: len(favoriteIds(r)),
+		CSRF:      csrfToken(r),
+	}
+
+	tmpl.Execute(w, templateData)
+}
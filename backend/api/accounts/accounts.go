@@ -0,0 +1,295 @@
+// Package accounts keeps user accounts and the sessions they are logged in
+// with.
+//
+// FileStore keeps both in one JSON file so no database is needed;
+// MemoryStore is meant for tests. Passwords are only ever stored hashed,
+// see HashPassword.
+package accounts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits of usernames and passwords.
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+	MinPasswordLength = 8
+	MaxPasswordLength = 256
+)
+
+var (
+	// ErrExists is returned when creating an account whose name is taken.
+	ErrExists = errors.New("account already exists")
+	// ErrNotFound is returned for unknown accounts and sessions.
+	ErrNotFound = errors.New("not found")
+)
+
+// Account is a registered user.
+type Account struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"passwordHash"`
+	Created      time.Time `json:"created"`
+}
+
+// Login is a session an account is logged in with.
+type Login struct {
+	Username string    `json:"username"`
+	Started  time.Time `json:"started"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Store keeps accounts and their logins.
+type Store interface {
+	// Create adds the account, ErrExists when the name is taken.
+	Create(account Account) error
+	// Get returns the account, ErrNotFound when there is none.
+	Get(username string) (Account, error)
+	// StartLogin records that the session is logged in to the account.
+	StartLogin(sessionId string, login Login) error
+	// LoginOf returns the login of the session, ErrNotFound when the
+	// session is not logged in or the login expired at now.
+	LoginOf(sessionId string, now time.Time) (Login, error)
+	// EndLogin logs the session out.
+	EndLogin(sessionId string) error
+}
+
+// NormalizeUsername lowercases and trims a username so names are unique
+// regardless of case.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ValidateUsername checks a normalized username: letters, digits, ".", "_"
+// and "-" only, within the length limits.
+func ValidateUsername(username string) error {
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return fmt.Errorf("username must be %d to %d characters long", MinUsernameLength, MaxUsernameLength)
+	}
+	for _, r := range username {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return fmt.Errorf("username may only contain letters, digits, dots, dashes and underscores")
+		}
+	}
+	return nil
+}
+
+// ValidatePassword checks the password length.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("password must be %d to %d characters long", MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+// data is everything a store holds.
+type data struct {
+	Accounts map[string]Account `json:"accounts"`
+	Logins   map[string]Login   `json:"logins"`
+}
+
+func newData() data {
+	return data{Accounts: map[string]Account{}, Logins: map[string]Login{}}
+}
+
+func (d data) create(account Account) error {
+	if _, found := d.Accounts[account.Username]; found {
+		return ErrExists
+	}
+	d.Accounts[account.Username] = account
+	return nil
+}
+
+func (d data) get(username string) (Account, error) {
+	account, found := d.Accounts[username]
+	if !found {
+		return Account{}, ErrNotFound
+	}
+	return account, nil
+}
+
+func (d data) startLogin(sessionId string, login Login) {
+	// Drop logins expired by the time this one started while we are at it
+	for id, other := range d.Logins {
+		if !other.Expires.After(login.Started) {
+			delete(d.Logins, id)
+		}
+	}
+	d.Logins[sessionId] = login
+}
+
+func (d data) loginOf(sessionId string, now time.Time) (Login, error) {
+	login, found := d.Logins[sessionId]
+	if !found || !login.Expires.After(now) {
+		return Login{}, ErrNotFound
+	}
+	return login, nil
+}
+
+// MemoryStore is a Store that lives in memory only.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data data
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newData()}
+}
+
+func (s *MemoryStore) Create(account Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.create(account)
+}
+
+func (s *MemoryStore) Get(username string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.get(username)
+}
+
+func (s *MemoryStore) StartLogin(sessionId string, login Login) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.startLogin(sessionId, login)
+	return nil
+}
+
+func (s *MemoryStore) LoginOf(sessionId string, now time.Time) (Login, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.loginOf(sessionId, now)
+}
+
+func (s *MemoryStore) EndLogin(sessionId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data.Logins, sessionId)
+	return nil
+}
+
+// FileStore is a Store persisted as JSON. The file is read once and
+// rewritten atomically on every change.
+type FileStore struct {
+	path string
+
+	mu     sync.Mutex
+	loaded bool
+	data   data
+}
+
+// NewFileStore returns a store kept in the file at path. The file is
+// created on the first change.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Create(account Account) error {
+	return s.update(func(d data) error { return d.create(account) })
+}
+
+func (s *FileStore) Get(username string) (Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return Account{}, err
+	}
+	return s.data.get(username)
+}
+
+func (s *FileStore) StartLogin(sessionId string, login Login) error {
+	return s.update(func(d data) error {
+		d.startLogin(sessionId, login)
+		return nil
+	})
+}
+
+func (s *FileStore) LoginOf(sessionId string, now time.Time) (Login, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return Login{}, err
+	}
+	return s.data.loginOf(sessionId, now)
+}
+
+func (s *FileStore) EndLogin(sessionId string) error {
+	return s.update(func(d data) error {
+		delete(d.Logins, sessionId)
+		return nil
+	})
+}
+
+// update applies change to a copy of the data and keeps it once it is
+// saved, so a failed write leaves memory and file in agreement.
+func (s *FileStore) update(change func(data) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	next := newData()
+	for name, account := range s.data.Accounts {
+		next.Accounts[name] = account
+	}
+	for id, login := range s.data.Logins {
+		next.Logins[id] = login
+	}
+	if err := change(next); err != nil {
+		return err
+	}
+	if err := s.save(next); err != nil {
+		return err
+	}
+	s.data = next
+	return nil
+}
+
+func (s *FileStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	loaded := newData()
+	content, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &loaded); err != nil {
+			return err
+		}
+		if loaded.Accounts == nil {
+			loaded.Accounts = map[string]Account{}
+		}
+		if loaded.Logins == nil {
+			loaded.Logins = map[string]Login{}
+		}
+	}
+	s.data = loaded
+	s.loaded = true
+	return nil
+}
+
+func (s *FileStore) save(d data) error {
+	content, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,152 @@
+package accounts
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	HashIterations = 1000
+}
+
+func TestPbkdf2(t *testing.T) {
+	// Test vectors of RFC 7914, section 11
+	testCases := []struct {
+		password   string
+		salt       string
+		iterations int
+		expected   string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, tc := range testCases {
+		got := hex.EncodeToString(pbkdf2([]byte(tc.password), []byte(tc.salt), tc.iterations, 64))
+		if got != tc.expected {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s want %s", tc.password, tc.salt, tc.iterations, got, tc.expected)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil || !strings.HasPrefix(hash, "pbkdf2-sha256$1000$") {
+		t.Fatalf("HashPassword returned %q, %v", hash, err)
+	}
+	again, _ := HashPassword("correct horse")
+	if again == hash {
+		t.Error("two hashes of one password share their salt")
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("CheckPassword rejected the password")
+	}
+
+	// Hashes keep working when the cost changes
+	HashIterations = 2000
+	defer func() { HashIterations = 1000 }()
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("CheckPassword rejected the password after raising the cost")
+	}
+
+	for _, tc := range []struct{ hash, password string }{
+		{hash, "correct horse "},
+		{hash, ""},
+		{"", "correct horse"},
+		{"bcrypt$10$abc$def", "correct horse"},
+		{strings.Replace(hash, "$1000$", "$0$", 1), "correct horse"},
+	} {
+		if CheckPassword(tc.hash, tc.password) {
+			t.Errorf("CheckPassword(%q, %q) matched", tc.hash, tc.password)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		username string
+		valid    bool
+	}{
+		{"queen_fan", true},
+		{"a.b-c", true},
+		{"ab", false},
+		{strings.Repeat("a", 33), false},
+		{"with space", false},
+		{"motörhead", false},
+	}
+	for _, tc := range testCases {
+		if err := ValidateUsername(tc.username); (err == nil) != tc.valid {
+			t.Errorf("ValidateUsername(%q) = %v", tc.username, err)
+		}
+	}
+	if NormalizeUsername("  Queen_Fan ") != "queen_fan" {
+		t.Error("NormalizeUsername does not lowercase and trim")
+	}
+	if ValidatePassword("short") == nil || ValidatePassword("long enough") != nil {
+		t.Error("ValidatePassword does not check the length")
+	}
+}
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	now := time.Now()
+	account := Account{Username: "queen_fan", PasswordHash: "hash", Created: now.Truncate(time.Second)}
+	if err := store.Create(account); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(account); !errors.Is(err, ErrExists) {
+		t.Errorf("second Create returned %v", err)
+	}
+	if got, err := store.Get("queen_fan"); err != nil || got.PasswordHash != "hash" {
+		t.Errorf("Get returned %+v, %v", got, err)
+	}
+	if _, err := store.Get("nobody"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unknown account returned %v", err)
+	}
+
+	if err := store.StartLogin("s1", Login{Username: "queen_fan", Expires: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if login, err := store.LoginOf("s1", now); err != nil || login.Username != "queen_fan" {
+		t.Errorf("LoginOf returned %+v, %v", login, err)
+	}
+	if _, err := store.LoginOf("s1", now.Add(2*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoginOf an expired login returned %v", err)
+	}
+	// Starting a login drops those expired by then, not others
+	if err := store.StartLogin("s2", Login{Username: "queen_fan", Started: now.Add(-48 * time.Hour), Expires: now.Add(-47 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StartLogin("s3", Login{Username: "queen_fan", Started: now.Add(-time.Hour), Expires: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoginOf("s1", now); err != nil {
+		t.Errorf("a later login dropped one that had not expired: %v", err)
+	}
+	if _, err := store.LoginOf("s2", now.Add(-48*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("a later login kept one that had expired: %v", err)
+	}
+	if err := store.EndLogin("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoginOf("s1", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoginOf after EndLogin returned %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	testStore(t, NewFileStore(path))
+
+	// A new store reads what the first one wrote
+	if _, err := NewFileStore(path).Get("queen_fan"); err != nil {
+		t.Errorf("reopened store lost the account: %v", err)
+	}
+}
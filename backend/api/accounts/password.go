@@ -0,0 +1,97 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Passwords are hashed with PBKDF2-HMAC-SHA256 (RFC 8018) and a random
+// salt. Like bcrypt, the hash records its algorithm, cost and salt, e.g.
+// "pbkdf2-sha256$600000$<salt>$<key>", so the cost can be raised later
+// without breaking stored hashes.
+const (
+	hashAlgorithm = "pbkdf2-sha256"
+	saltLength    = 16
+	keyLength     = 32
+)
+
+// HashIterations is the cost of new hashes. Tests lower it to stay fast.
+var HashIterations = 600000
+
+// HashPassword returns the encoded hash of the password.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, HashIterations, keyLength)
+	return strings.Join([]string{
+		hashAlgorithm,
+		strconv.Itoa(HashIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// CheckPassword tells whether the password matches the encoded hash.
+// Malformed hashes never match.
+func CheckPassword(hash string, password string) bool {
+	iterations, salt, key, err := decodeHash(hash)
+	if err != nil {
+		return false
+	}
+	candidate := pbkdf2([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(candidate, key) == 1
+}
+
+func decodeHash(hash string) (int, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashAlgorithm {
+		return 0, nil, nil, fmt.Errorf("unknown password hash format")
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return 0, nil, nil, fmt.Errorf("invalid iteration count %q", parts[1])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, fmt.Errorf("invalid key")
+	}
+	return iterations, salt, key, nil
+}
+
+// pbkdf2 derives a key of the given length from the password.
+func pbkdf2(password, salt []byte, iterations, length int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	block := make([]byte, 4)
+	for i := uint32(1); len(key) < length; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block, i)
+		prf.Write(block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:length]
+}
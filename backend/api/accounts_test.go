@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/accounts"
+)
+
+// useAccounts gives the test its own accounts on top of useFavorites, with
+// cheap password hashes.
+func useAccounts(t *testing.T) {
+	t.Helper()
+	useFavorites(t)
+	savedStore, savedIterations := accountStore, accounts.HashIterations
+	accountStore = accounts.NewMemoryStore()
+	accounts.HashIterations = 1000
+	t.Cleanup(func() {
+		accountStore, accounts.HashIterations = savedStore, savedIterations
+	})
+}
+
+// openForm fetches a login or registration form and returns the cookie of
+// the session it starts.
+func openForm(t *testing.T, handler http.HandlerFunc, target string) []*http.Cookie {
+	t.Helper()
+	rr := serve(handler, "GET", target)
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusOK || len(cookies) != 1 || !strings.Contains(rr.Body.String(), `name="csrf_token" value="`) {
+		t.Fatalf("GET %s returned %v with %d cookies", target, rr.Code, len(cookies))
+	}
+	return cookies
+}
+
+// registerUser registers an account and returns the cookie of its
+// logged in session.
+func registerUser(t *testing.T, username string) []*http.Cookie {
+	t.Helper()
+	cookies := openForm(t, handleRegister, "/register")
+	form := url.Values{"username": {username}, "password": {"bohemian rhapsody"}, "password_confirm": {"bohemian rhapsody"}}
+	rr := postForm(handleRegister, "/register", cookies, form)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("registering %s returned %v", username, rr.Code)
+	}
+	return rr.Result().Cookies()
+}
+
+func TestRegisterLoginLogout(t *testing.T) {
+	newFakeUpstream(t)
+	useAccounts(t)
+
+	// A star before registering is kept by the account
+	cookies := openForm(t, handleRegister, "/register?return=/favorites")
+	if rr := postFavorite(cookies, url.Values{"artist_id": {"3"}, "action": {"add"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("starring returned %v", rr.Code)
+	}
+
+	form := url.Values{"username": {" Queen_Fan "}, "password": {"bohemian rhapsody"}, "password_confirm": {"bohemian rhapsody"}, "return": {"/favorites"}}
+	rr := postForm(handleRegister, "/register", cookies, form)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/favorites" {
+		t.Fatalf("registering returned %v to %q: %s", rr.Code, rr.Header().Get("Location"), rr.Body.String())
+	}
+	// Registering starts a new session
+	loggedIn := rr.Result().Cookies()
+	if len(loggedIn) != 1 || loggedIn[0].Value == cookies[0].Value {
+		t.Fatal("registering kept the session id")
+	}
+	if account, err := accountStore.Get("queen_fan"); err != nil || !accounts.CheckPassword(account.PasswordHash, "bohemian rhapsody") || strings.Contains(account.PasswordHash, "bohemian") {
+		t.Fatalf("stored account is %+v, %v", account, err)
+	}
+
+	body := serveWithCookies(handleAccount, "/account", loggedIn).Body.String()
+	if !strings.Contains(body, "queen_fan") || !strings.Contains(body, "★ 1 favorites") {
+		t.Error("account page does not show the account and its favorite")
+	}
+	if rr := serveWithCookies(handleLogin, "/login", loggedIn); rr.Code != http.StatusSeeOther {
+		t.Errorf("login form for a logged in user returned %v", rr.Code)
+	}
+
+	// Logging out needs the CSRF token
+	if rr := postForm(handleLogout, "/logout", loggedIn, url.Values{"csrf_token": {"forged"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("logout with a forged token returned %v", rr.Code)
+	}
+	rr = postForm(handleLogout, "/logout", loggedIn, nil)
+	if rr.Code != http.StatusSeeOther || len(rr.Result().Cookies()) != 1 || rr.Result().Cookies()[0].MaxAge >= 0 {
+		t.Fatalf("logout returned %v without clearing the cookie", rr.Code)
+	}
+	// The old cookie no longer logs in
+	if rr := serveWithCookies(handleAccount, "/account", loggedIn); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login?return=/account" {
+		t.Errorf("account page after logout returned %v", rr.Code)
+	}
+
+	// Logging in again finds the favorites of the account
+	cookies = openForm(t, handleLogin, "/login")
+	rr = postForm(handleLogin, "/login", cookies, url.Values{"username": {"QUEEN_FAN"}, "password": {"bohemian rhapsody"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/account" {
+		t.Fatalf("login returned %v to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if body := serveWithCookies(handleFavorites, "/favorites", rr.Result().Cookies()).Body.String(); !strings.Contains(body, `id="favorite_3"`) {
+		t.Error("favorites of the account are missing after logging in")
+	}
+}
+
+func TestAccountFormErrors(t *testing.T) {
+	newFakeUpstream(t)
+	useAccounts(t)
+	hash, _ := accounts.HashPassword("bohemian rhapsody")
+	accountStore.Create(accounts.Account{Username: "queen_fan", PasswordHash: hash})
+
+	cookies := openForm(t, handleRegister, "/register")
+	testCases := []struct {
+		handler      http.HandlerFunc
+		target       string
+		cookies      []*http.Cookie
+		form         url.Values
+		expectedCode int
+	}{
+		{handleRegister, "/register", cookies, url.Values{"username": {"ab"}, "password": {"long enough"}, "password_confirm": {"long enough"}}, http.StatusBadRequest},
+		{handleRegister, "/register", cookies, url.Values{"username": {"new_fan"}, "password": {"short"}, "password_confirm": {"short"}}, http.StatusBadRequest},
+		{handleRegister, "/register", cookies, url.Values{"username": {"new_fan"}, "password": {"long enough"}, "password_confirm": {"long enougH"}}, http.StatusBadRequest},
+		{handleRegister, "/register", cookies, url.Values{"username": {"Queen_Fan"}, "password": {"long enough"}, "password_confirm": {"long enough"}}, http.StatusConflict},
+		{handleRegister, "/register", nil, url.Values{"username": {"new_fan"}, "password": {"long enough"}, "password_confirm": {"long enough"}}, http.StatusForbidden},
+		{handleRegister, "/register", cookies, url.Values{"username": {"new_fan"}, "password": {"long enough"}, "password_confirm": {"long enough"}, "csrf_token": {"forged"}}, http.StatusForbidden},
+		{handleLogin, "/login", cookies, url.Values{"username": {"queen_fan"}, "password": {"wrong password"}}, http.StatusUnauthorized},
+		{handleLogin, "/login", cookies, url.Values{"username": {"nobody"}, "password": {"bohemian rhapsody"}}, http.StatusUnauthorized},
+		{handleLogin, "/login", nil, url.Values{"username": {"queen_fan"}, "password": {"bohemian rhapsody"}}, http.StatusForbidden},
+		{handleLogin, "/login", cookies, url.Values{"username": {"queen_fan"}, "password": {"bohemian rhapsody"}, "return": {"//example.com"}}, http.StatusSeeOther},
+	}
+	for _, tc := range testCases {
+		rr := postForm(tc.handler, tc.target, tc.cookies, tc.form)
+		if rr.Code != tc.expectedCode {
+			t.Errorf("POST %s %v returned %v want %v", tc.target, tc.form, rr.Code, tc.expectedCode)
+		}
+		if rr.Code == http.StatusSeeOther && rr.Header().Get("Location") != "/account" {
+			t.Errorf("POST %s redirected to %q", tc.target, rr.Header().Get("Location"))
+		}
+		if tc.expectedCode == http.StatusBadRequest || tc.expectedCode == http.StatusConflict || tc.expectedCode == http.StatusUnauthorized {
+			if !strings.Contains(rr.Body.String(), `id="form_error"`) {
+				t.Errorf("POST %s %v does not show the error", tc.target, tc.form)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		method  string
+		target  string
+	}{
+		{handleRegister, "PUT", "/register"},
+		{handleLogin, "DELETE", "/login"},
+		{handleLogout, "GET", "/logout"},
+		{handleAccount, "POST", "/account"},
+	} {
+		if rr := serve(tc.handler, tc.method, tc.target); rr.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s returned %v", tc.method, tc.target, rr.Code)
+		}
+	}
+}
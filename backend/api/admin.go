@@ -0,0 +1,404 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mymain/backend/api/datastore"
+	"mymain/backend/api/imagecache"
+	"mymain/backend/api/overrides"
+)
+
+// adminAuth holds the credentials of /admin: a token sent as
+// "Authorization: Bearer <token>" by scripts, or a user and password for
+// basic auth in the browser. The admin area is disabled while neither the
+// token nor the password is set.
+var adminAuth struct {
+	Token    string
+	User     string
+	Password string
+}
+
+// maintenanceMode turns every page but /admin, the health checks and the
+// static files into a maintenance notice. It is not kept across restarts.
+var maintenanceMode atomic.Bool
+
+// maxUpstreamErrors is how many failed upstream requests the admin page
+// lists.
+const maxUpstreamErrors = 20
+
+// upstreamError is a failed request to the upstream API.
+type upstreamError struct {
+	Time  time.Time
+	Url   string
+	Error string
+}
+
+// TimeText is the time of the failure as shown on the admin page.
+func (e upstreamError) TimeText() string {
+	return adminTime(e.Time)
+}
+
+var upstreamErrors struct {
+	sync.Mutex
+	list []upstreamError
+}
+
+// recordUpstreamError keeps a failed upstream request for the admin page,
+// dropping the oldest beyond maxUpstreamErrors.
+func recordUpstreamError(url string, err error) {
+	upstreamErrors.Lock()
+	defer upstreamErrors.Unlock()
+	upstreamErrors.list = append(upstreamErrors.list, upstreamError{Time: time.Now(), Url: url, Error: err.Error()})
+	if extra := len(upstreamErrors.list) - maxUpstreamErrors; extra > 0 {
+		upstreamErrors.list = upstreamErrors.list[extra:]
+	}
+}
+
+// recentUpstreamErrors returns the kept upstream failures, newest first.
+func recentUpstreamErrors() []upstreamError {
+	upstreamErrors.Lock()
+	defer upstreamErrors.Unlock()
+	list := make([]upstreamError, 0, len(upstreamErrors.list))
+	for i := len(upstreamErrors.list) - 1; i >= 0; i-- {
+		list = append(list, upstreamErrors.list[i])
+	}
+	return list
+}
+
+// adminMessages are shown after an action, keyed by the done parameter of
+// the redirect.
+var adminMessages = map[string]string{
+	"refresh":          "The upstream data was synced and reloaded.",
+	"refresh_failed":   "The sync failed, see the last result below.",
+	"purge":            "The image cache was emptied.",
+	"purge_failed":     "The image cache could not be emptied, see the server log.",
+	"maintenance_on":   "Maintenance mode is on, visitors see a maintenance notice.",
+	"maintenance_off":  "Maintenance mode is off.",
+	"overrides":        "The overrides were read again and applied.",
+	"overrides_failed": "The overrides were not reloaded, the ones in use stay.",
+	"tags":             "The tags were saved.",
+	"tags_failed":      "The tags could not be saved, see the server log.",
+}
+
+// adminUrl is an upstream resource and the url it was discovered at.
+type adminUrl struct {
+	Key string
+	Url string
+}
+
+// adminView is the data of the admin page.
+type adminView struct {
+	CSRF        string
+	Message     string
+	Maintenance bool
+
+	Urls      []adminUrl
+	Discovery DiscoveryStatus
+	// Times of the discovery, formatted
+	DiscoveryAttempt string
+	DiscoverySuccess string
+
+	Synced      bool
+	Sync        syncReport
+	SyncedAt    string
+	LastAttempt string
+	LastError   string
+	Loaded      bool
+	SnapshotAge string
+	Artists     int
+
+	CacheDir   string
+	Cache      imagecache.Stats
+	CacheError string
+	CacheSize  string
+	CacheAge   string
+
+	Workers    int
+	Busy       int64
+	Waiting    int64
+	Sent       int64
+	Failed     int64
+	Dropped    int64
+	Goroutines int
+
+	Errors []upstreamError
+
+	OverrideDir   string
+	OverrideFiles []string
+	OverrideError string
+	Overrides     []overrides.Record
+
+	// MetadataProblems lists metadata of artists the upstream does not have
+	MetadataProblems string
+
+	// TagArtists can be given tags, Tagged were given some
+	TagArtists []ArtistsData
+	Tagged     []adminTagged
+}
+
+// adminTagged is an artist with the tags given on /admin.
+type adminTagged struct {
+	Artist ArtistsData
+	Tags   string
+}
+
+// adminTime formats a time of the admin page.
+func adminTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.UTC().Format("02-01-2006 15:04:05 MST")
+}
+
+// adminAge formats how long ago t was.
+func adminAge(t time.Time) string {
+	return time.Since(t).Round(time.Second).String()
+}
+
+// byteSize formats a size in bytes.
+func byteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, prefix := float64(size)/unit, 0
+	for value >= unit && prefix < 3 {
+		value /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[prefix])
+}
+
+func sameSecret(given string, expected string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// authorizeAdmin checks the credentials of a request to /admin. byToken
+// tells whether the bearer token was sent: such requests come from scripts
+// and do not need a CSRF token.
+func authorizeAdmin(r *http.Request) (byToken bool, ok bool) {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		return true, sameSecret(token, adminAuth.Token)
+	}
+	user, password, found := r.BasicAuth()
+	if !found {
+		return false, false
+	}
+	// Both are compared so the time does not tell which one is wrong
+	userOk := subtle.ConstantTimeCompare([]byte(user), []byte(adminAuth.User)) == 1
+	passwordOk := sameSecret(password, adminAuth.Password)
+	return false, userOk && passwordOk
+}
+
+// adminExempt tells whether a path stays reachable in maintenance mode.
+func adminExempt(path string) bool {
+	switch path {
+	case "/admin", "/healthz", "/readyz":
+		return true
+	}
+	return strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/img/")
+}
+
+// withMaintenance answers every request with a maintenance notice while
+// maintenance mode is on, except for the paths adminExempt lets through.
+func withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenanceMode.Load() || adminExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", "600")
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			writeJson(w, http.StatusServiceUnavailable, map[string]string{"error": "down for maintenance"})
+			return
+		}
+		handleErrorPage(w, r, MaintenanceError)
+	})
+}
+
+// adminStatus collects what the admin page shows.
+func adminStatus() adminView {
+	var view adminView
+	view.Maintenance = maintenanceMode.Load()
+
+	for _, key := range []string{"base", "artists", "locations", "dates", "relations"} {
+		view.Urls = append(view.Urls, adminUrl{Key: key, Url: apiUrl(key)})
+	}
+	view.Discovery = currentDiscoveryStatus()
+	view.DiscoveryAttempt = adminTime(view.Discovery.LastAttempt)
+	view.DiscoverySuccess = adminTime(view.Discovery.LastSuccess)
+
+	err := dataStore.View(func(tx *datastore.Tx) error {
+		found, err := tx.GetJson(metaBucket, "sync", &view.Sync)
+		view.Synced = found
+		return err
+	})
+	if err != nil {
+		log.Printf("reading the sync report: %v", err)
+	}
+	view.SyncedAt = adminTime(view.Sync.SyncedAt)
+
+	snapshotState.RLock()
+	view.LastAttempt = adminTime(snapshotState.lastAttempt)
+	if snapshotState.lastError != nil {
+		view.LastError = snapshotState.lastError.Error()
+	}
+	if snapshot := snapshotState.data; snapshot != nil {
+		view.Loaded = true
+		view.SnapshotAge = adminAge(snapshot.LoadedAt)
+		view.Artists = len(snapshot.Artists)
+		view.Overrides = snapshot.Overrides
+		view.TagArtists = snapshot.Artists
+	}
+	snapshotState.RUnlock()
+
+	view.CacheDir = artistImages.Dir
+	view.Cache, err = artistImages.Stats()
+	if err != nil {
+		view.CacheError = err.Error()
+	}
+	view.CacheSize = byteSize(view.Cache.Bytes)
+	if view.Cache.Files > 0 {
+		view.CacheAge = adminAge(view.Cache.Oldest)
+	}
+
+	view.Workers = webhookWorkers
+	view.Busy = webhookPool.busy.Load()
+	view.Waiting = webhookPool.waiting.Load()
+	view.Sent = webhookPool.sent.Load()
+	view.Failed = webhookPool.failed.Load()
+	view.Dropped = webhookPool.dropped.Load()
+	view.Goroutines = runtime.NumGoroutine()
+
+	view.Errors = recentUpstreamErrors()
+	overrideState.RLock()
+	view.OverrideDir = overrideState.dir
+	view.OverrideError = overrideState.lastError
+	overrideState.RUnlock()
+	view.OverrideFiles = currentOverrides().Files
+	view.MetadataProblems = metadataProblems()
+
+	given := adminTags()
+	for _, artist := range view.TagArtists {
+		if names := given[artist.Id]; len(names) > 0 {
+			view.Tagged = append(view.Tagged, adminTagged{Artist: artist, Tags: strings.Join(names, ", ")})
+		}
+	}
+	return view
+}
+
+// runAdminAction runs a posted action and returns the key of the message
+// to show, or "" for an unknown action or a form it cannot act on.
+func runAdminAction(r *http.Request) string {
+	switch r.PostFormValue("action") {
+	case "refresh":
+		if err := refreshSnapshot(); err != nil {
+			log.Printf("admin refresh failed: %v", err)
+			return "refresh_failed"
+		}
+		return "refresh"
+	case "purge":
+		if err := artistImages.Purge(); err != nil {
+			log.Printf("purging the image cache: %v", err)
+			return "purge_failed"
+		}
+		return "purge"
+	case "maintenance":
+		on := r.PostFormValue("on") == "true"
+		maintenanceMode.Store(on)
+		log.Printf("maintenance mode turned %s", map[bool]string{true: "on", false: "off"}[on])
+		if on {
+			return "maintenance_on"
+		}
+		return "maintenance_off"
+	case "overrides":
+		if err := reloadOverrides(); err != nil {
+			log.Printf("reloading overrides: %v", err)
+			return "overrides_failed"
+		}
+		return "overrides"
+	case "tags":
+		snapshot, err := currentSnapshot()
+		if err != nil {
+			log.Printf("saving tags: %v", err)
+			return "tags_failed"
+		}
+		id, err := strconv.Atoi(r.PostFormValue("artist"))
+		if _, found := snapshot.findArtist(id); err != nil || !found {
+			return ""
+		}
+		if err := setAdminTags(id, parseTags(r.PostFormValue("tags"))); err != nil {
+			log.Printf("saving the tags of artist %d: %v", id, err)
+			return "tags_failed"
+		}
+		return "tags"
+	}
+	return ""
+}
+
+// handleAdmin shows the state of the instance to its operators and lets
+// them sync the upstream data, empty the image cache, read the overrides
+// again, tag artists and toggle maintenance mode. It is answered with 404
+// while no admin credentials are configured.
+func handleAdmin(w http.ResponseWriter, r *http.Request) {
+	if adminAuth.Token == "" && adminAuth.Password == "" {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+	byToken, ok := authorizeAdmin(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="groupie-tracker admin", charset="UTF-8"`)
+		handleErrorPage(w, r, UnauthorizedError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !byToken {
+			id, hasSession := sessions.Id(r)
+			if !hasSession || !sessions.CheckCSRF(id, r.PostFormValue("csrf_token")) {
+				handleErrorPage(w, r, ForbiddenError)
+				return
+			}
+		}
+		done := runAdminAction(r)
+		if done == "" {
+			handleErrorPage(w, r, BadRequestError)
+			return
+		}
+		http.Redirect(w, r, "/admin?done="+done, http.StatusSeeOther)
+		return
+	default:
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"admin.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	view := adminStatus()
+	view.CSRF = sessions.CSRFToken(sessions.Ensure(w, r))
+	view.Message = adminMessages[r.URL.Query().Get("done")]
+	tmpl.Execute(w, view)
+}
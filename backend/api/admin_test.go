@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useAdmin configures the admin credentials and keeps the image cache and
+// the upstream error log of the test apart.
+func useAdmin(t *testing.T) {
+	t.Helper()
+	savedAuth, savedDir := adminAuth, artistImages.Dir
+	adminAuth.Token, adminAuth.User, adminAuth.Password = "t0ken", "admin", "s3cret"
+	artistImages.Dir = t.TempDir()
+	upstreamErrors.Lock()
+	upstreamErrors.list = nil
+	upstreamErrors.Unlock()
+	t.Cleanup(func() {
+		adminAuth, artistImages.Dir = savedAuth, savedDir
+		maintenanceMode.Store(false)
+	})
+}
+
+// adminRequest sends a request to /admin as the basic auth user, with the
+// given session cookies. A form is posted with the CSRF token of the session.
+func adminRequest(method string, target string, cookies []*http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	if form != nil {
+		return postForm(func(w http.ResponseWriter, r *http.Request) {
+			r.SetBasicAuth("admin", "s3cret")
+			handleAdmin(w, r)
+		}, target, cookies, form)
+	}
+	req := httptest.NewRequest(method, target, nil)
+	req.SetBasicAuth("admin", "s3cret")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	handleAdmin(rr, req)
+	return rr
+}
+
+func TestAdminAuthorization(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t)
+	useAdmin(t)
+
+	request := func(setup func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		setup(req)
+		rr := httptest.NewRecorder()
+		handleAdmin(rr, req)
+		return rr
+	}
+	testCases := []struct {
+		name         string
+		setup        func(r *http.Request)
+		expectedCode int
+	}{
+		{"no credentials", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }, http.StatusUnauthorized},
+		{"wrong user", func(r *http.Request) { r.SetBasicAuth("root", "s3cret") }, http.StatusUnauthorized},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") }, http.StatusUnauthorized},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("admin", "s3cret") }, http.StatusOK},
+		{"token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer t0ken") }, http.StatusOK},
+	}
+	for _, tc := range testCases {
+		rr := request(tc.setup)
+		if rr.Code != tc.expectedCode {
+			t.Errorf("%s returned %d", tc.name, rr.Code)
+		}
+		if tc.expectedCode == http.StatusUnauthorized && !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Basic ") {
+			t.Errorf("%s did not ask for credentials", tc.name)
+		}
+	}
+
+	// Without credentials configured the admin area does not exist
+	adminAuth.Token, adminAuth.Password = "", ""
+	if rr := request(func(r *http.Request) { r.SetBasicAuth("admin", "") }); rr.Code != http.StatusNotFound {
+		t.Errorf("disabled admin area returned %d", rr.Code)
+	}
+}
+
+func TestAdminPage(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useFavorites(t)
+	useAdmin(t)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(artistImages.Dir, "original.jpg"), make([]byte, 2048), 0o644)
+
+	rr := adminRequest(http.MethodGet, "/admin", nil, nil)
+	cookies, body := rr.Result().Cookies(), rr.Body.String()
+	for _, expected := range []string{
+		upstream.ApiUrls()["artists"],
+		"Discovery: discovered",
+		"24 added, 0 updated, 0 removed, 0 unchanged",
+		"6 artists",
+		"1 files, 2.0 KiB",
+		"0 of 4 busy",
+		"No upstream request failed since start.",
+		"Maintenance mode is off.",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("admin page does not contain %q", expected)
+		}
+	}
+
+	// A failing sync shows up with the failed request
+	upstream.FailPath("/api/artists", http.StatusBadGateway)
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"refresh"}}); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin?done=refresh_failed" {
+		t.Fatalf("failing refresh returned %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	body = adminRequest(http.MethodGet, "/admin?done=refresh_failed", nil, nil).Body.String()
+	for _, expected := range []string{adminMessages["refresh_failed"], `class="upstream-error"`, "502 Bad Gateway", "6 artists"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("admin page after a failed sync does not contain %q", expected)
+		}
+	}
+}
+
+func TestAdminActions(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useFavorites(t)
+	useAdmin(t)
+
+	// Scripts use the token and need no CSRF token
+	req := httptest.NewRequest(http.MethodPost, "/admin", strings.NewReader("action=refresh"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer t0ken")
+	rr := httptest.NewRecorder()
+	handleAdmin(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin?done=refresh" {
+		t.Fatalf("refresh with the token returned %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if upstream.Calls("/api/artists") != 1 || snapshotState.data == nil {
+		t.Errorf("refresh did not sync, %d calls", upstream.Calls("/api/artists"))
+	}
+
+	// Browsers post with the CSRF token of the session the page started
+	cookies := adminRequest(http.MethodGet, "/admin", nil, nil).Result().Cookies()
+	if rr := adminRequest(http.MethodPost, "/admin", nil, url.Values{"action": {"purge"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("posting without a session returned %d", rr.Code)
+	}
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"purge"}, "csrf_token": {"forged"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("posting a forged token returned %d", rr.Code)
+	}
+
+	cached := filepath.Join(artistImages.Dir, "card", "1.jpg")
+	os.MkdirAll(filepath.Dir(cached), 0o755)
+	os.WriteFile(cached, []byte("jpeg"), 0o644)
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"purge"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("purge returned %d", rr.Code)
+	}
+	if _, err := os.Stat(cached); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("cached image still exists: %v", err)
+	}
+
+	// A failed purge is reported on the page
+	artistImages.Dir = "cache\x00images"
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"purge"}}); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin?done=purge_failed" {
+		t.Errorf("failed purge returned %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"reboot"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown action returned %d", rr.Code)
+	}
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t)
+	useAdmin(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/artists", handleArtists)
+	mux.HandleFunc("/api/stats", handleStatsJson)
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/admin", handleAdmin)
+	server := withMaintenance(mux)
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("admin", "s3cret")
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	cookies := adminRequest(http.MethodGet, "/admin", nil, nil).Result().Cookies()
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"maintenance"}, "on": {"true"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("turning maintenance mode on returned %d", rr.Code)
+	}
+	if rr := get("/artists"); rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "maintenance") || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("page in maintenance mode returned %d", rr.Code)
+	}
+	if rr := get("/api/stats"); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("api in maintenance mode returned %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if rr := get("/healthz"); rr.Code != http.StatusOK {
+		t.Errorf("health check in maintenance mode returned %d", rr.Code)
+	}
+	if rr := get("/admin"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Turn maintenance mode off") {
+		t.Errorf("admin page in maintenance mode returned %d", rr.Code)
+	}
+
+	adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"maintenance"}, "on": {"false"}})
+	if rr := get("/artists"); rr.Code != http.StatusOK {
+		t.Errorf("page after maintenance mode returned %d", rr.Code)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+	}
+	for _, tc := range testCases {
+		if got := byteSize(tc.size); got != tc.expected {
+			t.Errorf("byteSize(%d) = %q want %q", tc.size, got, tc.expected)
+		}
+	}
+}
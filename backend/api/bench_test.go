@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Compare with the worker-pool server by running the same benchmarks in
+// backend/api/go-routine, e.g.
+//
+//	go test ./backend/api/... -run NONE -bench Pages -upstream-latency 20ms
+var benchLatency = flag.Duration("upstream-latency", 5*time.Millisecond, "latency of the fake upstream in benchmarks")
+
+var benchmarkPages = []struct {
+	name    string
+	handler http.HandlerFunc
+	target  string
+}{
+	{"index", handleIndex, "/"},
+	{"artists", handleArtists, "/artists"},
+	{"artist", handleArtist, "/artist/1"},
+	{"locations", handleLocations, "/locations"},
+	{"dates", handleDates, "/dates"},
+	{"tours", handleRelations, "/tours"},
+	{"search", handleSearch, "/search?search_text=queen"},
+}
+
+// BenchmarkPages renders every page with parallel clients and reports the
+// number of upstream calls per rendered page.
+func BenchmarkPages(b *testing.B) {
+	for _, page := range benchmarkPages {
+		b.Run(page.name, func(b *testing.B) {
+			upstream := newFakeUpstream(b)
+			upstream.SetLatency(*benchLatency)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					rr := httptest.NewRecorder()
+					page.handler(rr, httptest.NewRequest("GET", page.target, nil))
+					if rr.Code != http.StatusOK {
+						b.Errorf("%s returned %v", page.target, rr.Code)
+					}
+				}
+			})
+			b.StopTimer()
+			b.ReportMetric(float64(upstream.TotalCalls())/float64(b.N), "upstream-calls/op")
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mymain/backend/api/changes"
+	"mymain/backend/api/datastore"
+)
+
+// changesBucket keeps the changelog, keyed by the zero padded change id so
+// keys sort in the order the changes were recorded.
+const changesBucket = "changes"
+
+// maxChanges is how many changes the changelog keeps; older ones are
+// dropped when a sync records new ones.
+const maxChanges = 1000
+
+// changeGroup is the changes one sync found.
+type changeGroup struct {
+	Time    string
+	Changes []changes.Change
+}
+
+func changeKey(id int) string {
+	return fmt.Sprintf("%010d", id)
+}
+
+// changesDump returns the parts of the upstream data the changelog compares.
+func changesDump(snapshot *dataSnapshot) changes.Dump {
+	var dump changes.Dump
+	for _, artist := range snapshot.Artists {
+		dump.Artists = append(dump.Artists, changes.Artist{
+			Id:           artist.Id,
+			Name:         artist.Name,
+			Members:      artist.Members,
+			CreationDate: artist.CreationDate,
+			FirstAlbum:   artist.FirstAlbum,
+		})
+	}
+	for _, relation := range snapshot.Relations.Index {
+		dump.Relations.Index = append(dump.Relations.Index, changes.Relation{Id: relation.Id, DatesLocations: relation.DatesLocations})
+	}
+	return dump
+}
+
+// storedDump reads the artists and relations held by the store.
+func storedDump(tx *datastore.Tx) (changes.Dump, error) {
+	var dump changes.Dump
+	err := readBucket(tx, artistsBucket, func(record []byte) error {
+		var artist changes.Artist
+		if err := json.Unmarshal(record, &artist); err != nil {
+			return err
+		}
+		dump.Artists = append(dump.Artists, artist)
+		return nil
+	})
+	if err != nil {
+		return changes.Dump{}, err
+	}
+	err = readBucket(tx, relationsBucket, func(record []byte) error {
+		var relation changes.Relation
+		if err := json.Unmarshal(record, &relation); err != nil {
+			return err
+		}
+		dump.Relations.Index = append(dump.Relations.Index, relation)
+		return nil
+	})
+	if err != nil {
+		return changes.Dump{}, err
+	}
+	return dump, nil
+}
+
+// recordChanges appends the changes to the changelog with the time of the
+// sync that found them and drops the oldest beyond maxChanges. It returns
+// the changes as recorded, with their ids.
+func recordChanges(tx *datastore.Tx, list []changes.Change, at time.Time) ([]changes.Change, error) {
+	keys := tx.Keys(changesBucket)
+	next := 1
+	if len(keys) > 0 {
+		last, err := strconv.Atoi(keys[len(keys)-1])
+		if err != nil {
+			return nil, err
+		}
+		next = last + 1
+	}
+	var recorded []changes.Change
+	for _, change := range list {
+		change.Id, change.Time = next, at
+		if _, err := tx.PutJson(changesBucket, changeKey(next), change); err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, change)
+		keys = append(keys, changeKey(next))
+		next++
+	}
+	for len(keys) > maxChanges {
+		if _, err := tx.Delete(changesBucket, keys[0]); err != nil {
+			return nil, err
+		}
+		keys = keys[1:]
+	}
+	return recorded, nil
+}
+
+// readChanges returns the recorded changes newer than the change with id
+// since, newest first. artistId limits them to one artist when not zero.
+func readChanges(since int, artistId int) ([]changes.Change, error) {
+	var list []changes.Change
+	err := dataStore.View(func(tx *datastore.Tx) error {
+		keys := tx.Keys(changesBucket)
+		for i := len(keys) - 1; i >= 0; i-- {
+			var change changes.Change
+			if _, err := tx.GetJson(changesBucket, keys[i], &change); err != nil {
+				return err
+			}
+			if change.Id <= since {
+				break
+			}
+			if artistId == 0 || change.ArtistId == artistId {
+				list = append(list, change)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// groupChanges groups changes, newest first, by the sync that found them.
+func groupChanges(list []changes.Change) []changeGroup {
+	var groups []changeGroup
+	for _, change := range list {
+		label := change.Time.UTC().Format("02-01-2006 15:04 MST")
+		if len(groups) == 0 || groups[len(groups)-1].Time != label {
+			groups = append(groups, changeGroup{Time: label})
+		}
+		group := &groups[len(groups)-1]
+		group.Changes = append(group.Changes, change)
+	}
+	return groups
+}
+
+// changesFilter reads the optional artist and since parameters. ok is false
+// when one is not a number.
+func changesFilter(r *http.Request) (since int, artistId int, ok bool) {
+	for name, value := range map[string]*int{"since": &since, "artist": &artistId} {
+		text := r.URL.Query().Get(name)
+		if text == "" {
+			continue
+		}
+		number, err := strconv.Atoi(text)
+		if err != nil || number < 0 {
+			return 0, 0, false
+		}
+		*value = number
+	}
+	return since, artistId, true
+}
+
+// handleChanges lists what changed upstream, grouped by the sync that found
+// it. ?artist= limits the list to one artist.
+func handleChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+	since, artistId, ok := changesFilter(r)
+	if !ok {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"changes.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	list, err := readChanges(since, artistId)
+	if err != nil {
+		log.Printf("reading changes: %v", err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	artist, _ := snapshot.findArtist(artistId)
+	templateData := struct {
+		Artist   ArtistsData
+		Groups   []changeGroup
+		SyncedAt string
+	}{
+		Artist:   artist,
+		Groups:   groupChanges(list),
+		SyncedAt: snapshot.LoadedAt.UTC().Format("02-01-2006 15:04 MST"),
+	}
+
+	tmpl.Execute(w, templateData)
+}
+
+// handleChangesJson is the changelog as a feed, newest first. Readers pass
+// the id of the newest change they have as ?since= to get only newer ones.
+func handleChangesJson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	since, artistId, ok := changesFilter(r)
+	if !ok {
+		writeJson(w, http.StatusBadRequest, map[string]string{"error": "since and artist must be numbers"})
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	list, err := readChanges(since, artistId)
+	if err != nil {
+		log.Printf("reading changes: %v", err)
+		writeJson(w, http.StatusInternalServerError, map[string]string{"error": "reading changes failed"})
+		return
+	}
+	if list == nil {
+		list = []changes.Change{}
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{
+		"synced_at": snapshot.LoadedAt,
+		"changes":   list,
+	})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/changes"
+	"mymain/backend/api/datastore"
+	"mymain/backend/api/fakeapi"
+)
+
+// changeUpstream syncs the fixture, then renames Queen, lets Brian May
+// leave, adds a concert in Tokyo and drops Motörhead before syncing again.
+func changeUpstream(t *testing.T, upstream *fakeapi.Server) {
+	t.Helper()
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	artists := fakeapi.DefaultArtists()
+	artists[0].Name = "Queen + Adam Lambert"
+	artists[0].Members = []string{"Freddie Mercury", "John Daecon", "Roger Meddows-Taylor", "Mike Grose", "Barry Mitchell", "Doug Fogie"}
+	artists[0].Concerts["tokyo-japan"] = []string{"01-03-2020"}
+	upstream.SetArtists(artists[:5])
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncRecordsChanges(t *testing.T) {
+	upstream := newFakeUpstream(t)
+
+	// The first sync is the initial import, not a change
+	report, err := syncStore()
+	if err != nil || report.Changes != 0 {
+		t.Fatalf("first sync reported %+v, %v", report, err)
+	}
+	if list, _ := readChanges(0, 0); len(list) != 0 {
+		t.Fatalf("first sync recorded %v", list)
+	}
+
+	changeUpstream(t, upstream)
+	list, err := readChanges(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []changes.Kind
+	for _, change := range list {
+		kinds = append(kinds, change.Kind)
+	}
+	// Newest first: Motörhead and its two concerts, then Queen
+	expected := []changes.Kind{changes.ConcertRemoved, changes.ConcertRemoved, changes.ArtistRemoved, changes.ConcertAdded, changes.MemberRemoved, changes.ArtistRenamed}
+	if !slices.Equal(kinds, expected) {
+		t.Errorf("recorded %v want %v", kinds, expected)
+	}
+	if list[0].Id != 6 || list[5].Id != 1 || list[0].Time.IsZero() {
+		t.Errorf("changes are numbered %d to %d at %v", list[5].Id, list[0].Id, list[0].Time)
+	}
+
+	// An unchanged upstream records nothing new
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if newer, _ := readChanges(list[0].Id, 0); len(newer) != 0 {
+		t.Errorf("unchanged sync recorded %v", newer)
+	}
+	if queen, _ := readChanges(0, 1); len(queen) != 3 {
+		t.Errorf("Queen has %d changes want 3", len(queen))
+	}
+}
+
+func TestRecordChangesKeepsTheNewest(t *testing.T) {
+	newFakeUpstream(t)
+	list := make([]changes.Change, maxChanges+5)
+	err := dataStore.Update(func(tx *datastore.Tx) error {
+		_, err := recordChanges(tx, list, now())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := readChanges(0, 0)
+	if err != nil || len(kept) != maxChanges || kept[0].Id != maxChanges+5 || kept[len(kept)-1].Id != 6 {
+		t.Errorf("kept %d changes, %v", len(kept), err)
+	}
+}
+
+func TestHandleChanges(t *testing.T) {
+	upstream := newFakeUpstream(t)
+
+	rr := serve(handleChanges, http.MethodGet, "/changes")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `id="changes_empty"`) {
+		t.Errorf("changes page before any change returned %d", rr.Code)
+	}
+
+	changeUpstream(t, upstream)
+	rr = serve(handleChanges, http.MethodGet, "/changes")
+	body := rr.Body.String()
+	for _, expected := range []string{"Queen was renamed to Queen &#43; Adam Lambert", "Brian May left Queen &#43; Adam Lambert", "Adam Lambert plays tokyo-japan on 01-03-2020", `id="change_4"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("changes page does not contain %q", expected)
+		}
+	}
+	if !strings.Contains(body, "Motörhead was removed") || strings.Contains(body, `<a href="/artist/6">Motörhead was removed`) {
+		t.Error("removed artist is missing or links to its page")
+	}
+
+	rr = serve(handleChanges, http.MethodGet, "/changes?artist=6")
+	if body := rr.Body.String(); rr.Code != http.StatusOK || strings.Contains(body, "Queen") {
+		t.Errorf("changes of Motörhead returned %d and mention Queen", rr.Code)
+	}
+
+	for _, target := range []string{"/changes?artist=queen", "/changes?since=-1"} {
+		if rr := serve(handleChanges, http.MethodGet, target); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s returned %d want 400", target, rr.Code)
+		}
+	}
+	if rr := serve(handleChanges, http.MethodPost, "/changes"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /changes returned %d", rr.Code)
+	}
+}
+
+func TestHandleChangesJson(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	changeUpstream(t, upstream)
+
+	var feed struct {
+		Changes []changes.Change `json:"changes"`
+	}
+	rr := serve(handleChangesJson, http.MethodGet, "/api/changes?since=3")
+	if err := json.Unmarshal(rr.Body.Bytes(), &feed); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("feed returned %d, %v", rr.Code, err)
+	}
+	if len(feed.Changes) != 3 || feed.Changes[0].Id != 6 || feed.Changes[2].Kind != changes.ArtistRemoved {
+		t.Errorf("feed since 3 returned %+v", feed.Changes)
+	}
+
+	rr = serve(handleChangesJson, http.MethodGet, "/api/changes?since=6")
+	if body := strings.TrimSpace(rr.Body.String()); !strings.Contains(body, `"changes":[]`) {
+		t.Errorf("feed without newer changes returned %s", body)
+	}
+	if rr := serve(handleChangesJson, http.MethodGet, "/api/changes?artist=x"); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad artist returned %d", rr.Code)
+	}
+}
@@ -0,0 +1,244 @@
+// Package changes compares two copies of the upstream data and lists what
+// changed between them: artists added, removed or renamed, members joining
+// or leaving, creation date and first album corrections, and concerts added
+// or removed.
+package changes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of a change.
+type Kind string
+
+const (
+	ArtistAdded         Kind = "artist_added"
+	ArtistRemoved       Kind = "artist_removed"
+	ArtistRenamed       Kind = "artist_renamed"
+	MemberAdded         Kind = "member_added"
+	MemberRemoved       Kind = "member_removed"
+	CreationDateChanged Kind = "creation_date_changed"
+	FirstAlbumChanged   Kind = "first_album_changed"
+	ConcertAdded        Kind = "concert_added"
+	ConcertRemoved      Kind = "concert_removed"
+)
+
+// Artist holds the fields of an upstream artist that are compared.
+type Artist struct {
+	Id           int      `json:"id"`
+	Name         string   `json:"name"`
+	Members      []string `json:"members"`
+	CreationDate int      `json:"creationDate"`
+	FirstAlbum   string   `json:"firstAlbum"`
+}
+
+// Relation is the concerts of an artist: dates keyed by location slug.
+type Relation struct {
+	Id             int                 `json:"id"`
+	DatesLocations map[string][]string `json:"datesLocations"`
+}
+
+// Dump is a copy of the upstream data in the shape of the upstream API: the
+// artists list and the relation index.
+type Dump struct {
+	Artists   []Artist `json:"artists"`
+	Relations struct {
+		Index []Relation `json:"index"`
+	} `json:"relations"`
+}
+
+// Change is one difference between two dumps. Before and After hold the old
+// and new value of a renamed artist or a changed field, Member the member
+// who joined or left, Location and Date the concert added or removed. Id and
+// Time are set when the change is recorded.
+type Change struct {
+	Id         int       `json:"id,omitempty"`
+	Time       time.Time `json:"time"`
+	Kind       Kind      `json:"kind"`
+	ArtistId   int       `json:"artist_id"`
+	ArtistName string    `json:"artist_name"`
+	Member     string    `json:"member,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	Date       string    `json:"date,omitempty"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+}
+
+// String describes the change in a sentence.
+func (c Change) String() string {
+	switch c.Kind {
+	case ArtistAdded:
+		return fmt.Sprintf("%s was added", c.ArtistName)
+	case ArtistRemoved:
+		return fmt.Sprintf("%s was removed", c.ArtistName)
+	case ArtistRenamed:
+		return fmt.Sprintf("%s was renamed to %s", c.Before, c.After)
+	case MemberAdded:
+		return fmt.Sprintf("%s joined %s", c.Member, c.ArtistName)
+	case MemberRemoved:
+		return fmt.Sprintf("%s left %s", c.Member, c.ArtistName)
+	case CreationDateChanged:
+		return fmt.Sprintf("creation date of %s changed from %s to %s", c.ArtistName, c.Before, c.After)
+	case FirstAlbumChanged:
+		return fmt.Sprintf("first album of %s changed from %s to %s", c.ArtistName, c.Before, c.After)
+	case ConcertAdded:
+		return fmt.Sprintf("%s plays %s on %s", c.ArtistName, c.Location, c.Date)
+	case ConcertRemoved:
+		return fmt.Sprintf("%s no longer plays %s on %s", c.ArtistName, c.Location, c.Date)
+	}
+	return fmt.Sprintf("%s: %s", c.ArtistName, c.Kind)
+}
+
+// Diff returns the changes from old to current, ordered by artist id. The
+// changes of an artist come in the order of the Kind constants, members and
+// concerts in the order of the new data. Concerts of added and removed
+// artists are listed too, so every concert that appeared or disappeared
+// shows up.
+func Diff(old Dump, current Dump) []Change {
+	oldArtists, newArtists := artistsById(old.Artists), artistsById(current.Artists)
+	oldConcerts, newConcerts := relationsById(old.Relations.Index), relationsById(current.Relations.Index)
+
+	var ids []int
+	for id := range oldArtists {
+		ids = append(ids, id)
+	}
+	for id := range newArtists {
+		if _, found := oldArtists[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	var list []Change
+	for _, id := range ids {
+		before, hadBefore := oldArtists[id]
+		after, hasAfter := newArtists[id]
+		name := after.Name
+		if !hasAfter {
+			name = before.Name
+		}
+		change := func(kind Kind) Change {
+			return Change{Kind: kind, ArtistId: id, ArtistName: name}
+		}
+
+		switch {
+		case !hadBefore:
+			list = append(list, change(ArtistAdded))
+		case !hasAfter:
+			list = append(list, change(ArtistRemoved))
+		default:
+			list = append(list, artistChanges(before, after, change)...)
+		}
+
+		for _, concert := range missing(concertKeys(newConcerts[id]), concertKeys(oldConcerts[id])) {
+			added := change(ConcertAdded)
+			added.Location, added.Date = concert.location, concert.date
+			list = append(list, added)
+		}
+		for _, concert := range missing(concertKeys(oldConcerts[id]), concertKeys(newConcerts[id])) {
+			removed := change(ConcertRemoved)
+			removed.Location, removed.Date = concert.location, concert.date
+			list = append(list, removed)
+		}
+	}
+	return list
+}
+
+// artistChanges compares the fields of an artist present in both dumps.
+func artistChanges(before Artist, after Artist, change func(Kind) Change) []Change {
+	var list []Change
+	if before.Name != after.Name {
+		renamed := change(ArtistRenamed)
+		renamed.Before, renamed.After = before.Name, after.Name
+		list = append(list, renamed)
+	}
+	oldMembers, newMembers := normalizeAll(before.Members), normalizeAll(after.Members)
+	for _, member := range missing(newMembers, oldMembers) {
+		added := change(MemberAdded)
+		added.Member = member
+		list = append(list, added)
+	}
+	for _, member := range missing(oldMembers, newMembers) {
+		removed := change(MemberRemoved)
+		removed.Member = member
+		list = append(list, removed)
+	}
+	if before.CreationDate != after.CreationDate {
+		changed := change(CreationDateChanged)
+		changed.Before, changed.After = fmt.Sprint(before.CreationDate), fmt.Sprint(after.CreationDate)
+		list = append(list, changed)
+	}
+	if before.FirstAlbum != after.FirstAlbum {
+		changed := change(FirstAlbumChanged)
+		changed.Before, changed.After = before.FirstAlbum, after.FirstAlbum
+		list = append(list, changed)
+	}
+	return list
+}
+
+type concert struct {
+	location string
+	date     string
+}
+
+// concertKeys lists the concerts of a relation by location, then in the
+// order of their dates.
+func concertKeys(relation Relation) []concert {
+	var locations []string
+	for location := range relation.DatesLocations {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+
+	var list []concert
+	for _, location := range locations {
+		for _, date := range relation.DatesLocations[location] {
+			list = append(list, concert{location: location, date: strings.TrimPrefix(date, "*")})
+		}
+	}
+	return list
+}
+
+// missing returns the items of list that are not in other.
+func missing[T comparable](list []T, other []T) []T {
+	present := map[T]bool{}
+	for _, item := range other {
+		present[item] = true
+	}
+	var result []T
+	for _, item := range list {
+		if !present[item] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// normalizeAll collapses whitespace runs in every name, so reformatted
+// names do not count as members leaving and joining.
+func normalizeAll(names []string) []string {
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		list = append(list, strings.Join(strings.Fields(name), " "))
+	}
+	return list
+}
+
+func artistsById(artists []Artist) map[int]Artist {
+	byId := map[int]Artist{}
+	for _, artist := range artists {
+		byId[artist.Id] = artist
+	}
+	return byId
+}
+
+func relationsById(relations []Relation) map[int]Relation {
+	byId := map[int]Relation{}
+	for _, relation := range relations {
+		byId[relation.Id] = relation
+	}
+	return byId
+}
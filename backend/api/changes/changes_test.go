@@ -0,0 +1,74 @@
+package changes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func dump(artists []Artist, relations ...Relation) Dump {
+	var d Dump
+	d.Artists = artists
+	d.Relations.Index = relations
+	return d
+}
+
+func TestDiff(t *testing.T) {
+	queen := Artist{Id: 1, Name: "Queen", Members: []string{"Freddie Mercury", "Brian May"}, CreationDate: 1970, FirstAlbum: "14-12-1973"}
+	soja := Artist{Id: 2, Name: "SOJA", Members: []string{"Jacob Hemphill"}, CreationDate: 1997, FirstAlbum: "05-06-2002"}
+	pinkFloyd := Artist{Id: 3, Name: "Pink Floyd", Members: []string{"Roger Waters"}, CreationDate: 1965, FirstAlbum: "05-08-1967"}
+
+	old := dump(
+		[]Artist{queen, soja},
+		Relation{Id: 1, DatesLocations: map[string][]string{"osaka-japan": {"28-01-2020"}, "nagoya-japan": {"30-01-2019"}}},
+		Relation{Id: 2, DatesLocations: map[string][]string{"new_york-usa": {"13-10-2019"}}},
+	)
+
+	changed := queen
+	changed.Name = "Queen + Adam Lambert"
+	changed.Members = []string{"Brian  May", "Adam Lambert"}
+	changed.FirstAlbum = "13-07-1973"
+	current := dump(
+		[]Artist{pinkFloyd, changed},
+		Relation{Id: 1, DatesLocations: map[string][]string{"osaka-japan": {"28-01-2020", "29-01-2020"}}},
+		Relation{Id: 3, DatesLocations: map[string][]string{"berlin-germany": {"*05-12-2019"}}},
+	)
+
+	expected := []Change{
+		{Kind: ArtistRenamed, ArtistId: 1, ArtistName: "Queen + Adam Lambert", Before: "Queen", After: "Queen + Adam Lambert"},
+		{Kind: MemberAdded, ArtistId: 1, ArtistName: "Queen + Adam Lambert", Member: "Adam Lambert"},
+		{Kind: MemberRemoved, ArtistId: 1, ArtistName: "Queen + Adam Lambert", Member: "Freddie Mercury"},
+		{Kind: FirstAlbumChanged, ArtistId: 1, ArtistName: "Queen + Adam Lambert", Before: "14-12-1973", After: "13-07-1973"},
+		{Kind: ConcertAdded, ArtistId: 1, ArtistName: "Queen + Adam Lambert", Location: "osaka-japan", Date: "29-01-2020"},
+		{Kind: ConcertRemoved, ArtistId: 1, ArtistName: "Queen + Adam Lambert", Location: "nagoya-japan", Date: "30-01-2019"},
+		{Kind: ArtistRemoved, ArtistId: 2, ArtistName: "SOJA"},
+		{Kind: ConcertRemoved, ArtistId: 2, ArtistName: "SOJA", Location: "new_york-usa", Date: "13-10-2019"},
+		{Kind: ArtistAdded, ArtistId: 3, ArtistName: "Pink Floyd"},
+		{Kind: ConcertAdded, ArtistId: 3, ArtistName: "Pink Floyd", Location: "berlin-germany", Date: "05-12-2019"},
+	}
+	if got := Diff(old, current); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Diff returned\n%+v\nwant\n%+v", got, expected)
+	}
+
+	if got := Diff(current, current); len(got) != 0 {
+		t.Errorf("Diff of equal dumps returned %+v", got)
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	testCases := []struct {
+		change   Change
+		expected string
+	}{
+		{Change{Kind: ArtistAdded, ArtistName: "Queen"}, "Queen was added"},
+		{Change{Kind: ArtistRenamed, ArtistName: "Queen II", Before: "Queen", After: "Queen II"}, "Queen was renamed to Queen II"},
+		{Change{Kind: MemberRemoved, ArtistName: "Queen", Member: "Freddie Mercury"}, "Freddie Mercury left Queen"},
+		{Change{Kind: CreationDateChanged, ArtistName: "Queen", Before: "1970", After: "1971"}, "creation date of Queen changed from 1970 to 1971"},
+		{Change{Kind: ConcertAdded, ArtistName: "Queen", Location: "osaka-japan", Date: "28-01-2020"}, "Queen plays osaka-japan on 28-01-2020"},
+		{Change{Kind: ConcertRemoved, ArtistName: "Queen", Location: "osaka-japan", Date: "28-01-2020"}, "Queen no longer plays osaka-japan on 28-01-2020"},
+	}
+	for _, tc := range testCases {
+		if got := tc.change.String(); got != tc.expected {
+			t.Errorf("String() = %q want %q", got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCompareArtists limits how many artists are shown side by side.
+const maxCompareArtists = 4
+
+// compareColumn is one artist of the comparison.
+type compareColumn struct {
+	Artist      ArtistsData
+	Locations   LocationsDataLevel2
+	Concerts    []Concert
+	CareerStart int
+	CareerEnd   int
+	Countries   []string
+}
+
+// CareerYears is the time from the creation of the artist to its latest
+// known concert, or to the first album when there are no concerts.
+func (c compareColumn) CareerYears() int {
+	return c.CareerEnd - c.CareerStart
+}
+
+// compareOverlap is a location played by more than one of the compared
+// artists. Dates holds the dates of every column.
+type compareOverlap struct {
+	Location string
+	Name     string
+	Dates    [][]compareDate
+}
+
+// compareDate is a concert date, Shared when another compared artist played
+// the same location on that day.
+type compareDate struct {
+	Text   string
+	Shared bool
+}
+
+// parseCompareIds reads ?ids=1,5,12, also accepting repeated ids
+// parameters as sent by the checkboxes on the artists page. Duplicates are
+// dropped and the order is kept.
+func parseCompareIds(values []string) ([]int, error) {
+	var ids []int
+	for _, value := range values {
+		for _, text := range strings.Split(value, ",") {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				continue
+			}
+			id, err := strconv.Atoi(text)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid artist id %q", text)
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > maxCompareArtists {
+		return nil, fmt.Errorf("at most %d artists can be compared", maxCompareArtists)
+	}
+	return ids, nil
+}
+
+// newCompareColumn derives the compared figures of one artist.
+func newCompareColumn(artist ArtistsData, locations LocationsDataLevel2, relation RelationsDataLevel2) compareColumn {
+	column := compareColumn{
+		Artist:      artist,
+		Locations:   locations,
+		Concerts:    artistConcerts(artist, relation),
+		CareerStart: artist.CreationDate,
+		CareerEnd:   artist.CreationDate,
+	}
+	if album, err := parseDate(artist.FirstAlbum); err == nil {
+		column.CareerEnd = max(column.CareerEnd, album.Year())
+	}
+
+	countries := map[string]bool{}
+	for _, concert := range column.Concerts {
+		column.CareerEnd = max(column.CareerEnd, concert.Date.Year())
+		if !countries[concert.Country] {
+			countries[concert.Country] = true
+			column.Countries = append(column.Countries, countryName(concert.Country))
+		}
+	}
+	sort.Strings(column.Countries)
+	return column
+}
+
+// compareOverlaps lists the locations played by at least two of the
+// columns, in alphabetical order.
+func compareOverlaps(columns []compareColumn) []compareOverlap {
+	dates := map[string][][]string{}
+	for i, column := range columns {
+		for _, concert := range column.Concerts {
+			if dates[concert.Location] == nil {
+				dates[concert.Location] = make([][]string, len(columns))
+			}
+			dates[concert.Location][i] = append(dates[concert.Location][i], concert.DateText())
+		}
+	}
+
+	var overlaps []compareOverlap
+	for location, columnDates := range dates {
+		// seen counts the columns playing each date, once per column even
+		// when an artist lists a date twice
+		played := 0
+		seen := map[string]int{}
+		for _, list := range columnDates {
+			if len(list) > 0 {
+				played++
+			}
+			for _, date := range slices.Compact(slices.Sorted(slices.Values(list))) {
+				seen[date]++
+			}
+		}
+		if played < 2 {
+			continue
+		}
+
+		overlap := compareOverlap{Location: location, Name: locationName(location)}
+		for _, list := range columnDates {
+			var marked []compareDate
+			for _, date := range list {
+				marked = append(marked, compareDate{Text: date, Shared: seen[date] > 1})
+			}
+			overlap.Dates = append(overlap.Dates, marked)
+		}
+		overlaps = append(overlaps, overlap)
+	}
+	sort.Slice(overlaps, func(i, j int) bool {
+		return overlaps[i].Name < overlaps[j].Name
+	})
+	return overlaps
+}
+
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	ids, err := parseCompareIds(r.URL.Query()["ids"])
+	if err != nil {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+	if len(ids) == 0 {
+		http.Redirect(w, r, "/artists", http.StatusSeeOther)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"compare.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	var columns []compareColumn
+	for _, id := range ids {
+		artist, found := snapshot.findArtist(id)
+		if !found {
+			handleErrorPage(w, r, NotFoundError)
+			return
+		}
+		columns = append(columns, newCompareColumn(artist, snapshot.findLocations(id), snapshot.findRelation(id)))
+	}
+
+	templateData := struct {
+		Columns  []compareColumn
+		Overlaps []compareOverlap
+	}{
+		Columns:  columns,
+		Overlaps: compareOverlaps(columns),
+	}
+
+	tmpl.Execute(w, templateData)
+}
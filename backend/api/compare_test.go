@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCompareIds(t *testing.T) {
+	testCases := []struct {
+		values   []string
+		expected []int
+		valid    bool
+	}{
+		{[]string{"1,5,12"}, []int{1, 5, 12}, true},
+		{[]string{"3", "1", "3"}, []int{3, 1}, true},
+		{[]string{" 2 , ,4"}, []int{2, 4}, true},
+		{nil, nil, true},
+		{[]string{"1,abc"}, nil, false},
+		{[]string{"0"}, nil, false},
+		{[]string{"1,2,3,4,5"}, nil, false},
+	}
+	for _, tc := range testCases {
+		ids, err := parseCompareIds(tc.values)
+		if (err == nil) != tc.valid || !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("parseCompareIds(%q) = %v, %v want %v", tc.values, ids, err, tc.expected)
+		}
+	}
+}
+
+func TestCompareOverlaps(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var columns []compareColumn
+	for _, id := range []int{1, 2, 3} {
+		artist, _ := snapshot.findArtist(id)
+		columns = append(columns, newCompareColumn(artist, LocationsDataLevel2{}, snapshot.findRelation(id)))
+	}
+	if careers := []int{columns[0].CareerYears(), columns[1].CareerYears(), columns[2].CareerYears()}; !reflect.DeepEqual(careers, []int{50, 23, 55}) {
+		t.Errorf("career lengths are %v", careers)
+	}
+	if !reflect.DeepEqual(columns[1].Countries, []string{"Japan", "Mexico", "USA"}) {
+		t.Errorf("SOJA visited %v", columns[1].Countries)
+	}
+
+	overlaps := compareOverlaps(columns)
+	expected := []compareOverlap{
+		{
+			Location: "los_angeles-usa",
+			Name:     "Los Angeles, USA",
+			Dates: [][]compareDate{
+				{{"20-08-2019", true}},
+				{{"21-08-2019", false}},
+				{{"20-08-2019", true}},
+			},
+		},
+		{
+			Location: "osaka-japan",
+			Name:     "Osaka, Japan",
+			Dates: [][]compareDate{
+				{{"28-01-2020", true}},
+				{{"28-01-2020", true}},
+				nil,
+			},
+		},
+	}
+	if !reflect.DeepEqual(overlaps, expected) {
+		t.Errorf("overlaps are %+v want %+v", overlaps, expected)
+	}
+
+	// A date listed twice by one artist is not shared with themselves
+	twice := Concert{Location: "osaka-japan", Date: time.Date(2020, 1, 28, 0, 0, 0, 0, time.UTC)}
+	other := Concert{Location: "osaka-japan", Date: time.Date(2020, 1, 29, 0, 0, 0, 0, time.UTC)}
+	overlaps = compareOverlaps([]compareColumn{{Concerts: []Concert{twice, twice}}, {Concerts: []Concert{other}}})
+	if len(overlaps) != 1 || overlaps[0].Dates[0][0].Shared || overlaps[0].Dates[0][1].Shared {
+		t.Errorf("overlaps are %+v", overlaps)
+	}
+}
+
+func TestHandleCompare(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleCompare, "GET", "/compare?ids=1,2,3")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HandleCompare returned %v", rr.Code)
+	}
+	for _, expected := range []string{`id="compare_1"`, `id="compare_3"`, `id="overlap_osaka-japan"`, `<td>50 years (1970 - 2020)</td>`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("compare page does not contain %s", expected)
+		}
+	}
+
+	// The checkboxes on the artists page send repeated parameters
+	if rr := serve(handleCompare, "GET", "/compare?ids=5&ids=6"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `id="overlap_london-uk"`) {
+		t.Errorf("HandleCompare returned %v for repeated ids", rr.Code)
+	}
+
+	testCases := []struct {
+		target       string
+		expectedCode int
+	}{
+		{"/compare", http.StatusSeeOther},
+		{"/compare?ids=1,x", http.StatusBadRequest},
+		{"/compare?ids=1,99", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		if rr := serve(handleCompare, "GET", tc.target); rr.Code != tc.expectedCode {
+			t.Errorf("%s returned %v want %v", tc.target, rr.Code, tc.expectedCode)
+		}
+	}
+	if rr := serve(handleCompare, "POST", "/compare?ids=1"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HandleCompare returned %v for POST", rr.Code)
+	}
+}
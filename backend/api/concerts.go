@@ -0,0 +1,175 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// dateLayout is the format of every date in the upstream data.
+const dateLayout = "02-01-2006"
+
+// Concert is a single date of an artist at a location, flattened from the
+// relations data.
+type Concert struct {
+	ArtistId   int       `json:"artistId"`
+	ArtistName string    `json:"artistName"`
+	Location   string    `json:"location"`
+	City       string    `json:"city"`
+	Country    string    `json:"country"`
+	Date       time.Time `json:"date"`
+}
+
+// DateText formats the concert date like the upstream does.
+func (c Concert) DateText() string {
+	return c.Date.Format(dateLayout)
+}
+
+// parseDate reads an upstream date. The dates index marks the first date of
+// every location with a leading "*".
+func parseDate(text string) (time.Time, error) {
+	return time.Parse(dateLayout, strings.TrimPrefix(strings.TrimSpace(text), "*"))
+}
+
+// splitLocation splits a location slug such as "los_angeles-usa" into its
+// city and country slugs.
+func splitLocation(slug string) (string, string) {
+	index := strings.LastIndex(slug, "-")
+	if index < 0 {
+		return slug, ""
+	}
+	return slug[:index], slug[index+1:]
+}
+
+// placeName turns a city or country slug into a display name, e.g.
+// "new_zealand" into "New Zealand".
+func placeName(slug string) string {
+	words := strings.FieldsFunc(slug, func(r rune) bool { return r == '_' || r == ' ' })
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// countryName is placeName for countries, where short slugs such as "usa"
+// and "uk" are abbreviations.
+func countryName(slug string) string {
+	if len(slug) <= 3 {
+		return strings.ToUpper(slug)
+	}
+	return placeName(slug)
+}
+
+// locationName formats a location slug as "Los Angeles, USA".
+func locationName(slug string) string {
+	city, country := splitLocation(slug)
+	if country == "" {
+		return placeName(city)
+	}
+	return placeName(city) + ", " + countryName(country)
+}
+
+// artistConcerts flattens the relation of one artist, sorted by date.
+// Dates that can not be parsed are skipped.
+func artistConcerts(artist ArtistsData, relation RelationsDataLevel2) []Concert {
+	var concerts []Concert
+	for location, dates := range relation.DatesLocations {
+		city, country := splitLocation(location)
+		for _, text := range dates {
+			date, err := parseDate(text)
+			if err != nil {
+				continue
+			}
+			concerts = append(concerts, Concert{
+				ArtistId:   artist.Id,
+				ArtistName: artist.Name,
+				Location:   location,
+				City:       city,
+				Country:    country,
+				Date:       date,
+			})
+		}
+	}
+	sortConcerts(concerts)
+	return concerts
+}
+
+// allConcerts flattens the relations of every artist in the snapshot,
+// sorted by date.
+func allConcerts(snapshot *dataSnapshot) []Concert {
+	artists := map[int]ArtistsData{}
+	for _, artist := range snapshot.Artists {
+		artists[artist.Id] = artist
+	}
+
+	var concerts []Concert
+	for _, relation := range snapshot.Relations.Index {
+		artist, found := artists[relation.Id]
+		if !found {
+			continue
+		}
+		concerts = append(concerts, artistConcerts(artist, relation)...)
+	}
+	sortConcerts(concerts)
+	return concerts
+}
+
+func sortConcerts(concerts []Concert) {
+	sort.Slice(concerts, func(i, j int) bool {
+		return concertLess(concerts[i], concerts[j])
+	})
+}
+
+// concertLess orders concerts by date, then location, then artist.
+func concertLess(a, b Concert) bool {
+	if !a.Date.Equal(b.Date) {
+		return a.Date.Before(b.Date)
+	}
+	if a.Location != b.Location {
+		return a.Location < b.Location
+	}
+	return a.ArtistId < b.ArtistId
+}
+
+// findArtist returns the artist with the given id from the snapshot.
+func (s *dataSnapshot) findArtist(id int) (ArtistsData, bool) {
+	for _, artist := range s.Artists {
+		if artist.Id == id {
+			return artist, true
+		}
+	}
+	return ArtistsData{}, false
+}
+
+// findLocations returns the locations of the artist with the given id.
+func (s *dataSnapshot) findLocations(id int) LocationsDataLevel2 {
+	for _, locations := range s.Locations.Index {
+		if locations.Id == id {
+			return locations
+		}
+	}
+	return LocationsDataLevel2{Id: id}
+}
+
+// findDates returns the concert dates of the artist with the given id.
+func (s *dataSnapshot) findDates(id int) DatesDataLevel2 {
+	for _, dates := range s.Dates.Index {
+		if dates.Id == id {
+			return dates
+		}
+	}
+	return DatesDataLevel2{Id: id}
+}
+
+// findRelation returns the relation of the artist with the given id.
+func (s *dataSnapshot) findRelation(id int) RelationsDataLevel2 {
+	for _, relation := range s.Relations.Index {
+		if relation.Id == id {
+			return relation
+		}
+	}
+	return RelationsDataLevel2{Id: id}
+}
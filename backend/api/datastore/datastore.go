@@ -0,0 +1,216 @@
+// Package datastore is a small embedded key value database kept in one
+// JSON file.
+//
+// Records are JSON documents stored under a key in a named bucket, in the
+// spirit of bbolt. All access goes through transactions: View for reading
+// and Update for writing. An Update either succeeds and is on disk, or
+// fails and changes nothing. The whole database is held in memory, which
+// suits the few megabytes of artist data it is made for.
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DB is an open database.
+type DB struct {
+	path string
+
+	mu      sync.RWMutex
+	buckets map[string]map[string]json.RawMessage
+}
+
+// Open opens the database kept at path, creating it on the first update.
+// An empty path opens a database that only lives in memory.
+func Open(path string) (*DB, error) {
+	db := &DB{path: path, buckets: map[string]map[string]json.RawMessage{}}
+	if path == "" {
+		return db, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &db.buckets); err != nil {
+		return nil, err
+	}
+	for name, bucket := range db.buckets {
+		if bucket == nil {
+			db.buckets[name] = map[string]json.RawMessage{}
+		}
+	}
+	return db, nil
+}
+
+// Path returns the file of the database, empty for memory only ones.
+func (db *DB) Path() string {
+	return db.path
+}
+
+// View runs fn in a read only transaction.
+func (db *DB) View(fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(&Tx{db: db})
+}
+
+// Update runs fn in a read write transaction. The changes are written when
+// fn returns nil and dropped when it returns an error or writing fails.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tx := &Tx{db: db, writable: true, changes: map[string]map[string]json.RawMessage{}}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.changes) == 0 {
+		return nil
+	}
+
+	// Copy the touched buckets so a failed write leaves them untouched
+	buckets := make(map[string]map[string]json.RawMessage, len(db.buckets))
+	for name, bucket := range db.buckets {
+		buckets[name] = bucket
+	}
+	for name, changes := range tx.changes {
+		bucket := make(map[string]json.RawMessage, len(buckets[name])+len(changes))
+		for key, value := range buckets[name] {
+			bucket[key] = value
+		}
+		for key, value := range changes {
+			if value == nil {
+				delete(bucket, key)
+			} else {
+				bucket[key] = value
+			}
+		}
+		buckets[name] = bucket
+	}
+	if err := db.save(buckets); err != nil {
+		return err
+	}
+	db.buckets = buckets
+	return nil
+}
+
+func (db *DB) save(buckets map[string]map[string]json.RawMessage) error {
+	if db.path == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(buckets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(db.path), 0o755); err != nil {
+		return err
+	}
+	tmp := db.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, db.path)
+}
+
+// ErrReadOnly is returned when writing in a View transaction.
+var ErrReadOnly = errors.New("datastore: write in a read only transaction")
+
+// Tx is a transaction. It must not be used after its function returns.
+type Tx struct {
+	db       *DB
+	writable bool
+	// changes holds the writes of an Update; nil values are deletions
+	changes map[string]map[string]json.RawMessage
+}
+
+// Get returns the record stored under key, nil when there is none.
+func (tx *Tx) Get(bucket string, key string) []byte {
+	if value, changed := tx.changes[bucket][key]; changed {
+		return value
+	}
+	return tx.db.buckets[bucket][key]
+}
+
+// Keys returns the keys of the bucket in ascending order.
+func (tx *Tx) Keys(bucket string) []string {
+	var keys []string
+	for key := range tx.db.buckets[bucket] {
+		if value, changed := tx.changes[bucket][key]; changed && value == nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	for key, value := range tx.changes[bucket] {
+		if _, stored := tx.db.buckets[bucket][key]; !stored && value != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Put stores value, which must be valid JSON, under key. It reports
+// whether the record is new or differs from the stored one; storing an
+// equal record is not a change.
+func (tx *Tx) Put(bucket string, key string, value []byte) (bool, error) {
+	if !tx.writable {
+		return false, ErrReadOnly
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, value); err != nil {
+		return false, err
+	}
+	if previous := tx.Get(bucket, key); previous != nil && bytes.Equal(previous, compact.Bytes()) {
+		return false, nil
+	}
+	tx.change(bucket, key, json.RawMessage(compact.Bytes()))
+	return true, nil
+}
+
+// PutJson marshals value and stores it under key, see Put.
+func (tx *Tx) PutJson(bucket string, key string, value interface{}) (bool, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return tx.Put(bucket, key, content)
+}
+
+// GetJson unmarshals the record stored under key into value. It reports
+// false when there is no such record.
+func (tx *Tx) GetJson(bucket string, key string, value interface{}) (bool, error) {
+	content := tx.Get(bucket, key)
+	if content == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(content, value)
+}
+
+// Delete removes the record stored under key and reports whether there
+// was one.
+func (tx *Tx) Delete(bucket string, key string) (bool, error) {
+	if !tx.writable {
+		return false, ErrReadOnly
+	}
+	if tx.Get(bucket, key) == nil {
+		return false, nil
+	}
+	tx.change(bucket, key, nil)
+	return true, nil
+}
+
+func (tx *Tx) change(bucket string, key string, value json.RawMessage) {
+	if tx.changes[bucket] == nil {
+		tx.changes[bucket] = map[string]json.RawMessage{}
+	}
+	tx.changes[bucket][key] = value
+}
@@ -0,0 +1,147 @@
+package datastore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUpdateView(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store", "data.json")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *Tx) error {
+		for _, tc := range []struct {
+			key, value string
+			changed    bool
+		}{
+			{"1", `{"name": "Queen"}`, true},
+			{"2", `{"name":"SOJA"}`, true},
+			// Equal records are not changes, whatever their spacing
+			{"1", `{ "name":"Queen" }`, false},
+			{"1", `{"name":"Queen II"}`, true},
+		} {
+			if changed, err := tx.Put("artists", tc.key, []byte(tc.value)); err != nil || changed != tc.changed {
+				t.Errorf("Put(%s, %s) = %v, %v want %v", tc.key, tc.value, changed, err, tc.changed)
+			}
+		}
+		if got := tx.Keys("artists"); !slices.Equal(got, []string{"1", "2"}) {
+			t.Errorf("Keys within the update = %v", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A reopened database reads what was written
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.View(func(tx *Tx) error {
+		var artist struct{ Name string }
+		if found, err := tx.GetJson("artists", "1", &artist); !found || err != nil || artist.Name != "Queen II" {
+			t.Errorf("GetJson returned %+v, %v, %v", artist, found, err)
+		}
+		if tx.Get("artists", "3") != nil || tx.Get("nothing", "1") != nil {
+			t.Error("Get found a missing record")
+		}
+		if _, err := tx.Put("artists", "3", []byte(`{}`)); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("Put in View returned %v", err)
+		}
+		return nil
+	})
+
+	db.Update(func(tx *Tx) error {
+		if deleted, _ := tx.Delete("artists", "2"); !deleted {
+			t.Error("Delete did not find the record")
+		}
+		if deleted, _ := tx.Delete("artists", "2"); deleted {
+			t.Error("Delete found a deleted record")
+		}
+		tx.PutJson("artists", "10", map[string]string{"name": "Pink Floyd"})
+		if got := tx.Keys("artists"); !slices.Equal(got, []string{"1", "10"}) {
+			t.Errorf("Keys after delete = %v", got)
+		}
+		return nil
+	})
+	db.View(func(tx *Tx) error {
+		if got := tx.Keys("artists"); !slices.Equal(got, []string{"1", "10"}) {
+			t.Errorf("Keys after the update = %v", got)
+		}
+		return nil
+	})
+}
+
+func TestUpdateRollback(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Update(func(tx *Tx) error {
+		_, err := tx.Put("artists", "1", []byte(`{"name":"Queen"}`))
+		return err
+	})
+
+	failure := errors.New("sync failed")
+	err = db.Update(func(tx *Tx) error {
+		tx.Put("artists", "1", []byte(`{"name":"changed"}`))
+		tx.Delete("artists", "1")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Update returned %v", err)
+	}
+	db.View(func(tx *Tx) error {
+		if got := string(tx.Get("artists", "1")); got != `{"name":"Queen"}` {
+			t.Errorf("failed update changed the record to %s", got)
+		}
+		return nil
+	})
+
+	if err := db.Update(func(tx *Tx) error {
+		_, err := tx.Put("artists", "2", []byte(`not json`))
+		return err
+	}); err == nil {
+		t.Error("Put stored invalid JSON")
+	}
+}
+
+func TestUpdateWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory where the temporary file goes makes the write fail
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *Tx) error {
+		_, err := tx.Put("artists", "1", []byte(`{}`))
+		return err
+	}); err == nil {
+		t.Fatal("Update succeeded without writing")
+	}
+	db.View(func(tx *Tx) error {
+		if tx.Get("artists", "1") != nil {
+			t.Error("failed write kept the record in memory")
+		}
+		return nil
+	})
+}
+
+func TestOpenCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(path, []byte("{"), 0o644)
+	if _, err := Open(path); err == nil {
+		t.Error("Open read a corrupt file")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Retry policy for upstream endpoint discovery.
+var (
+	discoveryMaxAttempts    = 5
+	discoveryInitialBackoff = 1 * time.Second
+	discoveryMaxBackoff     = 30 * time.Second
+	discoveryInterval       = 10 * time.Minute
+	discoveryRetryInterval  = 1 * time.Minute
+)
+
+// defaultApiPaths are the well-known endpoint paths of the Groupie API, used
+// when the base endpoint can not be reached.
+var defaultApiPaths = map[string]string{
+	"artists":   "/artists",
+	"locations": "/locations",
+	"dates":     "/dates",
+	"relations": "/relation",
+}
+
+const (
+	discoveryPending    = "pending"
+	discoveryDiscovered = "discovered"
+	discoveryFallback   = "fallback"
+)
+
+type DiscoveryStatus struct {
+	Source      string    `json:"source"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+}
+
+var apiUrlsMu sync.RWMutex
+
+var discoveryState = DiscoveryStatus{Source: discoveryPending}
+
+// apiUrl returns the discovered url of an upstream resource.
+func apiUrl(key string) string {
+	apiUrlsMu.RLock()
+	defer apiUrlsMu.RUnlock()
+	return apiUrls[key]
+}
+
+func currentDiscoveryStatus() DiscoveryStatus {
+	apiUrlsMu.RLock()
+	defer apiUrlsMu.RUnlock()
+	return discoveryState
+}
+
+func fetchApiUrls() (map[string]string, error) {
+	type ApiInfo struct {
+		Artists   string `json:"artists"`
+		Locations string `json:"locations"`
+		Dates     string `json:"dates"`
+		Relations string `json:"relation"`
+	}
+
+	var data_obj ApiInfo
+	if err := sendGetRequest(apiUrl("base"), &data_obj, nil); err != nil {
+		return nil, err
+	}
+
+	urls := map[string]string{
+		"artists":   data_obj.Artists,
+		"locations": data_obj.Locations,
+		"dates":     data_obj.Dates,
+		"relations": data_obj.Relations,
+	}
+	for key, url := range urls {
+		if url == "" {
+			return nil, fmt.Errorf("upstream did not return the %s url", key)
+		}
+	}
+	return urls, nil
+}
+
+// getApiUrls discovers the upstream endpoints, retrying with exponential
+// backoff. If every attempt fails and nothing was discovered before, the
+// default paths under the base url are used instead.
+func getApiUrls() error {
+	backoff := discoveryInitialBackoff
+	var err error
+	for attempt := 1; attempt <= discoveryMaxAttempts; attempt++ {
+		var urls map[string]string
+		urls, err = fetchApiUrls()
+
+		apiUrlsMu.Lock()
+		discoveryState.Attempts++
+		discoveryState.LastAttempt = time.Now()
+		if err == nil {
+			for key, url := range urls {
+				apiUrls[key] = url
+			}
+			discoveryState.Source = discoveryDiscovered
+			discoveryState.LastSuccess = discoveryState.LastAttempt
+			discoveryState.LastError = ""
+			apiUrlsMu.Unlock()
+			log.Printf("upstream discovery succeeded on attempt %d", attempt)
+			return nil
+		}
+		discoveryState.LastError = err.Error()
+		apiUrlsMu.Unlock()
+
+		log.Printf("upstream discovery attempt %d/%d failed: %v", attempt, discoveryMaxAttempts, err)
+		if attempt < discoveryMaxAttempts {
+			time.Sleep(backoff)
+			backoff = min(backoff*2, discoveryMaxBackoff)
+		}
+	}
+
+	apiUrlsMu.Lock()
+	defer apiUrlsMu.Unlock()
+	if discoveryState.Source != discoveryDiscovered {
+		for key, path := range defaultApiPaths {
+			apiUrls[key] = apiUrls["base"] + path
+		}
+		discoveryState.Source = discoveryFallback
+		log.Printf("upstream discovery failed, using default paths under %s", apiUrls["base"])
+	}
+	return err
+}
+
+// watchApiUrls re-runs discovery periodically, and sooner while the
+// server is still running on fallback urls.
+func watchApiUrls() {
+	for {
+		if currentDiscoveryStatus().Source == discoveryDiscovered {
+			time.Sleep(discoveryInterval)
+		} else {
+			time.Sleep(discoveryRetryInterval)
+		}
+		getApiUrls()
+	}
+}
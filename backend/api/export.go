@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"mymain/backend/api/xlsx"
+)
+
+// exportFormat is a file format of ?export=.
+type exportFormat struct {
+	Key         string
+	Label       string
+	ContentType string
+}
+
+// exportFormats are offered in this order on the pages.
+var exportFormats = []exportFormat{
+	{"csv", "CSV", "text/csv; charset=utf-8"},
+	{"jsonl", "JSON Lines", "application/jsonl; charset=utf-8"},
+	{"xlsx", "Excel", xlsx.ContentType},
+}
+
+// listSeparator joins the values of list columns, such as the members, in
+// CSV and Excel cells. JSON Lines keeps them as arrays.
+const listSeparator = "; "
+
+// The columns of the exports. They only ever get new columns at the end, so
+// scripts reading the files keep working.
+var (
+	artistColumns  = []string{"id", "name", "creation_date", "first_album", "members", "member_count", "locations", "concert_count", "origin", "genres", "tags"}
+	concertColumns = []string{"artist_id", "artist_name", "date", "location", "city", "country"}
+)
+
+// exportTable is an export before it is written. Cells are strings, ints or
+// string lists.
+type exportTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]any
+}
+
+// exportLinks links the exports of path with the search and filters of the
+// request. /search links those of /artists.
+func exportLinks(r *http.Request, path string) []listingLink {
+	var links []listingLink
+	for _, format := range exportFormats {
+		values := r.URL.Query()
+		values.Del("page")
+		values.Del("size")
+		values.Set("export", format.Key)
+		links = append(links, listingLink{Label: format.Label, Url: path + "?" + values.Encode()})
+	}
+	return links
+}
+
+// exportedArtists returns the artists passing the search and filters of the
+// query, in its order and without paging.
+func exportedArtists(snapshot *dataSnapshot, query artistQuery, searchText string) []ArtistsData {
+	artists := slices.Clone(snapshot.Artists)
+	for i := range artists {
+		artists[i].LocationsData = snapshot.findLocations(artists[i].Id).Locations
+	}
+	if searchText != "" {
+		artists = searchArtists(artists, searchText)
+	}
+	artists = slices.DeleteFunc(artists, func(artist ArtistsData) bool { return !query.matches(artist) })
+	query.sortArtists(artists, concertCounts(snapshot.Dates))
+	return artists
+}
+
+// artistsTable has one row per artist.
+func artistsTable(snapshot *dataSnapshot, artists []ArtistsData) exportTable {
+	table := exportTable{Name: "artists", Columns: artistColumns}
+	concerts := concertCounts(snapshot.Dates)
+	for _, artist := range artists {
+		var origin string
+		var genres, tags []string
+		if artist.Metadata != nil {
+			origin, genres = artist.Metadata.Origin, artist.Metadata.Genres
+		}
+		for _, tag := range artist.Tags {
+			tags = append(tags, tag.Name)
+		}
+		table.Rows = append(table.Rows, []any{
+			artist.Id, artist.Name, artist.CreationDate, artist.FirstAlbum,
+			artist.Members, len(artist.Members), artist.LocationsData, concerts[artist.Id],
+			origin, genres, tags,
+		})
+	}
+	return table
+}
+
+// concertsTable has one row per concert of the artists, by date.
+func concertsTable(snapshot *dataSnapshot, artists []ArtistsData, name string) exportTable {
+	table := exportTable{Name: name, Columns: concertColumns}
+	for _, concert := range allConcerts(snapshot) {
+		if !slices.ContainsFunc(artists, func(artist ArtistsData) bool { return artist.Id == concert.ArtistId }) {
+			continue
+		}
+		table.Rows = append(table.Rows, []any{
+			concert.ArtistId, concert.ArtistName, concert.Date.Format("2006-01-02"),
+			concert.Location, placeName(concert.City), countryName(concert.Country),
+		})
+	}
+	return table
+}
+
+// exportCell is the text of a cell in CSV and Excel.
+func exportCell(cell any) any {
+	if list, ok := cell.([]string); ok {
+		return strings.Join(list, listSeparator)
+	}
+	return cell
+}
+
+// csvText formats a CSV cell. Spreadsheets run text starting with =, +, -,
+// @, a tab or a carriage return as a formula, so such text, which comes
+// from the upstream and the metadata, is prefixed with a quote. The Excel
+// export needs none: its cells are typed as text.
+func csvText(cell any) string {
+	text, ok := cell.(string)
+	if !ok {
+		return fmt.Sprint(cell)
+	}
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func writeCsv(w io.Writer, table exportTable) error {
+	writer := csv.NewWriter(w)
+	writer.Write(table.Columns)
+	for _, row := range table.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = csvText(exportCell(cell))
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeJsonLines writes one JSON object per row, keys in column order.
+func writeJsonLines(w io.Writer, table exportTable) error {
+	buffered := bufio.NewWriter(w)
+	for _, row := range table.Rows {
+		buffered.WriteByte('{')
+		for i, cell := range row {
+			if list, ok := cell.([]string); ok && list == nil {
+				cell = []string{}
+			}
+			key, _ := json.Marshal(table.Columns[i])
+			value, err := json.Marshal(cell)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				buffered.WriteByte(',')
+			}
+			buffered.Write(key)
+			buffered.WriteByte(':')
+			buffered.Write(value)
+		}
+		buffered.WriteString("}\n")
+	}
+	return buffered.Flush()
+}
+
+func writeXlsx(w io.Writer, table exportTable) error {
+	writer, err := xlsx.NewWriter(w, table.Name)
+	if err != nil {
+		return err
+	}
+	header := make([]any, len(table.Columns))
+	for i, column := range table.Columns {
+		header[i] = column
+	}
+	if err := writer.WriteRow(header); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		cells := make([]any, len(row))
+		for i, cell := range row {
+			cells[i] = exportCell(cell)
+		}
+		if err := writer.WriteRow(cells); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// serveExport answers ?export= on /artists, /locations, /dates and /tours
+// with a file of the artists, or of their concerts, that the search and
+// filters of the request select.
+func serveExport(w http.ResponseWriter, r *http.Request, page string) {
+	key := r.URL.Query().Get("export")
+	index := slices.IndexFunc(exportFormats, func(format exportFormat) bool { return format.Key == key })
+	if index < 0 {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+	format := exportFormats[index]
+	query, err := parseArtistQuery(r.URL.Query())
+	if err != nil {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	artists := exportedArtists(snapshot, query, r.URL.Query().Get("search_text"))
+	table := concertsTable(snapshot, artists, page)
+	if page == "artists" {
+		table = artistsTable(snapshot, artists)
+	}
+
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, page, format.Key))
+	switch format.Key {
+	case "csv":
+		err = writeCsv(w, table)
+	case "jsonl":
+		err = writeJsonLines(w, table)
+	case "xlsx":
+		err = writeXlsx(w, table)
+	}
+	if err != nil {
+		log.Printf("exporting %s as %s: %v", page, format.Key, err)
+	}
+}
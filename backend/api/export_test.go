@@ -0,0 +1,181 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/fakeapi"
+)
+
+func TestExportArtistsCsv(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleArtists, http.MethodGet, "/artists?export=csv&sort=concerts&order=desc&page=2&size=2")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Disposition") != `attachment; filename="artists.csv"` {
+		t.Fatalf("the export returned %d with %v", rr.Code, rr.Header())
+	}
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records[0], artistColumns) {
+		t.Errorf("the header is %q", records[0])
+	}
+	// Every artist in the order of the listing, without paging
+	var names []string
+	for _, record := range records[1:] {
+		names = append(names, record[1])
+	}
+	if want := []string{"Queen", "SOJA", "Scorpions", "Pink Floyd", "Bobby McFerrins", "Motörhead"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("the export lists %q, want %q", names, want)
+	}
+	// Quotes in a member name survive the round trip
+	if soja := records[2]; soja[5] != "8" || !strings.Contains(soja[4], `; Ryan "Byrd" Berty; `) {
+		t.Errorf("SOJA is exported as %q", soja)
+	}
+}
+
+func TestExportFilters(t *testing.T) {
+	newFakeUpstream(t)
+
+	for target, expected := range map[string][]string{
+		"/artists?export=csv&creation_date_start=1970":               {"Bobby McFerrins", "Motörhead", "Queen", "SOJA"},
+		"/artists?export=csv&search_text=mikkey":                     {"Motörhead", "Scorpions"},
+		"/artists?export=csv&members%5B%5D=1&creation_date_end=1990": {"Bobby McFerrins"},
+	} {
+		rr := serve(handleArtists, http.MethodGet, target)
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+		var names []string
+		for _, record := range records[1:] {
+			names = append(names, record[1])
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("%s exports %q, want %q", target, names, expected)
+		}
+	}
+
+	if rr := serve(handleArtists, http.MethodGet, "/artists?export=pdf"); rr.Code != http.StatusBadRequest {
+		t.Errorf("an unknown format returned %d", rr.Code)
+	}
+	if rr := serve(handleArtists, http.MethodGet, "/artists?export=csv&creation_date_start=soon"); rr.Code != http.StatusBadRequest {
+		t.Errorf("a bad filter returned %d", rr.Code)
+	}
+}
+
+func TestExportJsonLines(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleArtists, http.MethodGet, "/artists?export=jsonl&search_text=queen")
+	lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("the export has %d lines: %s", len(lines), rr.Body.String())
+	}
+	// Keys come in the order of the columns
+	if !strings.HasPrefix(lines[0], `{"id":1,"name":"Queen","creation_date":1970,`) || !strings.HasSuffix(lines[0], `"genres":[],"tags":[]}`) {
+		t.Errorf("the line is %s", lines[0])
+	}
+	var artist struct {
+		Members   []string `json:"members"`
+		Locations []string `json:"locations"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &artist); err != nil || len(artist.Members) != 7 || artist.Locations[0] != "nagoya-japan" {
+		t.Errorf("the line reads as %+v: %v", artist, err)
+	}
+}
+
+func TestExportConcerts(t *testing.T) {
+	newFakeUpstream(t)
+
+	for target, handler := range map[string]http.HandlerFunc{
+		"/tours?export=csv&search_text=scorpions":     handleRelations,
+		"/dates?export=csv&search_text=scorpions":     handleDates,
+		"/locations?export=csv&search_text=scorpions": handleLocations,
+	} {
+		records, err := csv.NewReader(serve(handler, http.MethodGet, target).Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+		if !reflect.DeepEqual(records[0], concertColumns) || len(records) != 6 {
+			t.Fatalf("%s exports %q", target, records)
+		}
+		if want := []string{"4", "Scorpions", "2020-05-15", "berlin-germany", "Berlin", "Germany"}; !reflect.DeepEqual(records[1], want) {
+			t.Errorf("%s starts with %q, want %q", target, records[1], want)
+		}
+	}
+}
+
+func TestExportXlsx(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleRelations, http.MethodGet, "/tours?export=xlsx&search_text=queen")
+	if rr.Header().Get("Content-Disposition") != `attachment; filename="tours.xlsx"` {
+		t.Errorf("the export is served as %v", rr.Header())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range archive.File {
+		if file.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		reader, _ := file.Open()
+		sheet, _ := io.ReadAll(reader)
+		if !strings.Contains(string(sheet), `<c r="A9"><v>1</v></c>`) || strings.Contains(string(sheet), `r="A10"`) {
+			t.Errorf("the sheet does not hold the 8 concerts of Queen: %s", sheet)
+		}
+		return
+	}
+	t.Error("the file has no sheet")
+}
+
+func TestExportLinks(t *testing.T) {
+	newFakeUpstream(t)
+
+	body := serve(handleSearch, http.MethodGet, "/search?search_text=mikkey").Body.String()
+	if !strings.Contains(body, `href="/artists?export=csv&amp;search_text=mikkey"`) {
+		t.Error("the search page does not link the export of its artists")
+	}
+	body = serve(handleArtists, http.MethodGet, "/artists?page=2&size=2").Body.String()
+	if !strings.Contains(body, `href="/artists?export=xlsx" download>Excel</a>`) {
+		t.Error("the export links keep the page")
+	}
+}
+
+func TestExportFormulas(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	artists := fakeapi.DefaultArtists()
+	artists[0].Name = "=HYPERLINK(\"https://example.com\")"
+	artists[0].Members = []string{"@SUM(A1)", "-1+2", "Brian May"}
+	upstream.SetArtists(artists[:1])
+
+	records, err := csv.NewReader(serve(handleArtists, http.MethodGet, "/artists?export=csv").Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, members := records[1][1], records[1][4]; name != `'=HYPERLINK("https://example.com")` || !strings.HasPrefix(members, "'@SUM(A1); -1+2") {
+		t.Errorf("formulas are exported as %q and %q", name, members)
+	}
+
+	// Excel cells are text, shown as they are
+	rr := serve(handleArtists, http.MethodGet, "/artists?export=xlsx")
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _ := archive.Open("xl/worksheets/sheet1.xml")
+	sheet, _ := io.ReadAll(reader)
+	if !strings.Contains(string(sheet), `<c r="B2" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;https://example.com&#34;)</t></is></c>`) {
+		t.Errorf("the sheet holds %s", sheet)
+	}
+}
@@ -0,0 +1,373 @@
+// Package fakeapi serves an in-process copy of the Groupie Trackers API for
+// tests, benchmarks and local load testing.
+//
+// A Server answers the same routes as the real upstream (/api, /api/artists,
+// /api/locations, /api/dates, /api/relation and their per-id variants) from
+// a fixed set of fixtures. Errors, malformed bodies and latency can be
+// injected per path, and every request is counted.
+package fakeapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Artist is a fixture artist together with its concerts, keyed by location
+// slug ("osaka-japan") with dates formatted as "dd-mm-yyyy". An Image
+// without scheme ("queen.jpeg") is served by the fake itself.
+type Artist struct {
+	Id           int
+	Image        string
+	Name         string
+	Members      []string
+	CreationDate int
+	FirstAlbum   string
+	Concerts     map[string][]string
+}
+
+type artistJson struct {
+	Id           int      `json:"id"`
+	Image        string   `json:"image"`
+	Name         string   `json:"name"`
+	Members      []string `json:"members"`
+	CreationDate int      `json:"creationDate"`
+	FirstAlbum   string   `json:"firstAlbum"`
+	Locations    string   `json:"locations"`
+	ConcertDates string   `json:"concertDates"`
+	Relations    string   `json:"relations"`
+}
+
+type locationJson struct {
+	Id        int      `json:"id"`
+	Locations []string `json:"locations"`
+	Dates     string   `json:"dates"`
+}
+
+type dateJson struct {
+	Id    int      `json:"id"`
+	Dates []string `json:"dates"`
+}
+
+type relationJson struct {
+	Id             int                 `json:"id"`
+	DatesLocations map[string][]string `json:"datesLocations"`
+}
+
+type injectedError struct {
+	status    int
+	malformed bool
+}
+
+// Server is a running fake upstream. Use URL() as the api base address.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	artists  []Artist
+	errors   map[string]injectedError
+	latency  time.Duration
+	calls    map[string]int
+	numCalls int
+}
+
+// NewServer starts a fake upstream serving the given artists.
+func NewServer(artists []Artist) *Server {
+	s := &Server{
+		artists: artists,
+		errors:  map[string]injectedError{},
+		calls:   map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the base address of the fake api, the equivalent of
+// https://groupietrackers.herokuapp.com/api.
+func (s *Server) URL() string {
+	return s.Server.URL + "/api"
+}
+
+// ApiUrls returns the endpoint urls as they are discovered from the base
+// address, keyed like the servers' apiUrls map.
+func (s *Server) ApiUrls() map[string]string {
+	return map[string]string{
+		"base":      s.URL(),
+		"artists":   s.URL() + "/artists",
+		"locations": s.URL() + "/locations",
+		"dates":     s.URL() + "/dates",
+		"relations": s.URL() + "/relation",
+	}
+}
+
+// SetArtists replaces the served fixtures.
+func (s *Server) SetArtists(artists []Artist) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artists = artists
+}
+
+// FailPath makes every request to path (for example "/api/artists") answer
+// with the given status code.
+func (s *Server) FailPath(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[path] = injectedError{status: status}
+}
+
+// MalformPath makes every request to path answer 200 with a body that is
+// not valid JSON.
+func (s *Server) MalformPath(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[path] = injectedError{malformed: true}
+}
+
+// ClearErrors removes all injected errors.
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = map[string]injectedError{}
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Calls returns how many requests were made to path.
+func (s *Server) Calls(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[path]
+}
+
+// TotalCalls returns how many requests were made to the server.
+func (s *Server) TotalCalls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.numCalls
+}
+
+// ResetCalls sets all call counters back to zero.
+func (s *Server) ResetCalls() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = map[string]int{}
+	s.numCalls = 0
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.calls[r.URL.Path]++
+	s.numCalls++
+	latency := s.latency
+	injected, failing := s.errors[r.URL.Path]
+	artists := s.artists
+	s.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if failing {
+		if injected.malformed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"index": [`))
+			return
+		}
+		http.Error(w, http.StatusText(injected.status), injected.status)
+		return
+	}
+
+	base := s.URL()
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if path == "/api" {
+		writeJson(w, map[string]string{
+			"artists":   base + "/artists",
+			"locations": base + "/locations",
+			"dates":     base + "/dates",
+			"relation":  base + "/relation",
+		})
+		return
+	}
+
+	resource, idText, hasId := strings.Cut(strings.TrimPrefix(path, "/api/"), "/")
+	if resource == "images" && hasId {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(Image(idText))
+		return
+	}
+	if !hasId {
+		switch resource {
+		case "artists":
+			list := make([]artistJson, 0, len(artists))
+			for _, artist := range artists {
+				list = append(list, artistToJson(base, artist))
+			}
+			writeJson(w, list)
+		case "locations":
+			index := make([]locationJson, 0, len(artists))
+			for _, artist := range artists {
+				index = append(index, locationsToJson(base, artist))
+			}
+			writeJson(w, map[string][]locationJson{"index": index})
+		case "dates":
+			index := make([]dateJson, 0, len(artists))
+			for _, artist := range artists {
+				index = append(index, datesToJson(artist))
+			}
+			writeJson(w, map[string][]dateJson{"index": index})
+		case "relation":
+			index := make([]relationJson, 0, len(artists))
+			for _, artist := range artists {
+				index = append(index, relationToJson(artist))
+			}
+			writeJson(w, map[string][]relationJson{"index": index})
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	// Like the real api, unknown ids answer with an empty object
+	var artist Artist
+	for _, candidate := range artists {
+		if candidate.Id == id {
+			artist = candidate
+		}
+	}
+	switch resource {
+	case "artists":
+		if artist.Id == 0 {
+			writeJson(w, artistJson{})
+			return
+		}
+		writeJson(w, artistToJson(base, artist))
+	case "locations":
+		writeJson(w, locationsToJson(base, artist))
+	case "dates":
+		writeJson(w, datesToJson(artist))
+	case "relation":
+		writeJson(w, relationToJson(artist))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Image returns a 600x400 JPEG whose colors are derived from name.
+func Image(name string) []byte {
+	sum := sha256.Sum256([]byte(name))
+	img := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			c := color.RGBA{R: sum[0], G: sum[1], B: sum[2], A: 0xff}
+			if (x/50+y/50)%2 == 0 {
+				c = color.RGBA{R: sum[3], G: sum[4], B: sum[5], A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var out bytes.Buffer
+	jpeg.Encode(&out, img, nil)
+	return out.Bytes()
+}
+
+func writeJson(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func artistToJson(base string, artist Artist) artistJson {
+	id := strconv.Itoa(artist.Id)
+	image := artist.Image
+	if image != "" && !strings.Contains(image, "://") {
+		image = base + "/images/" + image
+	}
+	return artistJson{
+		Id:           artist.Id,
+		Image:        image,
+		Name:         artist.Name,
+		Members:      artist.Members,
+		CreationDate: artist.CreationDate,
+		FirstAlbum:   artist.FirstAlbum,
+		Locations:    base + "/locations/" + id,
+		ConcertDates: base + "/dates/" + id,
+		Relations:    base + "/relation/" + id,
+	}
+}
+
+// sortedLocations orders an artist's locations by their first concert date,
+// which is how the upstream lists them.
+func sortedLocations(artist Artist) []string {
+	locations := make([]string, 0, len(artist.Concerts))
+	for location := range artist.Concerts {
+		locations = append(locations, location)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := dateKey(artist.Concerts[locations[i]][0]), dateKey(artist.Concerts[locations[j]][0])
+		if a != b {
+			return a < b
+		}
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+// dateKey turns "dd-mm-yyyy" into a sortable "yyyymmdd".
+func dateKey(date string) string {
+	parts := strings.Split(date, "-")
+	if len(parts) != 3 {
+		return date
+	}
+	return parts[2] + parts[1] + parts[0]
+}
+
+func locationsToJson(base string, artist Artist) locationJson {
+	location := locationJson{Id: artist.Id, Locations: []string{}}
+	if artist.Id == 0 {
+		return location
+	}
+	location.Locations = sortedLocations(artist)
+	location.Dates = base + "/dates/" + strconv.Itoa(artist.Id)
+	return location
+}
+
+// datesToJson lists the dates grouped by location. As in the upstream data
+// the first date of every location is marked with a leading "*".
+func datesToJson(artist Artist) dateJson {
+	dates := dateJson{Id: artist.Id, Dates: []string{}}
+	for _, location := range sortedLocations(artist) {
+		for i, date := range artist.Concerts[location] {
+			if i == 0 {
+				date = "*" + date
+			}
+			dates.Dates = append(dates.Dates, date)
+		}
+	}
+	return dates
+}
+
+func relationToJson(artist Artist) relationJson {
+	relation := relationJson{Id: artist.Id, DatesLocations: map[string][]string{}}
+	for location, dates := range artist.Concerts {
+		relation.DatesLocations[location] = append([]string(nil), dates...)
+	}
+	return relation
+}
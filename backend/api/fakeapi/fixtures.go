@@ -0,0 +1,96 @@
+package fakeapi
+
+// DefaultArtists returns a small, fixed data set modelled on the real
+// upstream. Some concerts deliberately overlap: Queen and SOJA share a
+// date in Osaka, Queen and Pink Floyd share a date in Los Angeles, Pink
+// Floyd and Scorpions share a date in Berlin, and Mikkey Dee plays in two
+// bands. Images are served by the fake under /api/images/. Every call
+// returns a fresh copy.
+func DefaultArtists() []Artist {
+	return []Artist{
+		{
+			Id:           1,
+			Image:        "queen.jpeg",
+			Name:         "Queen",
+			Members:      []string{"Freddie Mercury", "Brian May", "John Daecon", "Roger Meddows-Taylor", "Mike Grose", "Barry Mitchell", "Doug Fogie"},
+			CreationDate: 1970,
+			FirstAlbum:   "14-12-1973",
+			Concerts: map[string][]string{
+				"dunedin-new_zealand": {"10-02-2020"},
+				"georgia-usa":         {"22-08-2019"},
+				"los_angeles-usa":     {"20-08-2019"},
+				"nagoya-japan":        {"30-01-2019"},
+				"north_carolina-usa":  {"23-08-2019"},
+				"osaka-japan":         {"28-01-2020"},
+				"penrose-new_zealand": {"07-02-2020"},
+				"saitama-japan":       {"26-01-2020"},
+			},
+		},
+		{
+			Id:           2,
+			Image:        "soja.jpeg",
+			Name:         "SOJA",
+			Members:      []string{"Jacob Hemphill", "Bob Jefferson", "Ryan \"Byrd\" Berty", "Ken Bergman", "Patrick O'Shea", "Hellman Escorcia", "Rafael Rodriguez", "Trevor Young"},
+			CreationDate: 1997,
+			FirstAlbum:   "05-06-2002",
+			Concerts: map[string][]string{
+				"los_angeles-usa":         {"21-08-2019"},
+				"new_york-usa":            {"13-10-2019"},
+				"osaka-japan":             {"28-01-2020"},
+				"playa_del_carmen-mexico": {"05-12-2019", "06-12-2019"},
+			},
+		},
+		{
+			Id:           3,
+			Image:        "pinkfloyd.jpeg",
+			Name:         "Pink Floyd",
+			Members:      []string{"Syd Barrett", "David Gilmour", "Roger Waters", "Richard Wright", "Nick Mason"},
+			CreationDate: 1965,
+			FirstAlbum:   "05-08-1967",
+			Concerts: map[string][]string{
+				"berlin-germany":  {"15-05-2020"},
+				"london-uk":       {"21-04-2020"},
+				"los_angeles-usa": {"20-08-2019"},
+				"paris-france":    {"18-05-2020"},
+			},
+		},
+		{
+			Id:           4,
+			Image:        "scorpions.jpeg",
+			Name:         "Scorpions",
+			Members:      []string{"Rudolf Schenker", "Klaus Meine", "Matthias Jabs", "Pawel Maciwoda", "Mikkey Dee"},
+			CreationDate: 1965,
+			FirstAlbum:   "02-09-1972",
+			Concerts: map[string][]string{
+				"berlin-germany":       {"15-05-2020"},
+				"hamburg-germany":      {"10-06-2021", "11-06-2021"},
+				"paris-france":         {"16-05-2020"},
+				"yogyakarta-indonesia": {"17-03-2021"},
+			},
+		},
+		{
+			Id:           5,
+			Image:        "bobbymcferrins.jpeg",
+			Name:         "Bobby McFerrins",
+			Members:      []string{"Bobby McFerrins"},
+			CreationDate: 1977,
+			FirstAlbum:   "01-01-1982",
+			Concerts: map[string][]string{
+				"birmingham-uk": {"02-03-2021"},
+				"london-uk":     {"21-04-2020"},
+			},
+		},
+		{
+			Id:           6,
+			Image:        "motorhead.jpeg",
+			Name:         "Motörhead",
+			Members:      []string{"Lemmy Kilmister", "Phil Campbell", "Mikkey Dee"},
+			CreationDate: 1975,
+			FirstAlbum:   "21-08-1977",
+			Concerts: map[string][]string{
+				"hamburg-germany": {"10-06-2021"},
+				"london-uk":       {"24-11-2019"},
+			},
+		},
+	}
+}
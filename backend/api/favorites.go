@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"mymain/backend/api/favorites"
+	"mymain/backend/api/session"
+)
+
+// sessions signs the session cookie and favoriteStore keeps the starred
+// artists of every session and account. main replaces both with ones kept in
+// the data directory; the defaults only live as long as the process.
+var (
+	sessions                      = session.New(nil)
+	favoriteStore favorites.Store = favorites.NewMemoryStore()
+)
+
+// now is the current time, replaced in tests so upcoming concerts do not
+// depend on the day the tests run.
+var now = time.Now
+
+// favoriteStars tells templates which artists are starred, where the star
+// forms return to and the CSRF token they carry.
+type favoriteStars struct {
+	Ids    []int
+	Return string
+	CSRF   string
+}
+
+// favoriteStar is the star form of one artist.
+type favoriteStar struct {
+	Id     int
+	On     bool
+	Return string
+	CSRF   string
+}
+
+// Star returns the star form of the artist.
+func (s *favoriteStars) Star(id int) favoriteStar {
+	return favoriteStar{Id: id, On: slices.Contains(s.Ids, id), Return: s.Return, CSRF: s.CSRF}
+}
+
+// favoriteArtist is a starred artist with its concerts split around today.
+type favoriteArtist struct {
+	Artist   ArtistsData
+	Upcoming []Concert
+	Past     []Concert
+}
+
+// timelineMonth is the concerts of one month on the timeline.
+type timelineMonth struct {
+	Id       string
+	Label    string
+	Concerts []timelineConcert
+}
+
+// timelineConcert is a concert on the timeline, Favorite when its artist is
+// starred and Upcoming when it has not happened yet.
+type timelineConcert struct {
+	Concert
+	Favorite bool
+	Upcoming bool
+}
+
+// favoriteOwner returns the store key of the visitor: the account when
+// logged in, the session otherwise. ok is false when the visitor has no
+// session and therefore no favorites.
+func favoriteOwner(r *http.Request) (string, bool) {
+	if username, loggedIn := currentUser(r); loggedIn {
+		return "user:" + username, true
+	}
+	id, ok := sessions.Id(r)
+	if !ok {
+		return "", false
+	}
+	return "session:" + id, true
+}
+
+// favoriteIds returns the starred artist ids of the visitor. A failing store
+// is logged and treated as empty so pages still render.
+func favoriteIds(r *http.Request) []int {
+	owner, ok := favoriteOwner(r)
+	if !ok {
+		return nil
+	}
+	ids, err := favoriteStore.List(owner)
+	if err != nil {
+		log.Printf("listing favorites: %v", err)
+		return nil
+	}
+	return ids
+}
+
+// currentStars returns the stars of the visitor for a page served at r.
+func currentStars(r *http.Request) *favoriteStars {
+	return &favoriteStars{Ids: favoriteIds(r), Return: r.URL.RequestURI(), CSRF: csrfToken(r)}
+}
+
+// isUpcoming tells whether the concert is today or later.
+func isUpcoming(concert Concert, today time.Time) bool {
+	return !concert.Date.Before(today)
+}
+
+// startOfDay returns midnight of the day of t, in UTC like concert dates.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// buildFavoriteArtists returns the starred artists in the order they were
+// starred. Ids the data no longer knows are skipped.
+func buildFavoriteArtists(snapshot *dataSnapshot, ids []int, today time.Time) []favoriteArtist {
+	var list []favoriteArtist
+	for _, id := range ids {
+		artist, found := snapshot.findArtist(id)
+		if !found {
+			continue
+		}
+		favorite := favoriteArtist{Artist: artist}
+		for _, concert := range artistConcerts(artist, snapshot.findRelation(id)) {
+			if isUpcoming(concert, today) {
+				favorite.Upcoming = append(favorite.Upcoming, concert)
+			} else {
+				favorite.Past = append(favorite.Past, concert)
+			}
+		}
+		list = append(list, favorite)
+	}
+	return list
+}
+
+// buildTimeline groups the concerts by month in date order, marking the
+// concerts of the starred artists.
+func buildTimeline(concerts []Concert, ids []int, today time.Time) []timelineMonth {
+	var months []timelineMonth
+	for _, concert := range concerts {
+		id := concert.Date.Format("2006-01")
+		if len(months) == 0 || months[len(months)-1].Id != id {
+			months = append(months, timelineMonth{Id: id, Label: concert.Date.Format("January 2006")})
+		}
+		month := &months[len(months)-1]
+		month.Concerts = append(month.Concerts, timelineConcert{
+			Concert:  concert,
+			Favorite: slices.Contains(ids, concert.ArtistId),
+			Upcoming: isUpcoming(concert, today),
+		})
+	}
+	return months
+}
+
+// favoriteUpcoming returns the upcoming concerts of the starred artists in
+// date order.
+func favoriteUpcoming(concerts []Concert, ids []int, today time.Time) []Concert {
+	var upcoming []Concert
+	for _, concert := range concerts {
+		if slices.Contains(ids, concert.ArtistId) && isUpcoming(concert, today) {
+			upcoming = append(upcoming, concert)
+		}
+	}
+	return upcoming
+}
+
+// handleFavorites shows the starred artists on GET and stars or unstars an
+// artist on POST. The form sends artist_id, action (add or remove), the
+// page to return to and the CSRF token; the first star starts the session.
+func handleFavorites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		showFavorites(w, r)
+	case http.MethodPost:
+		updateFavorites(w, r)
+	default:
+		handleErrorPage(w, r, MethodNotAllowedError)
+	}
+}
+
+func updateFavorites(w http.ResponseWriter, r *http.Request) {
+	if !checkCSRF(r) {
+		handleErrorPage(w, r, ForbiddenError)
+		return
+	}
+	id, err := strconv.Atoi(r.PostFormValue("artist_id"))
+	if err != nil || id <= 0 {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+	action := r.PostFormValue("action")
+	if action != "add" && action != "remove" {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	if _, found := snapshot.findArtist(id); !found && action == "add" {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	owner, ok := favoriteOwner(r)
+	if !ok {
+		owner = "session:" + sessions.Ensure(w, r)
+	}
+	if action == "add" {
+		err = favoriteStore.Add(owner, id)
+	} else {
+		err = favoriteStore.Remove(owner, id)
+	}
+	if err != nil {
+		log.Printf("updating favorites: %v", err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, localPath(r.PostFormValue("return"), "/favorites"), http.StatusSeeOther)
+}
+
+func showFavorites(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := parsePage(
+		publicUrl+"favorites.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/favorite_star.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	stars := currentStars(r)
+	today := startOfDay(now())
+
+	templateData := struct {
+		Artists  []favoriteArtist
+		Upcoming []Concert
+		Stars    *favoriteStars
+	}{
+		Artists:  buildFavoriteArtists(snapshot, stars.Ids, today),
+		Upcoming: favoriteUpcoming(allConcerts(snapshot), stars.Ids, today),
+		Stars:    stars,
+	}
+
+	tmpl.Execute(w, templateData)
+}
+
+func handleTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"timeline.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	ids := favoriteIds(r)
+	today := startOfDay(now())
+	concerts := allConcerts(snapshot)
+
+	templateData := struct {
+		HasFavorites bool
+		Upcoming     []Concert
+		Months       []timelineMonth
+	}{
+		HasFavorites: len(ids) > 0,
+		Upcoming:     favoriteUpcoming(concerts, ids, today),
+		Months:       buildTimeline(concerts, ids, today),
+	}
+
+	tmpl.Execute(w, templateData)
+}
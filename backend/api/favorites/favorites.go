@@ -0,0 +1,165 @@
+// Package favorites stores the artists each visitor follows.
+//
+// Stores are keyed by an owner string, the anonymous session id or an
+// account, and hold artist ids in the order they were added. FileStore
+// keeps everything in one JSON file so no database is needed; MemoryStore
+// is meant for tests.
+package favorites
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Store keeps favorite artist ids per owner.
+type Store interface {
+	// List returns the favorite artist ids of owner, oldest first.
+	List(owner string) ([]int, error)
+	// Add marks the artist as favorite. Adding twice is not an error.
+	Add(owner string, artistId int) error
+	// Remove unmarks the artist. Removing a missing favorite is not an
+	// error.
+	Remove(owner string, artistId int) error
+}
+
+// MemoryStore is a Store that lives in memory only.
+type MemoryStore struct {
+	mu        sync.Mutex
+	favorites map[string][]int
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{favorites: map[string][]int{}}
+}
+
+func (s *MemoryStore) List(owner string) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.favorites[owner]), nil
+}
+
+func (s *MemoryStore) Add(owner string, artistId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.favorites[owner] = add(s.favorites[owner], artistId)
+	return nil
+}
+
+func (s *MemoryStore) Remove(owner string, artistId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.favorites[owner] = remove(s.favorites[owner], artistId)
+	if len(s.favorites[owner]) == 0 {
+		delete(s.favorites, owner)
+	}
+	return nil
+}
+
+// FileStore is a Store persisted as a JSON object of owner to ids. The
+// file is read once and rewritten atomically on every change.
+type FileStore struct {
+	path string
+
+	mu        sync.Mutex
+	loaded    bool
+	favorites map[string][]int
+}
+
+// NewFileStore returns a store kept in the file at path. The file is
+// created on the first change.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) List(owner string) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.favorites[owner]), nil
+}
+
+func (s *FileStore) Add(owner string, artistId int) error {
+	return s.update(owner, func(ids []int) []int { return add(ids, artistId) })
+}
+
+func (s *FileStore) Remove(owner string, artistId int) error {
+	return s.update(owner, func(ids []int) []int { return remove(ids, artistId) })
+}
+
+func (s *FileStore) update(owner string, change func([]int) []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	previous := s.favorites[owner]
+	ids := change(slices.Clone(previous))
+	if slices.Equal(ids, previous) {
+		return nil
+	}
+	if len(ids) == 0 {
+		delete(s.favorites, owner)
+	} else {
+		s.favorites[owner] = ids
+	}
+	if err := s.save(); err != nil {
+		// Keep memory and file in agreement
+		s.favorites[owner] = previous
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	s.favorites = map[string][]int{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &s.favorites); err != nil {
+		return err
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *FileStore) save() error {
+	data, err := json.MarshalIndent(s.favorites, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func add(ids []int, id int) []int {
+	if slices.Contains(ids, id) {
+		return ids
+	}
+	return append(ids, id)
+}
+
+func remove(ids []int, id int) []int {
+	return slices.DeleteFunc(ids, func(candidate int) bool { return candidate == id })
+}
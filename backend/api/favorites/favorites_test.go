@@ -0,0 +1,73 @@
+package favorites
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	for _, id := range []int{3, 1, 3, 7} {
+		if err := store.Add("a", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Add("b", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remove("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remove("a", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		owner    string
+		expected []int
+	}{
+		{"a", []int{3, 7}},
+		{"b", []int{2}},
+		{"c", nil},
+	}
+	for _, tc := range testCases {
+		ids, err := store.List(tc.owner)
+		if err != nil || !slices.Equal(ids, tc.expected) {
+			t.Errorf("List(%q) = %v, %v want %v", tc.owner, ids, err, tc.expected)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "favorites.json")
+	testStore(t, NewFileStore(path))
+
+	// A new store reads what the first one wrote
+	ids, err := NewFileStore(path).List("a")
+	if err != nil || !slices.Equal(ids, []int{3, 7}) {
+		t.Errorf("reopened store lists %v, %v", ids, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "favorites.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFileStore(path)
+	if _, err := store.List("a"); err == nil {
+		t.Error("List read a corrupt file without error")
+	}
+	if err := store.Add("a", 1); err == nil {
+		t.Error("Add overwrote a corrupt file")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"mymain/backend/api/favorites"
+	"mymain/backend/api/session"
+)
+
+// useFavorites gives the test its own sessions and favorites and fixes
+// today to 1 January 2020, half way through the fixture concerts.
+func useFavorites(t *testing.T) {
+	t.Helper()
+	savedSessions, savedStore, savedNow := sessions, favoriteStore, now
+	sessions = session.New([]byte("test secret"))
+	favoriteStore = favorites.NewMemoryStore()
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() {
+		sessions, favoriteStore, now = savedSessions, savedStore, savedNow
+	})
+}
+
+// postForm posts the form with the given cookies from a page of the site.
+// The CSRF token of the session is added unless the form has one.
+func postForm(handler http.HandlerFunc, target string, cookies []*http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	form = cloneValues(form)
+	for _, cookie := range cookies {
+		if id, ok := sessions.Verify(cookie.Value); ok && cookie.Name == sessions.CookieName && !form.Has("csrf_token") {
+			form.Set("csrf_token", sessions.CSRFToken(id))
+		}
+	}
+	req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Origin", "http://"+req.Host)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, list := range values {
+		clone[key] = append([]string(nil), list...)
+	}
+	return clone
+}
+
+// postFavorite sends the star form with the given cookies.
+func postFavorite(cookies []*http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	return postForm(handleFavorites, "/favorites", cookies, form)
+}
+
+// serveWithCookies serves a GET request carrying the cookies.
+func serveWithCookies(handler http.HandlerFunc, target string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLocalPath(t *testing.T) {
+	testCases := []struct {
+		target   string
+		expected string
+	}{
+		{"/artists?page=2", "/artists?page=2"},
+		{"/artist/1", "/artist/1"},
+		{"", "/favorites"},
+		{"https://example.com/", "/favorites"},
+		{"//example.com/", "/favorites"},
+		{"/\\example.com/", "/favorites"},
+	}
+	for _, tc := range testCases {
+		if got := localPath(tc.target, "/favorites"); got != tc.expected {
+			t.Errorf("localPath(%q) = %q want %q", tc.target, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildTimeline(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	concerts := allConcerts(snapshot)
+
+	months := buildTimeline(concerts, []int{1}, today)
+	count, favorite, upcoming := 0, 0, 0
+	for i, month := range months {
+		if i > 0 && month.Id <= months[i-1].Id {
+			t.Errorf("month %s follows %s", month.Id, months[i-1].Id)
+		}
+		for _, concert := range month.Concerts {
+			count++
+			if concert.Favorite {
+				favorite++
+			}
+			if concert.Upcoming {
+				upcoming++
+			}
+		}
+	}
+	if count != 26 || favorite != 8 || months[0].Label != "January 2019" {
+		t.Errorf("timeline has %d concerts, %d favorite, starting %s", count, favorite, months[0].Label)
+	}
+	if upcoming == 0 || upcoming == count {
+		t.Errorf("%d of %d concerts are upcoming", upcoming, count)
+	}
+
+	if got := favoriteUpcoming(concerts, []int{1}, today); len(got) != 4 || got[0].DateText() != "26-01-2020" {
+		t.Errorf("upcoming Queen concerts are %+v", got)
+	}
+}
+
+func TestHandleFavorites(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t)
+
+	rr := serve(handleFavorites, "GET", "/favorites")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `id="favorites_empty"`) {
+		t.Fatalf("HandleFavorites returned %v without the empty notice", rr.Code)
+	}
+
+	// The first star starts the session
+	rr = postFavorite(nil, url.Values{"artist_id": {"1"}, "action": {"add"}, "return": {"/artists"}})
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/artists" || len(cookies) != 1 {
+		t.Fatalf("starring returned %v to %q with %d cookies", rr.Code, rr.Header().Get("Location"), len(cookies))
+	}
+	rr = postFavorite(cookies, url.Values{"artist_id": {"6"}, "action": {"add"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/favorites" || len(rr.Result().Cookies()) != 0 {
+		t.Fatalf("second star returned %v to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	body := serveWithCookies(handleFavorites, "/favorites", cookies).Body.String()
+	for _, expected := range []string{`id="favorite_1"`, `id="favorite_6"`, "4 upcoming, 4 past concerts", `id="favorite_upcoming"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("favorites page misses %s", expected)
+		}
+	}
+	if strings.Contains(body, `id="favorite_2"`) {
+		t.Error("favorites page shows an artist that is not starred")
+	}
+	// Other visitors do not see them
+	if body := serve(handleFavorites, "GET", "/favorites").Body.String(); strings.Contains(body, `id="favorite_1"`) {
+		t.Error("favorites leaked to a visitor without session")
+	}
+
+	body = serveWithCookies(handleArtists, "/artists", cookies).Body.String()
+	if !strings.Contains(body, `id="star_1" title="Remove from favorites"`) || !strings.Contains(body, `id="star_2" title="Add to favorites"`) {
+		t.Error("artists page does not show the stars")
+	}
+	body = serveWithCookies(handleArtist, "/artist/6", cookies).Body.String()
+	if !strings.Contains(body, `id="artist_star"`) || !strings.Contains(body, `id="star_6" title="Remove from favorites"`) {
+		t.Error("artist page does not show the star")
+	}
+
+	body = serveWithCookies(handleTimeline, "/timeline", cookies).Body.String()
+	if !strings.Contains(body, `id="timeline_upcoming"`) || strings.Count(body, "timeline-favorite") != 10 {
+		t.Errorf("timeline highlights %d concerts want 10", strings.Count(body, "timeline-favorite"))
+	}
+
+	rr = postFavorite(cookies, url.Values{"artist_id": {"1"}, "action": {"remove"}, "return": {"//example.com"}})
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/favorites" {
+		t.Fatalf("unstarring returned %v to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if body := serveWithCookies(handleFavorites, "/favorites", cookies).Body.String(); strings.Contains(body, `id="favorite_1"`) {
+		t.Error("unstarred artist is still a favorite")
+	}
+
+	testCases := []struct {
+		form         url.Values
+		expectedCode int
+	}{
+		{url.Values{"artist_id": {"abc"}, "action": {"add"}}, http.StatusBadRequest},
+		{url.Values{"artist_id": {"1"}, "action": {"toggle"}}, http.StatusBadRequest},
+		{url.Values{"artist_id": {"99"}, "action": {"add"}}, http.StatusNotFound},
+		{url.Values{"artist_id": {"99"}, "action": {"remove"}}, http.StatusSeeOther},
+		{url.Values{"artist_id": {"2"}, "action": {"add"}, "csrf_token": {"forged"}}, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		if rr := postFavorite(cookies, tc.form); rr.Code != tc.expectedCode {
+			t.Errorf("POST /favorites %v returned %v want %v", tc.form, rr.Code, tc.expectedCode)
+		}
+	}
+	if rr := serve(handleFavorites, "PUT", "/favorites"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /favorites returned %v", rr.Code)
+	}
+	if rr := serve(handleTimeline, "POST", "/timeline"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /timeline returned %v", rr.Code)
+	}
+}
+
+func TestFavoritesFromOtherSites(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t)
+
+	form := url.Values{"artist_id": {"1"}, "action": {"add"}}
+	testCases := []struct {
+		header   http.Header
+		expected int
+	}{
+		{http.Header{"Sec-Fetch-Site": {"same-origin"}}, http.StatusSeeOther},
+		{http.Header{"Sec-Fetch-Site": {"cross-site"}, "Origin": {"http://example.com"}}, http.StatusForbidden},
+		{http.Header{"Origin": {"https://evil.example"}}, http.StatusForbidden},
+		{http.Header{"Origin": {"null"}}, http.StatusForbidden},
+		{http.Header{}, http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		req := httptest.NewRequest("POST", "/favorites", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for key, values := range tc.header {
+			req.Header[key] = values
+		}
+		rr := httptest.NewRecorder()
+		handleFavorites(rr, req)
+		if rr.Code != tc.expected {
+			t.Errorf("starring with %v returned %d want %d", tc.header, rr.Code, tc.expected)
+		}
+		// A refused star does not start a session
+		if cookies := rr.Result().Cookies(); rr.Code == http.StatusForbidden && len(cookies) != 0 {
+			t.Errorf("starring with %v set %d cookies", tc.header, len(cookies))
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mymain/backend/api/datastore"
+)
+
+// seenBucket keeps when every artist and concert first appeared upstream,
+// keyed by seenKey. Entries are never removed, so a concert that is dropped
+// and comes back keeps its first date.
+const seenBucket = "seen"
+
+// feedTag starts the ids of feeds and entries. They are tag URIs, which
+// stay the same whatever address the site is served from.
+const feedTag = "tag:groupie-tracker,2024:"
+
+// maxFeedEntries is the most entries a feed lists.
+const maxFeedEntries = 100
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Id         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+func artistSeenKey(id int) string {
+	return "artist/" + strconv.Itoa(id)
+}
+
+func concertSeenKey(concert Concert) string {
+	return fmt.Sprintf("concert/%d/%s/%s", concert.ArtistId, concert.Location, concert.DateText())
+}
+
+// markSeen records the artists and concerts of the upstream data that were
+// not seen before as first seen at the time of the sync.
+func markSeen(tx *datastore.Tx, upstream *dataSnapshot, at time.Time) error {
+	keys := []string{}
+	for _, artist := range upstream.Artists {
+		keys = append(keys, artistSeenKey(artist.Id))
+	}
+	for _, concert := range allConcerts(upstream) {
+		keys = append(keys, concertSeenKey(concert))
+	}
+	for _, key := range keys {
+		if tx.Get(seenBucket, key) != nil {
+			continue
+		}
+		if _, err := tx.PutJson(seenBucket, key, at); err != nil {
+			return err
+		}
+	}
+	if tx.Get(metaBucket, "imported") == nil {
+		if _, err := tx.PutJson(metaBucket, "imported", at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSeen fills FirstSeen and ImportedAt of the snapshot.
+func readSeen(tx *datastore.Tx, snapshot *dataSnapshot) error {
+	if _, err := tx.GetJson(metaBucket, "imported", &snapshot.ImportedAt); err != nil {
+		return err
+	}
+	snapshot.FirstSeen = map[string]time.Time{}
+	for _, key := range tx.Keys(seenBucket) {
+		var at time.Time
+		if err := json.Unmarshal(tx.Get(seenBucket, key), &at); err != nil {
+			return err
+		}
+		snapshot.FirstSeen[key] = at
+	}
+	return nil
+}
+
+// firstSeen returns when the record with the seen key first appeared, the
+// time of the last sync when that is not known.
+func (s *dataSnapshot) firstSeen(key string) time.Time {
+	if at, found := s.FirstSeen[key]; found {
+		return at
+	}
+	return s.LoadedAt
+}
+
+// siteUrl returns the address the request was sent to, for the absolute
+// links feeds need.
+func siteUrl(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// newAtomFeed starts a feed served at path, with the html page it follows.
+func newAtomFeed(r *http.Request, id string, title string, path string, page string) atomFeed {
+	site := siteUrl(r)
+	return atomFeed{
+		Id:    feedTag + id,
+		Title: title,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: site + path},
+			{Rel: "alternate", Type: "text/html", Href: site + page},
+		},
+		Author: atomAuthor{Name: "Groupie Tracker"},
+	}
+}
+
+// serveFeed writes the feed with its ETag and Last-Modified headers and
+// answers conditional requests with 304 Not Modified.
+func serveFeed(w http.ResponseWriter, r *http.Request, feed atomFeed, updated time.Time) {
+	feed.Updated = atomTime(updated)
+	var body bytes.Buffer
+	body.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&body)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("encoding feed: %v", err)
+		http.Error(w, "encoding the feed failed", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:10])+`"`)
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, "", updated, bytes.NewReader(body.Bytes()))
+}
+
+// concertsFeed selects the upcoming concerts of the artist or country
+// asked for in the query, with the feed describing them. ok is false when
+// the artist or country is unknown.
+func concertsFeed(r *http.Request, snapshot *dataSnapshot) (atomFeed, []Concert, bool) {
+	query := r.URL.Query()
+	concerts := allConcerts(snapshot)
+	feed := newAtomFeed(r, "concerts", "Upcoming concerts", r.URL.RequestURI(), "/timeline")
+
+	switch {
+	case query.Has("artist"):
+		id, err := strconv.Atoi(query.Get("artist"))
+		artist, found := snapshot.findArtist(id)
+		if err != nil || !found {
+			return atomFeed{}, nil, false
+		}
+		feed = newAtomFeed(r, "concerts/artist/"+strconv.Itoa(id), "Upcoming concerts of "+artist.Name, r.URL.RequestURI(), "/artist/"+strconv.Itoa(id))
+		concerts = filterConcerts(concerts, func(concert Concert) bool { return concert.ArtistId == id })
+	case query.Has("country"):
+		country := strings.ToLower(query.Get("country"))
+		concerts = filterConcerts(concerts, func(concert Concert) bool { return concert.Country == country })
+		if len(concerts) == 0 {
+			return atomFeed{}, nil, false
+		}
+		feed = newAtomFeed(r, "concerts/country/"+country, "Upcoming concerts in "+countryName(country), r.URL.RequestURI(), countryUrl(country))
+	}
+
+	today := startOfDay(now())
+	concerts = filterConcerts(concerts, func(concert Concert) bool { return isUpcoming(concert, today) })
+	if len(concerts) > maxFeedEntries {
+		concerts = concerts[:maxFeedEntries]
+	}
+	return feed, concerts, true
+}
+
+func filterConcerts(concerts []Concert, keep func(Concert) bool) []Concert {
+	var kept []Concert
+	for _, concert := range concerts {
+		if keep(concert) {
+			kept = append(kept, concert)
+		}
+	}
+	return kept
+}
+
+// handleConcertsFeed serves the upcoming concerts, soonest first, as an
+// Atom feed: all of them, those of one artist (?artist=id) or those in one
+// country (?country=slug). An entry is updated when its concert first
+// appeared upstream.
+func handleConcertsFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	feed, concerts, ok := concertsFeed(r, snapshot)
+	if !ok {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	site := siteUrl(r)
+	updated := snapshot.ImportedAt
+	for _, concert := range concerts {
+		seen := snapshot.firstSeen(concertSeenKey(concert))
+		if seen.After(updated) {
+			updated = seen
+		}
+		place := locationName(concert.Location)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Id:         feedTag + concertSeenKey(concert),
+			Title:      fmt.Sprintf("%s in %s on %s", concert.ArtistName, place, concert.DateText()),
+			Updated:    atomTime(seen),
+			Link:       atomLink{Rel: "alternate", Type: "text/html", Href: site + "/artist/" + strconv.Itoa(concert.ArtistId)},
+			Categories: []atomCategory{{Term: concert.Country, Label: countryName(concert.Country)}},
+			Summary:    fmt.Sprintf("%s plays %s on %s.", concert.ArtistName, place, concert.Date.Format("Monday 2 January 2006")),
+		})
+	}
+	serveFeed(w, r, feed, updated)
+}
+
+// handleArtistsFeed serves the artists that appeared upstream after the
+// first sync as an Atom feed, newest first.
+func handleArtistsFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+
+	var artists []ArtistsData
+	for _, artist := range snapshot.Artists {
+		if snapshot.firstSeen(artistSeenKey(artist.Id)).After(snapshot.ImportedAt) {
+			artists = append(artists, artist)
+		}
+	}
+	sort.SliceStable(artists, func(i, j int) bool {
+		return snapshot.firstSeen(artistSeenKey(artists[i].Id)).After(snapshot.firstSeen(artistSeenKey(artists[j].Id)))
+	})
+	if len(artists) > maxFeedEntries {
+		artists = artists[:maxFeedEntries]
+	}
+
+	site := siteUrl(r)
+	feed := newAtomFeed(r, "artists", "New artists", r.URL.RequestURI(), "/artists")
+	updated := snapshot.ImportedAt
+	for _, artist := range artists {
+		seen := snapshot.firstSeen(artistSeenKey(artist.Id))
+		if seen.After(updated) {
+			updated = seen
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Id:      feedTag + artistSeenKey(artist.Id),
+			Title:   "New artist: " + artist.Name,
+			Updated: atomTime(seen),
+			Link:    atomLink{Rel: "alternate", Type: "text/html", Href: site + "/artist/" + strconv.Itoa(artist.Id)},
+			Summary: fmt.Sprintf("%s, created in %d, first album %s. Members: %s.", artist.Name, artist.CreationDate, artist.FirstAlbum, strings.Join(artist.Members, ", ")),
+		})
+	}
+	serveFeed(w, r, feed, updated)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/fakeapi"
+)
+
+// getFeed requests the feed with the given extra headers and decodes it
+// when the answer is 200.
+func getFeed(t *testing.T, handler http.HandlerFunc, target string, headers map[string]string) (*httptest.ResponseRecorder, atomFeed) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var feed atomFeed
+	if rr.Code == http.StatusOK {
+		if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+			t.Fatalf("%s is not a valid feed: %v", target, err)
+		}
+	}
+	return rr, feed
+}
+
+func TestConcertsFeed(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t) // upcoming is after 2020-01-01
+
+	rr, feed := getFeed(t, handleConcertsFeed, "/feeds/concerts.atom", nil)
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("global feed returned %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if len(feed.Entries) == 0 || feed.Id != feedTag+"concerts" || feed.Links[0].Href != "http://example.com/feeds/concerts.atom" {
+		t.Fatalf("global feed is %+v", feed)
+	}
+	for _, entry := range feed.Entries {
+		if entry.Updated == "" || !strings.HasPrefix(entry.Id, feedTag+"concert/") || strings.Contains(entry.Title, "2019") {
+			t.Errorf("entry %+v is not an upcoming concert", entry)
+		}
+	}
+
+	testCases := []struct {
+		target       string
+		expectedCode int
+		entries      int
+		firstId      string
+	}{
+		{"/feeds/concerts.atom?artist=1", http.StatusOK, 4, feedTag + "concert/1/saitama-japan/26-01-2020"},
+		{"/feeds/concerts.atom?country=new_zealand", http.StatusOK, 2, feedTag + "concert/1/penrose-new_zealand/07-02-2020"},
+		{"/feeds/concerts.atom?artist=99", http.StatusNotFound, 0, ""},
+		{"/feeds/concerts.atom?artist=queen", http.StatusNotFound, 0, ""},
+		{"/feeds/concerts.atom?country=atlantis", http.StatusNotFound, 0, ""},
+	}
+	for _, tc := range testCases {
+		rr, feed := getFeed(t, handleConcertsFeed, tc.target, nil)
+		if rr.Code != tc.expectedCode || len(feed.Entries) != tc.entries {
+			t.Errorf("%s returned %d with %d entries want %d with %d", tc.target, rr.Code, len(feed.Entries), tc.expectedCode, tc.entries)
+			continue
+		}
+		if tc.entries > 0 && feed.Entries[0].Id != tc.firstId {
+			t.Errorf("%s starts with %s want %s", tc.target, feed.Entries[0].Id, tc.firstId)
+		}
+	}
+
+	if rr := serve(handleConcertsFeed, http.MethodPost, "/feeds/concerts.atom"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST returned %d", rr.Code)
+	}
+}
+
+func TestConcertsFeedConditionalGet(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useFavorites(t)
+
+	rr, before := getFeed(t, handleConcertsFeed, "/feeds/concerts.atom?artist=1", nil)
+	etag, lastModified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("feed has ETag %q and Last-Modified %q", etag, lastModified)
+	}
+	for _, headers := range []map[string]string{{"If-None-Match": etag}, {"If-Modified-Since": lastModified}} {
+		if rr, _ := getFeed(t, handleConcertsFeed, "/feeds/concerts.atom?artist=1", headers); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Errorf("request with %v returned %d", headers, rr.Code)
+		}
+	}
+
+	// A new concert changes the feed; only its entry is updated
+	artists := fakeapi.DefaultArtists()
+	artists[0].Concerts["tokyo-japan"] = []string{"01-03-2020"}
+	upstream.SetArtists(artists)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	rr, after := getFeed(t, handleConcertsFeed, "/feeds/concerts.atom?artist=1", map[string]string{"If-None-Match": etag})
+	if rr.Code != http.StatusOK || len(after.Entries) != 5 {
+		t.Fatalf("changed feed returned %d with %d entries", rr.Code, len(after.Entries))
+	}
+	tokyo := after.Entries[4]
+	if tokyo.Id != feedTag+"concert/1/tokyo-japan/01-03-2020" || tokyo.Updated != after.Updated || after.Entries[0].Updated != before.Entries[0].Updated {
+		t.Errorf("updated times are feed %s, new entry %s %s, old entry %s", after.Updated, tokyo.Id, tokyo.Updated, after.Entries[0].Updated)
+	}
+}
+
+func TestArtistsFeed(t *testing.T) {
+	upstream := newFakeUpstream(t)
+
+	// The artists of the first sync are not new
+	rr, feed := getFeed(t, handleArtistsFeed, "/feeds/artists.atom", nil)
+	if rr.Code != http.StatusOK || len(feed.Entries) != 0 || feed.Title != "New artists" {
+		t.Fatalf("feed after the import returned %d with %d entries", rr.Code, len(feed.Entries))
+	}
+
+	artists := append(fakeapi.DefaultArtists(), fakeapi.Artist{
+		Id:           7,
+		Name:         "Gorillaz",
+		Members:      []string{"Damon Albarn", "Jamie Hewlett"},
+		CreationDate: 1998,
+		FirstAlbum:   "26-03-2001",
+		Concerts:     map[string][]string{"london-uk": {"01-05-2020"}},
+	})
+	upstream.SetArtists(artists)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	rr, feed = getFeed(t, handleArtistsFeed, "/feeds/artists.atom", nil)
+	if rr.Code != http.StatusOK || len(feed.Entries) != 1 {
+		t.Fatalf("feed after a new artist returned %d with %d entries", rr.Code, len(feed.Entries))
+	}
+	entry := feed.Entries[0]
+	if entry.Id != feedTag+"artist/7" || entry.Title != "New artist: Gorillaz" || entry.Link.Href != "http://example.com/artist/7" || entry.Updated != feed.Updated {
+		t.Errorf("new artist entry is %+v in a feed updated %s", entry, feed.Updated)
+	}
+}
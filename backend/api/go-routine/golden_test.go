@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run `go test ./backend/api/go-routine/ -run TestGolden -update` to rewrite the
+// snapshots after an intended template change.
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// goldenDir is relative to the project root, see TestMain.
+const goldenDir = "backend/api/go-routine/testdata/golden"
+
+// normalizeHtml makes rendered pages comparable: whitespace runs collapse to
+// a single space, every tag starts on its own line and the address of the
+// fake upstream, also in its JavaScript escaped form, is replaced by a fixed
+// placeholder.
+func normalizeHtml(body string, upstreamUrl string) string {
+	body = strings.ReplaceAll(body, upstreamUrl, "http://upstream")
+	body = strings.ReplaceAll(body, strings.ReplaceAll(upstreamUrl, "/", `\/`), `http:\/\/upstream`)
+	body = strings.Join(strings.Fields(body), " ")
+	body = strings.ReplaceAll(body, "> <", "><")
+	body = strings.ReplaceAll(body, "><", ">\n<")
+	return body + "\n"
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".golden")
+
+	if *updateGolden {
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			t.Fatalf("creating %s: %v", goldenDir, err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v (run with -update to create it)", path, err)
+	}
+	if got != string(want) {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("%s differs from the rendered page at line %d:\nwant: %s\ngot:  %s\n(run with -update if the change is intended)", path, i+1, wantLines[i], gotLines[i])
+			}
+		}
+		t.Fatalf("%s differs from the rendered page: want %d lines, got %d (run with -update if the change is intended)", path, len(wantLines), len(gotLines))
+	}
+}
+
+func TestGoldenPages(t *testing.T) {
+	testCases := []struct {
+		name         string
+		handler      http.HandlerFunc
+		target       string
+		expectedCode int
+	}{
+		{"index", handleIndex, "/", http.StatusOK},
+		{"artists", handleArtists, "/artists", http.StatusOK},
+		{"artist_1", handleArtist, "/artist/1", http.StatusOK},
+		{"artist_4", handleArtist, "/artist/4", http.StatusOK},
+		{"locations", handleLocations, "/locations", http.StatusOK},
+		{"dates", handleDates, "/dates", http.StatusOK},
+		{"tours", handleRelations, "/tours", http.StatusOK},
+		{"search_scorpions", handleSearch, "/search?search_text=scorpions", http.StatusOK},
+		{"search_no_match", handleSearch, "/search?search_text=nobody", http.StatusNotFound},
+		{"not_found", handleIndex, "/unknown", http.StatusNotFound},
+		{"method_not_allowed", handleArtists, "/artists", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := newFakeUpstream(t)
+
+			method := "GET"
+			if tc.expectedCode == http.StatusMethodNotAllowed {
+				method = "POST"
+			}
+			rr := serve(tc.handler, method, tc.target)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("%s returned %v want %v", tc.target, rr.Code, tc.expectedCode)
+			}
+			// A template that fails half way appends the error page
+			if tc.expectedCode == http.StatusOK && strings.Contains(rr.Body.String(), `class="btnP"`) {
+				t.Fatalf("%s rendered an error page", tc.target)
+			}
+			checkGolden(t, tc.name, normalizeHtml(rr.Body.String(), upstream.Server.URL))
+		})
+	}
+}
+
+func TestGoldenErrorPages(t *testing.T) {
+	for name, errorType := range PredefinedErrors {
+		t.Run(name, func(t *testing.T) {
+			rr := serve(func(w http.ResponseWriter, r *http.Request) {
+				handleErrorPage(w, r, errorType)
+			}, "GET", "/")
+			checkGolden(t, "error_"+errorType.Code, normalizeHtml(rr.Body.String(), "http://upstream"))
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// How often the upstream data snapshot is reloaded and how old it may get
+// before the instance stops reporting itself as ready.
+var (
+	snapshotRefreshInterval = 5 * time.Minute
+	snapshotMaxAge          = 15 * time.Minute
+)
+
+// dataSnapshot holds a complete copy of the upstream API data.
+type dataSnapshot struct {
+	Artists   []ArtistsData
+	Locations LocationsDataLevel1
+	Dates     DatesDataLevel1
+	Relations RelationsDataLevel1
+	LoadedAt  time.Time
+}
+
+var snapshotState struct {
+	sync.RWMutex
+	data        *dataSnapshot
+	lastError   error
+	lastAttempt time.Time
+}
+
+type componentStatus struct {
+	Status string                 `json:"status"`
+	Detail string                 `json:"detail,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+type readinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// loadSnapshot fetches every upstream resource through the worker pool.
+func loadSnapshot() (*dataSnapshot, error) {
+	var snapshot dataSnapshot
+	requests := []RequestTask{
+		{url: apiUrl("artists"), dataObj: &snapshot.Artists},
+		{url: apiUrl("locations"), dataObj: &snapshot.Locations},
+		{url: apiUrl("dates"), dataObj: &snapshot.Dates},
+		{url: apiUrl("relations"), dataObj: &snapshot.Relations},
+	}
+
+	response := make(chan error, len(requests))
+	for _, task := range requests {
+		task.response = response
+		tasks <- task
+	}
+
+	var firstErr error
+	for range requests {
+		if err := <-response; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	snapshot.LoadedAt = time.Now()
+	return &snapshot, nil
+}
+
+// refreshSnapshot reloads the upstream data. On failure the previous
+// snapshot is kept so it can keep serving until it becomes stale.
+func refreshSnapshot() error {
+	snapshot, err := loadSnapshot()
+
+	snapshotState.Lock()
+	defer snapshotState.Unlock()
+	snapshotState.lastAttempt = time.Now()
+	snapshotState.lastError = err
+	if err == nil {
+		snapshotState.data = snapshot
+	}
+	return err
+}
+
+// currentSnapshot returns the loaded snapshot, loading it first if no
+// snapshot has been loaded yet.
+func currentSnapshot() (*dataSnapshot, error) {
+	snapshotState.RLock()
+	snapshot := snapshotState.data
+	snapshotState.RUnlock()
+	if snapshot != nil {
+		return snapshot, nil
+	}
+
+	if err := refreshSnapshot(); err != nil {
+		return nil, err
+	}
+	snapshotState.RLock()
+	defer snapshotState.RUnlock()
+	return snapshotState.data, nil
+}
+
+func watchSnapshot(interval time.Duration) {
+	for {
+		if err := refreshSnapshot(); err != nil {
+			log.Printf("snapshot refresh failed: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func checkUpstreamUrls() componentStatus {
+	discovery := currentDiscoveryStatus()
+	data := map[string]interface{}{
+		"base":      apiUrl("base"),
+		"discovery": discovery,
+	}
+
+	var missing []string
+	for _, key := range []string{"artists", "locations", "dates", "relations"} {
+		if apiUrl(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		data["missing"] = missing
+		return componentStatus{Status: "fail", Detail: "upstream urls not discovered", Data: data}
+	}
+	if discovery.Source == discoveryFallback {
+		return componentStatus{Status: "ok", Detail: "using default upstream paths", Data: data}
+	}
+	return componentStatus{Status: "ok", Data: data}
+}
+
+func checkSnapshot() componentStatus {
+	snapshotState.RLock()
+	defer snapshotState.RUnlock()
+
+	data := map[string]interface{}{}
+	if !snapshotState.lastAttempt.IsZero() {
+		data["last_attempt"] = snapshotState.lastAttempt
+	}
+	if snapshotState.lastError != nil {
+		data["last_error"] = snapshotState.lastError.Error()
+	}
+
+	if snapshotState.data == nil {
+		return componentStatus{Status: "fail", Detail: "snapshot not loaded", Data: data}
+	}
+
+	age := time.Since(snapshotState.data.LoadedAt)
+	data["loaded_at"] = snapshotState.data.LoadedAt
+	data["age_seconds"] = int(age.Seconds())
+	data["artists"] = len(snapshotState.data.Artists)
+	if age > snapshotMaxAge {
+		return componentStatus{Status: "fail", Detail: fmt.Sprintf("snapshot older than %s", snapshotMaxAge), Data: data}
+	}
+	return componentStatus{Status: "ok", Data: data}
+}
+
+func checkWorkerPool() componentStatus {
+	running := int(runningWorkers.Load())
+	data := map[string]interface{}{
+		"size":    workerPoolSize,
+		"running": running,
+		"queued":  len(tasks),
+	}
+	if running < workerPoolSize {
+		return componentStatus{Status: "fail", Detail: fmt.Sprintf("%d of %d workers running", running, workerPoolSize), Data: data}
+	}
+	return componentStatus{Status: "ok", Data: data}
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// handleHealthz reports whether the process is alive. It never touches the
+// upstream API.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "error": MethodNotAllowedError.Info})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the instance can render pages: the upstream
+// urls are known, a fresh data snapshot is loaded and the worker pool is
+// running.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "error": MethodNotAllowedError.Info})
+		return
+	}
+
+	report := readinessReport{
+		Status: "ready",
+		Components: map[string]componentStatus{
+			"upstream_urls": checkUpstreamUrls(),
+			"snapshot":      checkSnapshot(),
+			"worker_pool":   checkWorkerPool(),
+		},
+	}
+
+	status := http.StatusOK
+	for _, component := range report.Components {
+		if component.Status != "ok" {
+			report.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJson(w, status, report)
+}
@@ -6,14 +6,54 @@ import (
 	"html/template"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var publicUrl = "frontend/public/"
 
+// pageFuncs are the functions of the page templates, which are shared with
+// the main server. serves tells whether this server handles a page, so the
+// templates leave out links to the pages only the main server has.
+var pageFuncs = template.FuncMap{
+	"serves": func(path string) bool {
+		switch path {
+		case "/", "/artists", "/artist", "/locations", "/dates", "/tours", "/search":
+			return true
+		}
+		return false
+	},
+}
+
+// mainServerOnly holds what the shared templates show of the features only
+// the main server has, such as tags, favorites and exports. Page data embeds
+// it and leaves it empty, so the templates show none of them.
+type mainServerOnly struct {
+	TagCloud        interface{}
+	MatchingMembers interface{}
+	Listing         interface{}
+	Stars           interface{}
+	Facets          interface{}
+	Exports         interface{}
+	SharedVenues    interface{}
+	Similar         interface{}
+	ConcertFeed     interface{}
+	TourSheet       interface{}
+}
+
+// parsePage parses the files of a page, the page itself first.
+func parsePage(files ...string) (*template.Template, error) {
+	return template.New(filepath.Base(files[0])).Funcs(pageFuncs).ParseFiles(files...)
+}
+
 var apiUrls = map[string]string{
 	"base": "https://groupietrackers.herokuapp.com/api",
 }
@@ -28,6 +68,11 @@ type ArtistsData struct {
 	Locations    string   `json:"locations"`
 	ConcertDates string   `json:"concertDates"`
 	Relations    string   `json:"relations"`
+	// LocationsData is filled from the locations index for the filter form
+	LocationsData []string
+	// Metadata and tags are only merged by the main server
+	Metadata interface{} `json:"metadata,omitempty"`
+	Tags     interface{} `json:"tags,omitempty"`
 }
 
 type LocationsDataLevel2 struct {
@@ -90,15 +135,32 @@ var PredefinedErrors = map[string]ErrorPageData{
 		CodeNumber: http.StatusInternalServerError,
 		Info:       "Internal server error",
 	},
+	"ServiceUnavailableError": {
+		Name:       "ServiceUnavailableError",
+		Code:       strconv.Itoa(http.StatusServiceUnavailable),
+		CodeNumber: http.StatusServiceUnavailable,
+		Info:       "Artist data is temporarily unavailable",
+	},
 }
 
 var (
-	BadRequestError       = PredefinedErrors["BadRequestError"]
-	NotFoundError         = PredefinedErrors["NotFoundError"]
-	MethodNotAllowedError = PredefinedErrors["MethodNotAllowedError"]
-	InternalServerError   = PredefinedErrors["InternalServerError"]
+	BadRequestError         = PredefinedErrors["BadRequestError"]
+	NotFoundError           = PredefinedErrors["NotFoundError"]
+	MethodNotAllowedError   = PredefinedErrors["MethodNotAllowedError"]
+	InternalServerError     = PredefinedErrors["InternalServerError"]
+	ServiceUnavailableError = PredefinedErrors["ServiceUnavailableError"]
 )
 
+// upstreamErrorPage picks the error page for a failed upstream request.
+// While endpoint discovery has not succeeded the upstream is most likely
+// asleep, so the page tells the user to come back later.
+func upstreamErrorPage() ErrorPageData {
+	if currentDiscoveryStatus().Source != discoveryDiscovered {
+		return ServiceUnavailableError
+	}
+	return InternalServerError
+}
+
 type RequestTask struct {
 	url      string
 	dataObj  interface{}
@@ -107,17 +169,26 @@ type RequestTask struct {
 
 var workerPoolSize = 5
 
+// runningWorkers counts the workers currently consuming the tasks channel.
+var runningWorkers atomic.Int32
+
 func worker(wg *sync.WaitGroup, tasks <-chan RequestTask) {
 	defer wg.Done()
+	runningWorkers.Add(1)
+	defer runningWorkers.Add(-1)
 	for task := range tasks {
 		err := sendGetRequest(task.url, task.dataObj, nil)
 		task.response <- err
 	}
 }
 
+// upstreamClient makes the upstream requests. Its timeout keeps a hung
+// upstream from blocking the workers.
+var upstreamClient = &http.Client{Timeout: 15 * time.Second}
+
 func sendGetRequest(url string, dataObj interface{}, client *http.Client) error {
 	if client == nil {
-		client = http.DefaultClient
+		client = upstreamClient
 	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -129,6 +200,9 @@ func sendGetRequest(url string, dataObj interface{}, client *http.Client) error
 		return err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, res.Status)
+	}
 	body, readErr := ioutil.ReadAll(res.Body)
 	if readErr != nil {
 		return readErr
@@ -141,31 +215,14 @@ func sendGetRequest(url string, dataObj interface{}, client *http.Client) error
 	return nil
 }
 
-func getApiUrls() {
-	type ApiInfo struct {
-		Artists   string `json:"artists"`
-		Locations string `json:"locations"`
-		Dates     string `json:"dates"`
-		Relations string `json:"relation"`
-	}
-
-	var dataObj ApiInfo
-	sendGetRequest(apiUrls["base"], &dataObj, nil)
-
-	apiUrls["artists"] = dataObj.Artists
-	apiUrls["dates"] = dataObj.Dates
-	apiUrls["locations"] = dataObj.Locations
-	apiUrls["relations"] = dataObj.Relations
-}
-
 func generateUrl(path string, desiredUrl string) (string, string, string) {
 	var url string
 	if path == "/"+desiredUrl {
-		url = apiUrls[desiredUrl]
+		url = apiUrl(desiredUrl)
 		return url, "", ""
 	} else if strings.HasPrefix(path, "/"+desiredUrl+"/") {
 		id := strings.TrimPrefix(path, "/"+desiredUrl+"/")
-		url = apiUrls[desiredUrl+"s"]
+		url = apiUrl(desiredUrl + "s")
 		return url, id, ""
 	} else {
 		return "", "", "not found"
@@ -183,7 +240,7 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"index.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
@@ -197,15 +254,18 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 
 	var dataObj []ArtistsData
 	response := make(chan error)
-	task := RequestTask{url: apiUrls["artists"], dataObj: &dataObj, response: response}
+	task := RequestTask{url: apiUrl("artists"), dataObj: &dataObj, response: response}
 	tasks <- task
 	err = <-response
 	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
-	tmpl.Execute(w, dataObj)
+	tmpl.Execute(w, struct {
+		Artists []ArtistsData
+		mainServerOnly
+	}{Artists: dataObj})
 }
 
 func handleArtists(w http.ResponseWriter, r *http.Request) {
@@ -220,12 +280,14 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"artists.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/artist_filter.html",
+		publicUrl+"templates/favorite_star.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -233,15 +295,18 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Fetch artists and locations in parallel through the worker pool
 	var dataObjArray []ArtistsData
-	response := make(chan error)
-	task := RequestTask{url: url, dataObj: &dataObjArray, response: response}
-	tasks <- task
-	err = <-response
-	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+	var locationsData LocationsDataLevel1
+	response := make(chan error, 2)
+	tasks <- RequestTask{url: url, dataObj: &dataObjArray, response: response}
+	tasks <- RequestTask{url: apiUrl("locations"), dataObj: &locationsData, response: response}
+	err1, err2 := <-response, <-response
+	if err1 != nil || err2 != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
+	uniqueLocations := attachLocations(dataObjArray, locationsData)
 
 	// Marshal JSON for the template
 	jsonData, err := json.Marshal(dataObjArray)
@@ -249,16 +314,24 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 		handleErrorPage(w, r, InternalServerError)
 		return
 	}
+	uniqueLocationsData, err := json.Marshal(uniqueLocations)
+	if err != nil {
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
 
 	// Prepare structured data for the template
 	type ArtistsDataForPass struct {
-		Artists         []ArtistsData
-		ArtistsJsonData string
+		Artists             []ArtistsData
+		ArtistsJsonData     string
+		UniqueLocationsData string
+		mainServerOnly
 	}
 
 	dataObjSender := ArtistsDataForPass{
-		Artists:         dataObjArray,
-		ArtistsJsonData: string(jsonData),
+		Artists:             dataObjArray,
+		ArtistsJsonData:     string(jsonData),
+		UniqueLocationsData: string(uniqueLocationsData),
 	}
 
 	// Execute template with structured data
@@ -269,6 +342,25 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// attachLocations copies every artist's concert locations into
+// LocationsData and returns the distinct locations in order of appearance.
+func attachLocations(artists []ArtistsData, locationsData LocationsDataLevel1) []string {
+	uniqueLocations := []string{}
+	for _, locations := range locationsData.Index {
+		for _, location := range locations.Locations {
+			if !slices.Contains(uniqueLocations, location) {
+				uniqueLocations = append(uniqueLocations, location)
+			}
+		}
+		for i := range artists {
+			if artists[i].Id == locations.Id {
+				artists[i].LocationsData = locations.Locations
+			}
+		}
+	}
+	return uniqueLocations
+}
+
 func handleArtist(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		handleErrorPage(w, r, MethodNotAllowedError)
@@ -280,8 +372,12 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 		handleErrorPage(w, r, NotFoundError)
 		return
 	}
+	if _, err := strconv.Atoi(id); err != nil {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"artist.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
@@ -289,6 +385,9 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 		publicUrl+"templates/artist_dates.html",
 		publicUrl+"templates/artist_locations.html",
 		publicUrl+"templates/artist_relation.html",
+		publicUrl+"templates/artist_shared.html",
+		publicUrl+"templates/artist_similar.html",
+		publicUrl+"templates/favorite_star.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -306,7 +405,12 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 	tasks <- task
 	err = <-response
 	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	// The api answers unknown ids with an empty artist
+	if dataObj.Id == 0 {
+		handleErrorPage(w, r, NotFoundError)
 		return
 	}
 
@@ -316,7 +420,7 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 	tasks <- task
 	err = <-response
 	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
@@ -325,7 +429,7 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 	tasks <- task
 	err = <-response
 	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
@@ -334,7 +438,7 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 	tasks <- task
 	err = <-response
 	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
@@ -344,6 +448,7 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 		ArtistDates     DatesDataLevel2
 		ArtistLocations LocationsDataLevel2
 		Relation        RelationsDataLevel2
+		mainServerOnly
 	}{
 		ArtistInfo:      dataObj,
 		ArtistDates:     dateDataObj,
@@ -373,12 +478,13 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse the template files
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"locations.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/locations_list.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -393,7 +499,7 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch artists data asynchronously
 	go func() {
-		err := sendGetRequest(apiUrls["artists"], &artistsData, nil)
+		err := sendGetRequest(apiUrl("artists"), &artistsData, nil)
 		errCh <- err
 	}()
 
@@ -410,7 +516,7 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 
 	// Check if there were any errors
 	if err1 != nil || err2 != nil {
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
@@ -418,6 +524,7 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 	templateData := struct {
 		ArtistsData   []ArtistsData
 		LocationsData LocationsDataLevel1
+		mainServerOnly
 	}{
 		ArtistsData:   artistsData,
 		LocationsData: locationsData,
@@ -443,12 +550,13 @@ func handleDates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"dates.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/dates_list.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -464,7 +572,7 @@ func handleDates(w http.ResponseWriter, r *http.Request) {
 	// Goroutine for fetching artists data
 	go func() {
 		var dataObj []ArtistsData
-		err := sendGetRequest(apiUrls["artists"], &dataObj, nil)
+		err := sendGetRequest(apiUrl("artists"), &dataObj, nil)
 		if err != nil {
 			errorChannel <- err // Use err here
 			return
@@ -490,14 +598,14 @@ func handleDates(w http.ResponseWriter, r *http.Request) {
 	select {
 	case artistsData = <-artistsDataChannel:
 	case err = <-errorChannel:
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
 	select {
 	case datesData = <-datesDataChannel:
 	case err = <-errorChannel:
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
@@ -505,6 +613,7 @@ func handleDates(w http.ResponseWriter, r *http.Request) {
 	templateData := struct {
 		ArtistsData []ArtistsData
 		DatesData   DatesDataLevel1
+		mainServerOnly
 	}{
 		ArtistsData: artistsData,
 		DatesData:   datesData,
@@ -519,12 +628,13 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"relations.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/relations_list.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -540,7 +650,7 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 	// Goroutine for fetching artists data
 	go func() {
 		var dataObj []ArtistsData
-		err := sendGetRequest(apiUrls["artists"], &dataObj, nil)
+		err := sendGetRequest(apiUrl("artists"), &dataObj, nil)
 		if err != nil {
 			errorChannel <- err // Use err here
 			return
@@ -551,7 +661,7 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 	// Goroutine for fetching relations data
 	go func() {
 		var dataObj RelationsDataLevel1
-		err := sendGetRequest(apiUrls["relations"], &dataObj, nil)
+		err := sendGetRequest(apiUrl("relations"), &dataObj, nil)
 		if err != nil {
 			errorChannel <- err // Use err here
 			return
@@ -566,14 +676,14 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 	select {
 	case artistsData = <-artistsDataChannel:
 	case err = <-errorChannel:
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
 	select {
 	case relationsData = <-relationsDataChannel:
 	case err = <-errorChannel:
-		handleErrorPage(w, r, InternalServerError)
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
 
@@ -581,6 +691,7 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 	templateData := struct {
 		ArtistsData   []ArtistsData
 		RelationsData RelationsDataLevel1
+		mainServerOnly
 	}{
 		ArtistsData:   artistsData,
 		RelationsData: relationsData,
@@ -597,16 +708,18 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	searchText := r.URL.Query().Get("search_text")
 	if searchText == "" {
-		handleIndex(w, r) // Show the index page if no search term is provided.
+		http.Redirect(w, r, "/", http.StatusSeeOther) // Show the index page if no search term is provided.
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"artists.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/artist_filter.html", // Added missing template
+		publicUrl+"templates/favorite_star.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -616,14 +729,16 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	// Use a channel to fetch data
 	var artistsData []ArtistsData
-	response := make(chan error)
-	task := RequestTask{url: apiUrls["artists"], dataObj: &artistsData, response: response}
-	tasks <- task
-	err = <-response
-	if err != nil {
-		handleErrorPage(w, r, InternalServerError)
+	var locationsData LocationsDataLevel1
+	response := make(chan error, 2)
+	tasks <- RequestTask{url: apiUrl("artists"), dataObj: &artistsData, response: response}
+	tasks <- RequestTask{url: apiUrl("locations"), dataObj: &locationsData, response: response}
+	err1, err2 := <-response, <-response
+	if err1 != nil || err2 != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
 		return
 	}
+	uniqueLocations := attachLocations(artistsData, locationsData)
 
 	// Filter the data
 	var filteredData []ArtistsData
@@ -645,16 +760,24 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 		handleErrorPage(w, r, InternalServerError)
 		return
 	}
+	uniqueLocationsData, err := json.Marshal(uniqueLocations)
+	if err != nil {
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
 
 	// Prepare structured data for template
 	type ArtistsDataForPass struct {
-		Artists         []ArtistsData
-		ArtistsJsonData string
+		Artists             []ArtistsData
+		ArtistsJsonData     string
+		UniqueLocationsData string
+		mainServerOnly
 	}
 
 	dataObjSender := ArtistsDataForPass{
-		Artists:         filteredData,
-		ArtistsJsonData: string(jsonData),
+		Artists:             filteredData,
+		ArtistsJsonData:     string(jsonData),
+		UniqueLocationsData: string(uniqueLocationsData),
 	}
 
 	// Execute the template
@@ -666,12 +789,7 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleErrorPage(w http.ResponseWriter, r *http.Request, errorPageData ErrorPageData) {
-	tmpl, err := template.ParseFiles(
-		publicUrl+"errors.html",
-		publicUrl+"templates/header.html",
-		publicUrl+"templates/menu.html",
-		publicUrl+"templates/footer.html",
-	)
+	tmpl, err := template.ParseFiles("frontend/errors/error.html")
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -689,6 +807,7 @@ func main() {
 
 	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./frontend/public/static"))))
 	http.Handle("/img/", http.StripPrefix("/img/", http.FileServer(http.Dir("./frontend/public/img"))))
+	http.Handle("/img/artist/", artistImages)
 
 	tasks = make(chan RequestTask, workerPoolSize)
 
@@ -698,7 +817,17 @@ func main() {
 		go worker(&wg, tasks)
 	}
 
-	getApiUrls()
+	// The upstream, listen address and cache directory can be overridden
+	if base := os.Getenv("GROUPIE_API_BASE"); base != "" {
+		apiUrls["base"] = base
+	}
+	addr := ":8082"
+	if envAddr := os.Getenv("GROUPIE_ADDR"); envAddr != "" {
+		addr = envAddr
+	}
+	if cacheDir := os.Getenv("GROUPIE_CACHE_DIR"); cacheDir != "" {
+		artistImages.Dir = cacheDir + "/images"
+	}
 
 	http.HandleFunc("/", handleIndex)
 	http.HandleFunc("/artists", handleArtists)
@@ -707,10 +836,23 @@ func main() {
 	http.HandleFunc("/dates", handleDates)
 	http.HandleFunc("/tours", handleRelations)
 	http.HandleFunc("/search", handleSearch)
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz)
 
 	// Start the server on port 8082
-	fmt.Println("Starting server on 0.0.0.0:8082")
-	log.Fatal(http.ListenAndServe(":8082", nil))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Starting server on " + addr)
+	// Discovery keeps retrying while the upstream is asleep, so it runs
+	// once the server listens and /healthz can answer meanwhile
+	go func() {
+		getApiUrls()
+		go watchApiUrls()
+		watchSnapshot(snapshotRefreshInterval)
+	}()
+	log.Fatal(http.Serve(listener, nil))
 
 	close(tasks)
 	wg.Wait()
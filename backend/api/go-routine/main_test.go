@@ -0,0 +1,516 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mymain/backend/api/fakeapi"
+)
+
+// newFakeUpstream starts a fake Groupie API serving the default fixtures and
+// points the server at it for the duration of the test.
+func newFakeUpstream(t testing.TB) *fakeapi.Server {
+	t.Helper()
+	upstream := fakeapi.NewServer(fakeapi.DefaultArtists())
+
+	savedUrls, savedState := apiUrls, discoveryState
+	apiUrls = upstream.ApiUrls()
+	discoveryState = DiscoveryStatus{Source: discoveryDiscovered}
+	snapshotState.data = nil
+
+	t.Cleanup(func() {
+		upstream.Close()
+		apiUrls, discoveryState = savedUrls, savedState
+		snapshotState.data = nil
+	})
+	return upstream
+}
+
+func serve(handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+	return rr
+}
+
+func TestHandleIndex(t *testing.T) {
+	newFakeUpstream(t)
+
+	// Test for GET request
+	log.Println("Starting TestHandleIndex - GET request")
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		log.Fatalf("Failed to create GET request: %v", err)
+	}
+
+	// Create a response recorder to capture the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(handleIndex)
+
+	// Perform the GET request
+	handler.ServeHTTP(rr, req)
+
+	// Check the status code
+	if status := rr.Code; status != http.StatusOK {
+		log.Printf("TestHandleIndex - GET request: Expected status %v, got %v", http.StatusOK, status)
+		t.Errorf("HandleIndex returned wrong status code: got %v want %v", status, http.StatusOK)
+	} else {
+		log.Printf("TestHandleIndex - GET request: Received status %v, as expected", status)
+	}
+
+	// Check if the response contains the correct content (assuming the HTML file is served)
+	expected := `<p class="col-md-4 mb-0 text-body-secondary">© 2024 GT by PR & MR</p>` // Add a unique identifier that would appear in the HTML
+
+	if !strings.Contains(rr.Body.String(), expected) {
+		log.Printf("TestHandleIndex - GET request: Expected content not found in response body")
+		t.Errorf("HandleIndex returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	} else {
+		log.Printf("TestHandleIndex - GET request: Correct content found in response body")
+	}
+
+	if !strings.Contains(rr.Body.String(), `id="artist-p-4">Scorpions</p>`) {
+		t.Errorf("HandleIndex did not render the artists swiper")
+	}
+
+	// Test for POST request (Should return Method Not Allowed)
+	req, err = http.NewRequest("POST", "/", nil)
+	if err != nil {
+		log.Fatalf("Failed to create POST request: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		log.Printf("TestHandleIndex - POST request: Expected status %v, got %v", http.StatusMethodNotAllowed, status)
+		t.Errorf("HandleIndex returned wrong status code for POST: got %v want %v", status, http.StatusMethodNotAllowed)
+	} else {
+		log.Printf("TestHandleIndex - POST request: Received status %v, as expected", status)
+	}
+
+	// Unknown paths fall through to the index handler
+	if rr := serve(handleIndex, "GET", "/unknown"); rr.Code != http.StatusNotFound {
+		t.Errorf("HandleIndex returned wrong status code for unknown path: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleArtists(t *testing.T) {
+	newFakeUpstream(t)
+
+	// Test for GET request
+	log.Println("Starting TestHandleArtists - GET request")
+	req, err := http.NewRequest("GET", "/artists", nil)
+	if err != nil {
+		t.Fatalf("Failed to create GET request: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(handleArtists)
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("HandleArtists returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// Check if the response contains the correct content, the full markup
+	// is covered by the golden files
+	expected := `<h5 class="card-title mb-3">Queen</h5>`
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("HandleArtists returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	// The filter form needs the locations of every artist
+	if !strings.Contains(rr.Body.String(), `yogyakarta-indonesia`) {
+		t.Errorf("HandleArtists did not pass the unique locations to the filter")
+	}
+
+	// Test for POST request (Should return Method Not Allowed)
+	req, err = http.NewRequest("POST", "/artists", nil) // Correct route for POST request
+	if err != nil {
+		t.Fatalf("Failed to create POST request: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("HandleArtists returned wrong status code for POST: got %v want %v", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandleArtist(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleArtist, "GET", "/artist/3")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HandleArtist returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	for _, expected := range []string{"Pink Floyd", "Syd Barrett", "1965", "05-08-1967", "berlin-germany", "*15-05-2020"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("HandleArtist body does not contain %q", expected)
+		}
+	}
+
+	testCases := []struct {
+		name         string
+		target       string
+		expectedCode int
+	}{
+		{"unknown id", "/artist/99", http.StatusNotFound},
+		{"invalid id", "/artist/abc", http.StatusNotFound},
+		{"wrong prefix", "/artists/1", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if rr := serve(handleArtist, "GET", tc.target); rr.Code != tc.expectedCode {
+				t.Errorf("HandleArtist(%s) returned %v want %v", tc.target, rr.Code, tc.expectedCode)
+			}
+		})
+	}
+}
+
+func TestArtistImages(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	artistImages.Dir = t.TempDir()
+
+	rr := serve(artistImages.ServeHTTP, "GET", "/img/artist/4?size=card")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("artist image returned %v with headers %v", rr.Code, rr.Header())
+	}
+	if calls := upstream.Calls("/api/images/scorpions.jpeg"); calls != 1 {
+		t.Errorf("upstream image fetched %d times, want 1", calls)
+	}
+	if rr := serve(artistImages.ServeHTTP, "GET", "/img/artist/99"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown artist image returned %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleListPages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		target   string
+		expected []string
+	}{
+		{"locations", handleLocations, "/locations", []string{"Artist name: Queen", "playa_del_carmen-mexico"}},
+		{"dates", handleDates, "/dates", []string{"Artist name: SOJA", "*05-12-2019", "06-12-2019"}},
+		{"tours", handleRelations, "/tours", []string{"Artist name: Motörhead", "hamburg-germany", "24-11-2019"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newFakeUpstream(t)
+
+			rr := serve(tc.handler, "GET", tc.target)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s returned wrong status code: got %v want %v", tc.target, rr.Code, http.StatusOK)
+			}
+			for _, expected := range tc.expected {
+				if !strings.Contains(rr.Body.String(), expected) {
+					t.Errorf("%s body does not contain %q", tc.target, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	newFakeUpstream(t)
+
+	testCases := []struct {
+		name         string
+		query        string
+		expectedCode int
+		found        []string
+		notFound     []string
+	}{
+		{"by name", "floyd", http.StatusOK, []string{"Pink Floyd"}, []string{"Scorpions"}},
+		{"ignores case", "SCORPIONS", http.StatusOK, []string{"Scorpions"}, []string{"Queen"}},
+		{"name only", "mikkey", http.StatusNotFound, nil, nil},
+		{"no match", "nobody", http.StatusNotFound, nil, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(handleSearch, "GET", "/search?search_text="+tc.query)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("HandleSearch(%q) returned %v want %v", tc.query, rr.Code, tc.expectedCode)
+			}
+			for _, name := range tc.found {
+				if !strings.Contains(rr.Body.String(), `card-title mb-3">`+name+`<`) {
+					t.Errorf("HandleSearch(%q) should find %s", tc.query, name)
+				}
+			}
+			for _, name := range tc.notFound {
+				if strings.Contains(rr.Body.String(), `card-title mb-3">`+name+`<`) {
+					t.Errorf("HandleSearch(%q) should not find %s", tc.query, name)
+				}
+			}
+		})
+	}
+
+	// Searching for nothing goes back to the index page
+	rr := serve(handleSearch, "GET", "/search?search_text=")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/" {
+		t.Errorf("HandleSearch without text: got %v to %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestHandlersMethodNotAllowed(t *testing.T) {
+	newFakeUpstream(t)
+
+	handlers := map[string]http.HandlerFunc{
+		"/":                         handleIndex,
+		"/artists":                  handleArtists,
+		"/artist/1":                 handleArtist,
+		"/locations":                handleLocations,
+		"/dates":                    handleDates,
+		"/tours":                    handleRelations,
+		"/search?search_text=queen": handleSearch,
+	}
+	for target, handler := range handlers {
+		for _, method := range []string{"POST", "PUT", "DELETE"} {
+			if rr := serve(handler, method, target); rr.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s returned %v want %v", method, target, rr.Code, http.StatusMethodNotAllowed)
+			}
+		}
+	}
+}
+
+func TestHandlersUpstreamErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		path    string
+	}{
+		{"index", handleIndex, "/", "/api/artists"},
+		{"artists", handleArtists, "/artists", "/api/artists"},
+		{"artists locations", handleArtists, "/artists", "/api/locations"},
+		{"artist", handleArtist, "/artist/1", "/api/artists/1"},
+		{"artist relation", handleArtist, "/artist/1", "/api/relation/1"},
+		{"locations", handleLocations, "/locations", "/api/locations"},
+		{"dates", handleDates, "/dates", "/api/dates"},
+		{"tours", handleRelations, "/tours", "/api/relation"},
+		{"search", handleSearch, "/search?search_text=queen", "/api/artists"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := newFakeUpstream(t)
+
+			upstream.FailPath(tc.path, http.StatusBadGateway)
+			if rr := serve(tc.handler, "GET", tc.target); rr.Code != http.StatusInternalServerError {
+				t.Errorf("%s with failing %s returned %v want %v", tc.target, tc.path, rr.Code, http.StatusInternalServerError)
+			}
+
+			upstream.MalformPath(tc.path)
+			if rr := serve(tc.handler, "GET", tc.target); rr.Code != http.StatusInternalServerError {
+				t.Errorf("%s with malformed %s returned %v want %v", tc.target, tc.path, rr.Code, http.StatusInternalServerError)
+			}
+
+			// While discovery runs on fallback urls the upstream is reported as unavailable
+			upstream.FailPath(tc.path, http.StatusServiceUnavailable)
+			discoveryState.Source = discoveryFallback
+			if rr := serve(tc.handler, "GET", tc.target); rr.Code != http.StatusServiceUnavailable {
+				t.Errorf("%s on fallback urls returned %v want %v", tc.target, rr.Code, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+func TestHandlersEmptyData(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	upstream.SetArtists(nil)
+
+	for _, target := range []string{"/", "/artists", "/locations", "/dates", "/tours"} {
+		handler := map[string]http.HandlerFunc{
+			"/":          handleIndex,
+			"/artists":   handleArtists,
+			"/locations": handleLocations,
+			"/dates":     handleDates,
+			"/tours":     handleRelations,
+		}[target]
+		if rr := serve(handler, "GET", target); rr.Code != http.StatusOK {
+			t.Errorf("%s with no artists returned %v want %v", target, rr.Code, http.StatusOK)
+		}
+	}
+
+	if rr := serve(handleSearch, "GET", "/search?search_text=queen"); rr.Code != http.StatusNotFound {
+		t.Errorf("search with no artists returned %v want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := serve(handleArtist, "GET", "/artist/1"); rr.Code != http.StatusNotFound {
+		t.Errorf("artist with no artists returned %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleErrorPage(t *testing.T) {
+	testCases := []struct {
+		name         string
+		errorType    ErrorPageData
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Test 404 Not Found",
+			errorType:    NotFoundError,
+			expectedCode: http.StatusNotFound,
+			expectedBody: "Page not found",
+		},
+		{
+			name:         "Test 400 Bad Request",
+			errorType:    BadRequestError,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Bad request",
+		},
+		{
+			name:         "Test 500 Internal Server Error",
+			errorType:    InternalServerError,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Internal server error",
+		},
+		{
+			name:         "Test 503 Service Unavailable",
+			errorType:    ServiceUnavailableError,
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: "Artist data is temporarily unavailable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+
+			handleErrorPage(w, req, tc.errorType)
+
+			// get result
+			res := w.Result()
+			defer res.Body.Close()
+
+			// check http status code
+			if res.StatusCode != tc.expectedCode {
+				t.Errorf("Expected status code %d, but got %d", tc.expectedCode, res.StatusCode)
+			}
+
+			// check response content
+			body := w.Body.String()
+			if !strings.Contains(body, tc.expectedBody) {
+				t.Errorf("Expected body to contain '%s', but got '%s'", tc.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestHandleHealthz(t *testing.T) {
+	rr := serve(handleHealthz, "GET", "/healthz")
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("HandleHealthz returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `"status":"ok"`) {
+		t.Errorf("HandleHealthz returned unexpected body: %v", rr.Body.String())
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	newFakeUpstream(t)
+
+	// No snapshot loaded yet
+	rr := serve(handleReadyz, "GET", "/readyz")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("HandleReadyz without snapshot: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	// Fresh snapshot
+	if err := refreshSnapshot(); err != nil {
+		t.Fatalf("refreshSnapshot failed: %v", err)
+	}
+	rr = serve(handleReadyz, "GET", "/readyz")
+	if rr.Code != http.StatusOK {
+		t.Errorf("HandleReadyz with snapshot: got %v want %v, body %v", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	// Stopped workers
+	runningWorkers.Add(-1)
+	rr = serve(handleReadyz, "GET", "/readyz")
+	runningWorkers.Add(1)
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), `"worker_pool":{"status":"fail"`) {
+		t.Errorf("HandleReadyz with stopped worker: got %v, body %v", rr.Code, rr.Body.String())
+	}
+
+	// Stale snapshot
+	snapshotState.data.LoadedAt = time.Now().Add(-2 * snapshotMaxAge)
+	rr = serve(handleReadyz, "GET", "/readyz")
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "snapshot older than") {
+		t.Errorf("HandleReadyz with stale snapshot: got %v, body %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGetApiUrls(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	upstream.FailPath("/api", http.StatusServiceUnavailable)
+
+	savedBackoff := discoveryInitialBackoff
+	defer func() { discoveryInitialBackoff = savedBackoff }()
+	apiUrls = map[string]string{"base": upstream.URL()}
+	discoveryState = DiscoveryStatus{Source: discoveryPending}
+	discoveryInitialBackoff = time.Millisecond
+
+	// Every attempt fails: fall back to the default paths
+	if err := getApiUrls(); err == nil {
+		t.Errorf("getApiUrls should report the discovery error")
+	}
+	status := currentDiscoveryStatus()
+	if status.Source != discoveryFallback || status.Attempts != discoveryMaxAttempts {
+		t.Errorf("unexpected discovery status after failure: %+v", status)
+	}
+	if got, want := apiUrl("relations"), upstream.URL()+"/relation"; got != want {
+		t.Errorf("fallback relations url: got %v want %v", got, want)
+	}
+	if upstreamErrorPage().CodeNumber != http.StatusServiceUnavailable {
+		t.Errorf("upstream errors should render 503 while running on fallback urls")
+	}
+
+	// Upstream wakes up: the discovered urls replace the fallback
+	upstream.ClearErrors()
+	if err := getApiUrls(); err != nil {
+		t.Fatalf("getApiUrls failed: %v", err)
+	}
+	if status := currentDiscoveryStatus(); status.Source != discoveryDiscovered || status.LastError != "" {
+		t.Errorf("unexpected discovery status after success: %+v", status)
+	}
+	if got := apiUrl("artists"); got != upstream.URL()+"/artists" {
+		t.Errorf("discovered artists url: got %v", got)
+	}
+}
+
+func TestMain(m *testing.M) {
+	// Templates are loaded relative to the root of the project
+	if err := os.Chdir("../../../"); err != nil {
+		log.Fatalf("Error changing directory: %v", err)
+	}
+
+	// Set up global variables
+	publicUrl = "frontend/public/"
+
+	// Handlers fetch through the worker pool
+	tasks = make(chan RequestTask, workerPoolSize)
+	var wg sync.WaitGroup
+	for i := 0; i < workerPoolSize; i++ {
+		wg.Add(1)
+		go worker(&wg, tasks)
+	}
+	for runningWorkers.Load() < int32(workerPoolSize) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Run tests
+	os.Exit(m.Run())
+}
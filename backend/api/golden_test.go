@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"mymain/backend/api/fakeapi"
+)
+
+// Run `go test ./backend/api/ -run TestGolden -update` to rewrite the
+// snapshots after an intended template change.
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// goldenDir is relative to the project root, see TestMain.
+const goldenDir = "backend/api/testdata/golden"
+
+// normalizeHtml makes rendered pages comparable: whitespace runs collapse to
+// a single space, every tag starts on its own line, and the address of the
+// fake upstream, also in its JavaScript escaped form, and the parts that
+// change with every run are replaced by fixed placeholders.
+func normalizeHtml(body string, upstreamUrl string) string {
+	body = csrfField.ReplaceAllString(body, `name="csrf_token" value="csrf"`)
+	body = clockTime.ReplaceAllString(body, "01-01-2020 12:00 UTC")
+	body = processCount.ReplaceAllStringFunc(body, func(cell string) string {
+		return digits.ReplaceAllString(cell, "N")
+	})
+	body = strings.ReplaceAll(body, artistImages.Dir, "cache/images")
+	body = strings.ReplaceAll(body, upstreamUrl, "http://upstream")
+	body = strings.ReplaceAll(body, strings.ReplaceAll(upstreamUrl, "/", `\/`), `http:\/\/upstream`)
+	body = strings.Join(strings.Fields(body), " ")
+	body = strings.ReplaceAll(body, "> <", "><")
+	body = strings.ReplaceAll(body, "><", ">\n<")
+	return body + "\n"
+}
+
+// Parts of the pages that change with every run: the CSRF token of a
+// session, the times of syncs, and the goroutines and webhook deliveries
+// the admin page counts across the whole test process.
+var (
+	csrfField    = regexp.MustCompile(`name="csrf_token" value="[^"]+"`)
+	clockTime    = regexp.MustCompile(`\d{2}-\d{2}-\d{4} \d{2}:\d{2}(:\d{2})? UTC`)
+	processCount = regexp.MustCompile(`<th>(Webhook workers|Deliveries|Goroutines)</th><td>[^<]*`)
+	digits       = regexp.MustCompile(`\d+`)
+)
+
+// loggedIn registers an account and returns the cookie of its session.
+func loggedIn(t *testing.T, upstream *fakeapi.Server) []*http.Cookie {
+	useAccounts(t)
+	useWebhooks(t)
+	return registerUser(t, "queen_fan")
+}
+
+// asAdmin serves handler as the basic auth user of /admin.
+func asAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.SetBasicAuth("admin", "s3cret")
+		handler(w, r)
+	}
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join(goldenDir, name+".golden")
+
+	if *updateGolden {
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			t.Fatalf("creating %s: %v", goldenDir, err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("writing %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v (run with -update to create it)", path, err)
+	}
+	if got != string(want) {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("%s differs from the rendered page at line %d:\nwant: %s\ngot:  %s\n(run with -update if the change is intended)", path, i+1, wantLines[i], gotLines[i])
+			}
+		}
+		t.Fatalf("%s differs from the rendered page: want %d lines, got %d (run with -update if the change is intended)", path, len(wantLines), len(gotLines))
+	}
+}
+
+func TestGoldenPages(t *testing.T) {
+	testCases := []struct {
+		name         string
+		handler      http.HandlerFunc
+		target       string
+		expectedCode int
+		// setup prepares the state of the page and returns the cookies
+		// of its session, if any
+		setup func(t *testing.T, upstream *fakeapi.Server) []*http.Cookie
+	}{
+		{"index", handleIndex, "/", http.StatusOK, nil},
+		{"artists", handleArtists, "/artists", http.StatusOK, nil},
+		{"artists_sorted_paged", handleArtists, "/artists?sort=concerts&order=desc&size=4&page=2", http.StatusOK, nil},
+		{"artist_1", handleArtist, "/artist/1", http.StatusOK, nil},
+		{"artist_4", handleArtist, "/artist/4", http.StatusOK, nil},
+		{"locations", handleLocations, "/locations", http.StatusOK, nil},
+		{"dates", handleDates, "/dates", http.StatusOK, nil},
+		{"tours", handleRelations, "/tours", http.StatusOK, nil},
+		{"search_mikkey", handleSearch, "/search?search_text=mikkey", http.StatusOK, nil},
+		{"search_no_match", handleSearch, "/search?search_text=nobody", http.StatusNotFound, nil},
+		{"stats", handleStats, "/stats", http.StatusOK, nil},
+		{"compare_1_2_3", handleCompare, "/compare?ids=1,2,3", http.StatusOK, nil},
+		{"festivals", handleFestivals, "/festivals", http.StatusOK, nil},
+		{"members", handleMembers, "/members", http.StatusOK, nil},
+		{"member_mikkey_dee", handleMember, "/member/mikkey-dee", http.StatusOK, nil},
+		{"location_index", handleLocation, "/location", http.StatusOK, nil},
+		{"location_germany", handleLocation, "/location/germany", http.StatusOK, nil},
+		{"location_london", handleLocation, "/location/uk/london", http.StatusOK, nil},
+		{"favorites_empty", handleFavorites, "/favorites", http.StatusOK, nil},
+		{"timeline", handleTimeline, "/timeline", http.StatusOK, nil},
+		{"changes", handleChanges, "/changes", http.StatusOK, func(t *testing.T, upstream *fakeapi.Server) []*http.Cookie {
+			changeUpstream(t, upstream)
+			return nil
+		}},
+		{"login", handleLogin, "/login", http.StatusOK, func(t *testing.T, upstream *fakeapi.Server) []*http.Cookie {
+			useAccounts(t)
+			return nil
+		}},
+		{"register", handleRegister, "/register", http.StatusOK, func(t *testing.T, upstream *fakeapi.Server) []*http.Cookie {
+			useAccounts(t)
+			return nil
+		}},
+		{"account", handleAccount, "/account", http.StatusOK, loggedIn},
+		{"webhooks", handleWebhooks, "/webhooks", http.StatusOK, loggedIn},
+		{"admin", asAdmin(handleAdmin), "/admin", http.StatusOK, func(t *testing.T, upstream *fakeapi.Server) []*http.Cookie {
+			useAdmin(t)
+			// Earlier tests leave deliveries and sync attempts behind;
+			// newFakeUpstream already reset the discovery
+			webhookQueue.Wait()
+			webhookPool.sent.Store(0)
+			webhookPool.failed.Store(0)
+			webhookPool.dropped.Store(0)
+			snapshotState.Lock()
+			snapshotState.lastAttempt, snapshotState.lastError = time.Time{}, nil
+			snapshotState.Unlock()
+			return nil
+		}},
+		{"tag_british", handleTag, "/tag/british", http.StatusOK, func(t *testing.T, upstream *fakeapi.Server) []*http.Cookie {
+			useTags(t)
+			useMetadata(t, testTagMetadata)
+			if err := refreshSnapshot(); err != nil {
+				t.Fatal(err)
+			}
+			return nil
+		}},
+		{"not_found", handleIndex, "/unknown", http.StatusNotFound, nil},
+		{"method_not_allowed", handleArtists, "/artists", http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := newFakeUpstream(t)
+			var cookies []*http.Cookie
+			if tc.setup != nil {
+				cookies = tc.setup(t, upstream)
+			}
+
+			method := "GET"
+			if tc.expectedCode == http.StatusMethodNotAllowed {
+				method = "POST"
+			}
+			req := httptest.NewRequest(method, tc.target, nil)
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rr := httptest.NewRecorder()
+			tc.handler(rr, req)
+			if rr.Code != tc.expectedCode {
+				t.Fatalf("%s returned %v want %v", tc.target, rr.Code, tc.expectedCode)
+			}
+			// A template that fails half way appends the error page
+			if tc.expectedCode == http.StatusOK && strings.Contains(rr.Body.String(), `class="btnP"`) {
+				t.Fatalf("%s rendered an error page", tc.target)
+			}
+			checkGolden(t, tc.name, normalizeHtml(rr.Body.String(), upstream.Server.URL))
+		})
+	}
+}
+
+func TestGoldenErrorPages(t *testing.T) {
+	for name, errorType := range PredefinedErrors {
+		t.Run(name, func(t *testing.T) {
+			rr := serve(func(w http.ResponseWriter, r *http.Request) {
+				handleErrorPage(w, r, errorType)
+			}, "GET", "/")
+			golden := "error_" + errorType.Code
+			// The maintenance notice shares its code with the upstream error
+			if errorType.Name == "MaintenanceError" {
+				golden += "_maintenance"
+			}
+			checkGolden(t, golden, normalizeHtml(rr.Body.String(), "http://upstream"))
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"mymain/backend/api/overrides"
+)
+
+// How often the upstream data snapshot is reloaded and how long after the
+// last successful sync the instance reports itself as degraded.
+var (
+	snapshotRefreshInterval = 5 * time.Minute
+	snapshotMaxAge          = 15 * time.Minute
+)
+
+// dataSnapshot holds a complete copy of the upstream API data, as read
+// from the store. It is shared by all requests and must not be changed.
+// FirstSeen tells when every artist and concert first appeared upstream,
+// ImportedAt is the time of the first sync. Overrides tells what the local
+// corrections changed.
+type dataSnapshot struct {
+	Artists    []ArtistsData
+	Locations  LocationsDataLevel1
+	Dates      DatesDataLevel1
+	Relations  RelationsDataLevel1
+	LoadedAt   time.Time
+	FirstSeen  map[string]time.Time
+	ImportedAt time.Time
+	Overrides  []overrides.Record
+}
+
+var snapshotState struct {
+	sync.RWMutex
+	data        *dataSnapshot
+	lastError   error
+	readError   error
+	lastAttempt time.Time
+}
+
+type componentStatus struct {
+	Status string                 `json:"status"`
+	Detail string                 `json:"detail,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+type readinessReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+// refreshSnapshot syncs the store with the upstream and reloads the
+// snapshot from the store. When the sync fails the stored data keeps
+// serving, the error is still reported.
+func refreshSnapshot() error {
+	_, syncErr := syncStore()
+	snapshot, readErr := readStore()
+
+	snapshotState.Lock()
+	defer snapshotState.Unlock()
+	snapshotState.lastAttempt = time.Now()
+	snapshotState.lastError = syncErr
+	snapshotState.readError = readErr
+	if readErr == nil {
+		snapshotState.data = snapshot
+		checkMetadata(snapshot)
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+	return readErr
+}
+
+// currentSnapshot returns the loaded snapshot, loading it first if no
+// snapshot has been loaded yet. An error is only returned when neither the
+// upstream nor the store has data.
+func currentSnapshot() (*dataSnapshot, error) {
+	snapshotState.RLock()
+	snapshot := snapshotState.data
+	snapshotState.RUnlock()
+	if snapshot != nil {
+		return snapshot, nil
+	}
+
+	err := loadSnapshot()
+	snapshotState.RLock()
+	defer snapshotState.RUnlock()
+	if snapshotState.data == nil {
+		return nil, err
+	}
+	return snapshotState.data, nil
+}
+
+// snapshotLoad is the refresh started by the first request finding no
+// snapshot. Requests arriving while it runs wait for it instead of each
+// syncing with the upstream, which matters most when the upstream is down.
+var snapshotLoad struct {
+	sync.Mutex
+	running *snapshotFlight
+}
+
+type snapshotFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// loadSnapshot refreshes the snapshot, or waits for the refresh already
+// loading it and returns its error.
+func loadSnapshot() error {
+	snapshotLoad.Lock()
+	if flight := snapshotLoad.running; flight != nil {
+		snapshotLoad.Unlock()
+		<-flight.done
+		return flight.err
+	}
+	flight := &snapshotFlight{done: make(chan struct{})}
+	snapshotLoad.running = flight
+	snapshotLoad.Unlock()
+
+	flight.err = refreshSnapshot()
+	snapshotLoad.Lock()
+	snapshotLoad.running = nil
+	snapshotLoad.Unlock()
+	close(flight.done)
+	return flight.err
+}
+
+func watchSnapshot(interval time.Duration) {
+	for {
+		if err := refreshSnapshot(); err != nil {
+			log.Printf("snapshot refresh failed: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func checkUpstreamUrls() componentStatus {
+	discovery := currentDiscoveryStatus()
+	data := map[string]interface{}{
+		"base":      apiUrl("base"),
+		"discovery": discovery,
+	}
+
+	var missing []string
+	for _, key := range []string{"artists", "locations", "dates", "relations"} {
+		if apiUrl(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		data["missing"] = missing
+		return componentStatus{Status: "fail", Detail: "upstream urls not discovered", Data: data}
+	}
+	if discovery.Source == discoveryFallback {
+		return componentStatus{Status: "ok", Detail: "using default upstream paths", Data: data}
+	}
+	return componentStatus{Status: "ok", Data: data}
+}
+
+func checkSnapshot() componentStatus {
+	snapshotState.RLock()
+	defer snapshotState.RUnlock()
+
+	data := map[string]interface{}{}
+	if !snapshotState.lastAttempt.IsZero() {
+		data["last_attempt"] = snapshotState.lastAttempt
+	}
+	if snapshotState.lastError != nil {
+		data["last_error"] = snapshotState.lastError.Error()
+	}
+	if snapshotState.readError != nil {
+		data["read_error"] = snapshotState.readError.Error()
+	}
+
+	if snapshotState.data == nil {
+		return componentStatus{Status: "fail", Detail: "snapshot not loaded", Data: data}
+	}
+	if snapshotState.readError != nil {
+		return componentStatus{Status: "fail", Detail: "store not readable", Data: data}
+	}
+
+	age := time.Since(snapshotState.data.LoadedAt)
+	data["loaded_at"] = snapshotState.data.LoadedAt
+	data["age_seconds"] = int(age.Seconds())
+	data["artists"] = len(snapshotState.data.Artists)
+	// The stored data keeps being served while the upstream is down, so a
+	// failing sync does not take the instance out of the load balancer
+	if age > snapshotMaxAge {
+		return componentStatus{Status: "degraded", Detail: fmt.Sprintf("last sync older than %s", snapshotMaxAge), Data: data}
+	}
+	return componentStatus{Status: "ok", Data: data}
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// handleHealthz reports whether the process is alive. It never touches the
+// upstream API.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "error": MethodNotAllowedError.Info})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the instance can render pages: the upstream
+// urls are known and a snapshot is read from the store. A snapshot whose
+// last sync is old is reported as degraded, still with 200.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"status": "error", "error": MethodNotAllowedError.Info})
+		return
+	}
+
+	report := readinessReport{
+		Status: "ready",
+		Components: map[string]componentStatus{
+			"upstream_urls": checkUpstreamUrls(),
+			"snapshot":      checkSnapshot(),
+		},
+	}
+
+	status := http.StatusOK
+	for _, component := range report.Components {
+		switch component.Status {
+		case "ok":
+		case "degraded":
+			if report.Status == "ready" {
+				report.Status = "degraded"
+			}
+		default:
+			report.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJson(w, status, report)
+}
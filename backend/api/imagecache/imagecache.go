@@ -0,0 +1,353 @@
+// Package imagecache serves artist images from a local disk cache.
+//
+// The original image is fetched from the upstream once, stored on disk and
+// resized into the thumbnail sizes used by the templates. Responses carry
+// long-lived cache headers and an ETag. When the upstream is unavailable
+// and nothing is cached yet, a generated placeholder is served instead.
+package imagecache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Size is a thumbnail size. Cropped sizes are cut to fill the box exactly,
+// the others are scaled to fit inside it.
+type Size struct {
+	Width  int
+	Height int
+	Crop   bool
+}
+
+// Sizes are the thumbnail sizes known to the templates. "full" serves the
+// original image.
+var Sizes = map[string]Size{
+	"card":   {Width: 400, Height: 400},
+	"swiper": {Width: 160, Height: 160, Crop: true},
+	"hero":   {Width: 700, Height: 700},
+}
+
+const originalSize = "full"
+
+// ErrUnknownArtist is returned by a lookup function for ids without artist.
+var ErrUnknownArtist = errors.New("unknown artist")
+
+// Cache fetches, stores and serves artist images.
+type Cache struct {
+	// Dir is where originals and thumbnails are stored.
+	Dir string
+	// Lookup returns the upstream image url of an artist.
+	Lookup func(id int) (string, error)
+	// Client fetches the originals, http.DefaultClient if nil.
+	Client *http.Client
+	// MaxAge is sent in the Cache-Control header of cached images.
+	MaxAge time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// Stats describes the content of the cache directory.
+type Stats struct {
+	Files  int       `json:"files"`
+	Bytes  int64     `json:"bytes"`
+	Oldest time.Time `json:"oldest"`
+	Newest time.Time `json:"newest"`
+}
+
+// New returns a cache storing its files in dir.
+func New(dir string, lookup func(id int) (string, error)) *Cache {
+	return &Cache{
+		Dir:    dir,
+		Lookup: lookup,
+		MaxAge: 30 * 24 * time.Hour,
+		locks:  map[string]*sync.Mutex{},
+	}
+}
+
+// ServeHTTP answers /img/artist/{id}?size={card|swiper|hero|full}.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	sizeName := r.URL.Query().Get("size")
+	if sizeName == "" {
+		sizeName = originalSize
+	}
+	size, known := Sizes[sizeName]
+	if !known && sizeName != originalSize {
+		http.Error(w, "unknown size", http.StatusBadRequest)
+		return
+	}
+
+	sourceUrl, err := c.Lookup(id)
+	if errors.Is(err, ErrUnknownArtist) {
+		http.NotFound(w, r)
+		return
+	}
+	var data []byte
+	if err == nil {
+		data, err = c.Get(sourceUrl, sizeName)
+	}
+	if err != nil {
+		c.servePlaceholder(w, r, size)
+		return
+	}
+
+	etag := `"` + hash(string(data))[:20] + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(c.MaxAge.Seconds())))
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// Get returns the image at sourceUrl in the named size, fetching and
+// resizing it on first use.
+func (c *Cache) Get(sourceUrl string, sizeName string) ([]byte, error) {
+	key := hash(sourceUrl)
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	thumbPath := filepath.Join(c.Dir, sizeName, key)
+	if data, err := os.ReadFile(thumbPath); err == nil {
+		return data, nil
+	}
+
+	originalPath := filepath.Join(c.Dir, originalSize, key)
+	original, err := os.ReadFile(originalPath)
+	var src image.Image
+	if err != nil {
+		if original, err = c.fetch(sourceUrl); err != nil {
+			return nil, err
+		}
+		// Only images are kept, so a broken download is fetched again
+		// next time
+		if src, _, err = image.Decode(bytes.NewReader(original)); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", sourceUrl, err)
+		}
+		if err := writeFile(originalPath, original); err != nil {
+			return nil, err
+		}
+	}
+	if sizeName == originalSize {
+		return original, nil
+	}
+
+	if src == nil {
+		if src, _, err = image.Decode(bytes.NewReader(original)); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", sourceUrl, err)
+		}
+	}
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, Thumbnail(src, Sizes[sizeName]), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	if err := writeFile(thumbPath, out.Bytes()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Stats walks the cache directory.
+func (c *Cache) Stats() (Stats, error) {
+	var stats Stats
+	err := filepath.WalkDir(c.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		stats.Files++
+		stats.Bytes += info.Size()
+		if stats.Oldest.IsZero() || info.ModTime().Before(stats.Oldest) {
+			stats.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(stats.Newest) {
+			stats.Newest = info.ModTime()
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Purge removes every cached original and thumbnail.
+func (c *Cache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return os.RemoveAll(c.Dir)
+}
+
+func (c *Cache) lock(key string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.locks == nil {
+		c.locks = map[string]*sync.Mutex{}
+	}
+	if c.locks[key] == nil {
+		c.locks[key] = &sync.Mutex{}
+	}
+	return c.locks[key]
+}
+
+func (c *Cache) fetch(sourceUrl string) ([]byte, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(sourceUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", sourceUrl, res.Status)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 20<<20))
+}
+
+func (c *Cache) servePlaceholder(w http.ResponseWriter, r *http.Request, size Size) {
+	if size.Width == 0 {
+		size = Sizes["hero"]
+	}
+	var out bytes.Buffer
+	png.Encode(&out, Placeholder(size.Width, size.Height))
+
+	// Short lived so the real image shows up once the upstream is back
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(out.Bytes())
+}
+
+// writeFile writes atomically so concurrent readers never see a partial file.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Thumbnail scales src into size, cropping around the center for cropped
+// sizes. Images are never enlarged.
+func Thumbnail(src image.Image, size Size) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	if size.Crop {
+		// Cut the largest centered region with the aspect ratio of the box
+		cropW, cropH := srcW, srcW*size.Height/size.Width
+		if cropH > srcH {
+			cropW, cropH = srcH*size.Width/size.Height, srcH
+		}
+		x0 := bounds.Min.X + (srcW-cropW)/2
+		y0 := bounds.Min.Y + (srcH-cropH)/2
+		bounds = image.Rect(x0, y0, x0+cropW, y0+cropH)
+		srcW, srcH = cropW, cropH
+	}
+
+	scale := min(float64(size.Width)/float64(srcW), float64(size.Height)/float64(srcH), 1)
+	dstW, dstH := max(int(float64(srcW)*scale), 1), max(int(float64(srcH)*scale), 1)
+	return resize(src, bounds, dstW, dstH)
+}
+
+// resize downsamples the region of src with a box filter: every target
+// pixel is the average of the source pixels it covers.
+func resize(src image.Image, region image.Rectangle, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcW, srcH := region.Dx(), region.Dy()
+	for y := 0; y < height; y++ {
+		y0 := region.Min.Y + y*srcH/height
+		y1 := max(region.Min.Y+(y+1)*srcH/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := region.Min.X + x*srcW/width
+			x1 := max(region.Min.X+(x+1)*srcW/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// Placeholder draws a neutral artist silhouette.
+func Placeholder(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	background := color.RGBA{R: 0x34, G: 0x3a, B: 0x40, A: 0xff}
+	figure := color.RGBA{R: 0x6c, G: 0x75, B: 0x7d, A: 0xff}
+
+	unit := float64(min(width, height))
+	cx, cy := float64(width)/2, float64(height)/2
+	headY, headR := cy-unit*0.12, unit*0.16
+	bodyY, bodyR := cy+unit*0.38, unit*0.30
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)+0.5, float64(y)+0.5
+			inHead := (fx-cx)*(fx-cx)+(fy-headY)*(fy-headY) <= headR*headR
+			inBody := (fx-cx)*(fx-cx)+(fy-bodyY)*(fy-bodyY) <= bodyR*bodyR
+			if inHead || inBody {
+				img.SetRGBA(x, y, figure)
+			} else {
+				img.SetRGBA(x, y, background)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,165 @@
+package imagecache
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mymain/backend/api/fakeapi"
+)
+
+func newTestCache(t *testing.T) (*Cache, *fakeapi.Server) {
+	t.Helper()
+	upstream := fakeapi.NewServer(fakeapi.DefaultArtists())
+	t.Cleanup(upstream.Close)
+
+	cache := New(t.TempDir(), func(id int) (string, error) {
+		if id > 6 {
+			return "", ErrUnknownArtist
+		}
+		return upstream.URL() + "/images/artist.jpeg", nil
+	})
+	return cache, upstream
+}
+
+func get(cache *Cache, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rr := httptest.NewRecorder()
+	cache.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServeThumbnails(t *testing.T) {
+	cache, upstream := newTestCache(t)
+
+	testCases := []struct {
+		size          string
+		width, height int
+	}{
+		{"full", 600, 400},
+		{"card", 400, 266},
+		{"swiper", 160, 160},
+		{"hero", 600, 400},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.size, func(t *testing.T) {
+			rr := get(cache, "/img/artist/1?size="+tc.size, nil)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("size %s returned %v", tc.size, rr.Code)
+			}
+			config, _, err := image.DecodeConfig(bytes.NewReader(rr.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("size %s is not an image: %v", tc.size, err)
+			}
+			if config.Width != tc.width || config.Height != tc.height {
+				t.Errorf("size %s is %dx%d want %dx%d", tc.size, config.Width, config.Height, tc.width, tc.height)
+			}
+			if rr.Header().Get("ETag") == "" || rr.Header().Get("Cache-Control") != "public, max-age=2592000" {
+				t.Errorf("size %s has cache headers %v", tc.size, rr.Header())
+			}
+		})
+	}
+
+	// The original was fetched once and every size was generated from it
+	if calls := upstream.Calls("/api/images/artist.jpeg"); calls != 1 {
+		t.Errorf("upstream image fetched %d times, want 1", calls)
+	}
+	stats, err := cache.Stats()
+	if err != nil || stats.Files != 4 {
+		t.Errorf("cache stats %+v, %v: want 4 files", stats, err)
+	}
+}
+
+func TestServeNotModified(t *testing.T) {
+	cache, _ := newTestCache(t)
+
+	rr := get(cache, "/img/artist/2?size=card", nil)
+	etag := rr.Header().Get("ETag")
+
+	rr = get(cache, "/img/artist/2?size=card", http.Header{"If-None-Match": {etag}})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("conditional request returned %v with %d bytes", rr.Code, rr.Body.Len())
+	}
+}
+
+func TestServeFromCacheWhileUpstreamDown(t *testing.T) {
+	cache, upstream := newTestCache(t)
+
+	if rr := get(cache, "/img/artist/3?size=hero", nil); rr.Code != http.StatusOK {
+		t.Fatalf("warming the cache returned %v", rr.Code)
+	}
+	cached := get(cache, "/img/artist/3?size=hero", nil).Body.Bytes()
+
+	upstream.FailPath("/api/images/artist.jpeg", http.StatusServiceUnavailable)
+	rr := get(cache, "/img/artist/3?size=hero", nil)
+	if !bytes.Equal(rr.Body.Bytes(), cached) {
+		t.Errorf("cached image not served while upstream is down")
+	}
+
+	// Nothing cached for other sizes after a purge: placeholder
+	if err := cache.Purge(); err != nil {
+		t.Fatal(err)
+	}
+	rr = get(cache, "/img/artist/3?size=swiper", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || rr.Header().Get("Cache-Control") != "public, max-age=300" {
+		t.Errorf("placeholder returned %v with headers %v", rr.Code, rr.Header())
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(rr.Body.Bytes()))
+	if err != nil || config.Width != 160 || config.Height != 160 {
+		t.Errorf("placeholder is %+v, %v", config, err)
+	}
+}
+
+func TestBrokenImageNotCached(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>moved</html>"))
+	}))
+	t.Cleanup(page.Close)
+	cache := New(t.TempDir(), func(id int) (string, error) { return page.URL, nil })
+
+	for _, size := range []string{"card", "full"} {
+		if _, err := cache.Get(page.URL, size); err == nil {
+			t.Errorf("size %s of a page was served as an image", size)
+		}
+	}
+	if stats, err := cache.Stats(); err != nil || stats.Files != 0 {
+		t.Errorf("cache stats %+v, %v: want no files", stats, err)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	cache, _ := newTestCache(t)
+
+	testCases := []struct {
+		method       string
+		target       string
+		expectedCode int
+	}{
+		{"GET", "/img/artist/99", http.StatusNotFound},
+		{"GET", "/img/artist/abc", http.StatusNotFound},
+		{"GET", "/img/artist/1?size=huge", http.StatusBadRequest},
+		{"POST", "/img/artist/1", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range testCases {
+		rr := httptest.NewRecorder()
+		cache.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, nil))
+		if rr.Code != tc.expectedCode {
+			t.Errorf("%s %s returned %v want %v", tc.method, tc.target, rr.Code, tc.expectedCode)
+		}
+	}
+}
+
+func TestThumbnailNeverEnlarges(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	if bounds := Thumbnail(src, Sizes["hero"]).Bounds(); bounds.Dx() != 100 || bounds.Dy() != 50 {
+		t.Errorf("small image resized to %v", bounds)
+	}
+	if bounds := Thumbnail(src, Sizes["swiper"]).Bounds(); bounds.Dx() != 50 || bounds.Dy() != 50 {
+		t.Errorf("small image cropped to %v", bounds)
+	}
+}
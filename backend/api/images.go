@@ -0,0 +1,22 @@
+package main
+
+import (
+	"mymain/backend/api/imagecache"
+)
+
+// artistImages serves /img/artist/{id} from the local image cache so pages
+// keep their images while the upstream is asleep.
+var artistImages = imagecache.New("cache/images", lookupArtistImage)
+
+func lookupArtistImage(id int) (string, error) {
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		return "", err
+	}
+	for _, artist := range snapshot.Artists {
+		if artist.Id == id {
+			return artist.Image, nil
+		}
+	}
+	return "", imagecache.ErrUnknownArtist
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mymain/backend/api/metadata"
+)
+
+// Page sizes of the artists listing.
+const (
+	defaultPageSize = 24
+	maxPageSize     = 100
+)
+
+// artistSorts are the orders of the artists listing, in the order they are
+// offered on the page.
+var artistSorts = []struct {
+	Key   string
+	Label string
+}{
+	{"name", "Name"},
+	{"creation", "Creation date"},
+	{"album", "First album"},
+	{"members", "Members"},
+	{"concerts", "Concerts"},
+}
+
+// artistQuery is the sorting, paging and filtering of the artists listing.
+// The filter parameters are named like the inputs of artist_filter.html;
+// zero values mean no filter.
+type artistQuery struct {
+	values url.Values
+
+	Sort          string
+	Descending    bool
+	Page          int
+	Size          int
+	CreationStart int
+	CreationEnd   int
+	AlbumStart    int
+	AlbumEnd      int
+	Location      string
+	Members       []int
+	Genre         string
+	Origin        string
+	Tag           string
+}
+
+// artistListing describes the shown page of the artists listing and links
+// the other pages and orders, keeping every other parameter of the query.
+type artistListing struct {
+	Total     int
+	Page      int
+	Pages     int
+	Sort      string
+	Order     string
+	PageLinks []listingLink
+	SortLinks []listingLink
+	PrevUrl   string
+	NextUrl   string
+}
+
+type listingLink struct {
+	Label   string
+	Url     string
+	Current bool
+}
+
+// parseArtistQuery reads the listing parameters. Unknown sorts, orders and
+// malformed numbers are errors.
+func parseArtistQuery(values url.Values) (artistQuery, error) {
+	query := artistQuery{values: values, Sort: "name", Page: 1, Size: defaultPageSize}
+
+	if sortKey := values.Get("sort"); sortKey != "" {
+		if !slices.ContainsFunc(artistSorts, func(s struct{ Key, Label string }) bool { return s.Key == sortKey }) {
+			return query, fmt.Errorf("unknown sort %q", sortKey)
+		}
+		query.Sort = sortKey
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, fmt.Errorf("unknown order %q", values.Get("order"))
+	}
+
+	numbers := []struct {
+		key      string
+		target   *int
+		min, max int
+	}{
+		{"page", &query.Page, 1, 1 << 20},
+		{"size", &query.Size, 1, maxPageSize},
+		{"creation_date_start", &query.CreationStart, 0, 9999},
+		{"creation_date_end", &query.CreationEnd, 0, 9999},
+		{"first_album_date_start", &query.AlbumStart, 0, 9999},
+		{"first_album_date_end", &query.AlbumEnd, 0, 9999},
+	}
+	for _, number := range numbers {
+		text := values.Get(number.key)
+		if text == "" {
+			continue
+		}
+		value, err := strconv.Atoi(text)
+		if err != nil || value < number.min || value > number.max {
+			return query, fmt.Errorf("invalid %s %q", number.key, text)
+		}
+		*number.target = value
+	}
+
+	query.Location = values.Get("concerts_locations")
+	query.Genre = metadata.GenreKey(values.Get("genre"))
+	query.Origin = values.Get("origin")
+	query.Tag = tagSlug(values.Get("tag"))
+	for _, text := range values["members[]"] {
+		count, err := strconv.Atoi(text)
+		if err != nil || count < 1 {
+			return query, fmt.Errorf("invalid members %q", text)
+		}
+		query.Members = append(query.Members, count)
+	}
+	return query, nil
+}
+
+// matches tells whether the artist passes the filters of the query.
+func (q artistQuery) matches(artist ArtistsData) bool {
+	if q.CreationStart > 0 && artist.CreationDate < q.CreationStart {
+		return false
+	}
+	if q.CreationEnd > 0 && artist.CreationDate > q.CreationEnd {
+		return false
+	}
+	if q.AlbumStart > 0 || q.AlbumEnd > 0 {
+		album, err := parseDate(artist.FirstAlbum)
+		if err != nil {
+			return false
+		}
+		if (q.AlbumStart > 0 && album.Year() < q.AlbumStart) || (q.AlbumEnd > 0 && album.Year() > q.AlbumEnd) {
+			return false
+		}
+	}
+	if q.Location != "" && !slices.Contains(artist.LocationsData, q.Location) {
+		return false
+	}
+	if len(q.Members) > 0 && !slices.Contains(q.Members, len(artist.Members)) {
+		return false
+	}
+	if q.Genre != "" && (artist.Metadata == nil || !artist.Metadata.HasGenre(q.Genre)) {
+		return false
+	}
+	if q.Origin != "" && (artist.Metadata == nil || artist.Metadata.Origin != q.Origin) {
+		return false
+	}
+	if q.Tag != "" && !artist.hasTag(q.Tag) {
+		return false
+	}
+	return true
+}
+
+// sortArtists orders the artists in place. concerts holds the number of
+// concerts per artist id and is only needed to sort by concerts. Ties are
+// broken by id so pages are stable.
+func (q artistQuery) sortArtists(artists []ArtistsData, concerts map[int]int) {
+	compare := func(a, b ArtistsData) int {
+		switch q.Sort {
+		case "creation":
+			return a.CreationDate - b.CreationDate
+		case "album":
+			albumA, _ := parseDate(a.FirstAlbum)
+			albumB, _ := parseDate(b.FirstAlbum)
+			return albumA.Compare(albumB)
+		case "members":
+			return len(a.Members) - len(b.Members)
+		case "concerts":
+			return concerts[a.Id] - concerts[b.Id]
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	}
+	sort.SliceStable(artists, func(i, j int) bool {
+		order := compare(artists[i], artists[j])
+		if order == 0 {
+			return artists[i].Id < artists[j].Id
+		}
+		if q.Descending {
+			return order > 0
+		}
+		return order < 0
+	})
+}
+
+// link returns path with the query, changed by the given key value pairs.
+func (q artistQuery) link(path string, changes ...string) string {
+	values := url.Values{}
+	for key, list := range q.values {
+		values[key] = append([]string(nil), list...)
+	}
+	for i := 0; i+1 < len(changes); i += 2 {
+		values.Set(changes[i], changes[i+1])
+	}
+	if values.Get("page") == "1" {
+		values.Del("page")
+	}
+	if encoded := values.Encode(); encoded != "" {
+		return path + "?" + encoded
+	}
+	return path
+}
+
+// listArtists filters, sorts and pages the artists. It returns the artists
+// of the requested page and ok false when the page does not exist.
+func listArtists(artists []ArtistsData, concerts map[int]int, query artistQuery, path string) ([]ArtistsData, artistListing, bool) {
+	var filtered []ArtistsData
+	for _, artist := range artists {
+		if query.matches(artist) {
+			filtered = append(filtered, artist)
+		}
+	}
+	query.sortArtists(filtered, concerts)
+
+	listing := artistListing{
+		Total: len(filtered),
+		Page:  query.Page,
+		Pages: max((len(filtered)+query.Size-1)/query.Size, 1),
+		Sort:  query.Sort,
+		Order: "asc",
+	}
+	if query.Descending {
+		listing.Order = "desc"
+	}
+	if query.Page > listing.Pages {
+		return nil, listing, false
+	}
+
+	for page := 1; page <= listing.Pages; page++ {
+		listing.PageLinks = append(listing.PageLinks, listingLink{
+			Label:   strconv.Itoa(page),
+			Url:     query.link(path, "page", strconv.Itoa(page)),
+			Current: page == query.Page,
+		})
+	}
+	if query.Page > 1 {
+		listing.PrevUrl = query.link(path, "page", strconv.Itoa(query.Page-1))
+	}
+	if query.Page < listing.Pages {
+		listing.NextUrl = query.link(path, "page", strconv.Itoa(query.Page+1))
+	}
+	for _, option := range artistSorts {
+		for _, order := range []string{"asc", "desc"} {
+			label := option.Label + " ↑"
+			if order == "desc" {
+				label = option.Label + " ↓"
+			}
+			listing.SortLinks = append(listing.SortLinks, listingLink{
+				Label:   label,
+				Url:     query.link(path, "sort", option.Key, "order", order, "page", "1"),
+				Current: option.Key == listing.Sort && order == listing.Order,
+			})
+		}
+	}
+
+	start := (query.Page - 1) * query.Size
+	end := min(start+query.Size, len(filtered))
+	return filtered[start:end], listing, true
+}
+
+// concertCounts counts the dates of every artist in the dates index.
+func concertCounts(dates DatesDataLevel1) map[int]int {
+	counts := map[int]int{}
+	for _, entry := range dates.Index {
+		counts[entry.Id] = len(entry.Dates)
+	}
+	return counts
+}
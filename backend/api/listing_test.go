@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArtistQuery(t *testing.T) {
+	query, err := parseArtistQuery(url.Values{
+		"sort":                {"album"},
+		"order":               {"desc"},
+		"page":                {"2"},
+		"size":                {"10"},
+		"creation_date_start": {"1960"},
+		"concerts_locations":  {"osaka-japan"},
+		"members[]":           {"1", "5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Sort != "album" || !query.Descending || query.Page != 2 || query.Size != 10 || query.CreationStart != 1960 || query.Location != "osaka-japan" || !reflect.DeepEqual(query.Members, []int{1, 5}) {
+		t.Errorf("parsed query is %+v", query)
+	}
+
+	if query, err := parseArtistQuery(url.Values{}); err != nil || query.Sort != "name" || query.Page != 1 || query.Size != defaultPageSize {
+		t.Errorf("default query is %+v, %v", query, err)
+	}
+
+	for _, invalid := range []string{"sort=genre", "order=up", "page=0", "page=x", "size=1000", "creation_date_end=soon", "members[]=none"} {
+		values, _ := url.ParseQuery(invalid)
+		if _, err := parseArtistQuery(values); err == nil {
+			t.Errorf("%s parsed without error", invalid)
+		}
+	}
+}
+
+func TestListArtists(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	concerts := concertCounts(snapshot.Dates)
+
+	names := func(artists []ArtistsData) []string {
+		var list []string
+		for _, artist := range artists {
+			list = append(list, artist.Name)
+		}
+		return list
+	}
+
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"Bobby McFerrins", "Motörhead", "Pink Floyd", "Queen", "Scorpions", "SOJA"}},
+		{"sort=creation", []string{"Pink Floyd", "Scorpions", "Queen", "Motörhead", "Bobby McFerrins", "SOJA"}},
+		{"sort=album&order=desc", []string{"SOJA", "Bobby McFerrins", "Motörhead", "Queen", "Scorpions", "Pink Floyd"}},
+		{"sort=members", []string{"Bobby McFerrins", "Motörhead", "Pink Floyd", "Scorpions", "Queen", "SOJA"}},
+		{"sort=concerts&order=desc", []string{"Queen", "SOJA", "Scorpions", "Pink Floyd", "Bobby McFerrins", "Motörhead"}},
+		{"sort=creation&size=4&page=2", []string{"Bobby McFerrins", "SOJA"}},
+		{"creation_date_start=1970&members[]=7&members[]=8", []string{"Queen", "SOJA"}},
+		{"first_album_date_end=1972", []string{"Pink Floyd", "Scorpions"}},
+	}
+	for _, tc := range testCases {
+		values, _ := url.ParseQuery(tc.query)
+		query, err := parseArtistQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		artists, _, ok := listArtists(append([]ArtistsData(nil), snapshot.Artists...), concerts, query, "/artists")
+		if got := names(artists); !ok || !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%q lists %v want %v", tc.query, got, tc.expected)
+		}
+	}
+
+	values, _ := url.ParseQuery("search_text=a&sort=members&order=desc&size=2&page=2")
+	query, _ := parseArtistQuery(values)
+	_, listing, ok := listArtists(snapshot.Artists, concerts, query, "/search")
+	if !ok || listing.Pages != 3 || listing.Total != 6 {
+		t.Fatalf("listing is %+v", listing)
+	}
+	if listing.PrevUrl != "/search?order=desc&search_text=a&size=2&sort=members" || listing.NextUrl != "/search?order=desc&page=3&search_text=a&size=2&sort=members" {
+		t.Errorf("page links are %q and %q", listing.PrevUrl, listing.NextUrl)
+	}
+	if !listing.PageLinks[1].Current || listing.SortLinks[0].Url != "/search?order=asc&search_text=a&size=2&sort=name" {
+		t.Errorf("links are %+v", listing)
+	}
+
+	values.Set("page", "4")
+	query, _ = parseArtistQuery(values)
+	if _, _, ok := listArtists(snapshot.Artists, concerts, query, "/search"); ok {
+		t.Errorf("page 4 of 3 exists")
+	}
+}
+
+func TestHandleArtistsListing(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleArtists, "GET", "/artists?sort=creation&order=desc&size=2")
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `id="artist_2"`) || !strings.Contains(body, `id="artist_5"`) || strings.Contains(body, `id="artist_1"`) {
+		t.Errorf("first page sorted by creation returned %v with other artists", rr.Code)
+	}
+	if !strings.Contains(body, `href="/artists?order=desc&amp;page=2&amp;size=2&amp;sort=creation"`) {
+		t.Errorf("page links do not keep the sort order")
+	}
+
+	rr = serve(handleSearch, "GET", "/search?search_text=mikkey&sort=name&order=desc")
+	body = rr.Body.String()
+	if rr.Code != http.StatusOK || strings.Index(body, `id="artist_4"`) > strings.Index(body, `id="artist_6"`) {
+		t.Errorf("search results are not sorted by name descending")
+	}
+
+	testCases := []struct {
+		handler      http.HandlerFunc
+		target       string
+		expectedCode int
+	}{
+		{handleArtists, "/artists?page=2", http.StatusNotFound},
+		{handleArtists, "/artists?sort=genre", http.StatusBadRequest},
+		{handleArtists, "/artists?sort=concerts", http.StatusOK},
+		{handleSearch, "/search?search_text=mikkey&size=0", http.StatusBadRequest},
+		{handleSearch, "/search?search_text=mikkey&size=1&page=3", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		if rr := serve(tc.handler, "GET", tc.target); rr.Code != tc.expectedCode {
+			t.Errorf("%s returned %v want %v", tc.target, rr.Code, tc.expectedCode)
+		}
+	}
+}
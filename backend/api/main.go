@@ -6,14 +6,35 @@ import (
 	"html/template"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"mymain/backend/api/accounts"
+	"mymain/backend/api/datastore"
+	"mymain/backend/api/favorites"
+	"mymain/backend/api/session"
 )
 
 var publicUrl = "frontend/public/"
 
+// pageFuncs are the functions of the page templates. The templates are
+// shared with the worker-pool server, where serves leaves out links to the
+// pages it does not have; this server has them all.
+var pageFuncs = template.FuncMap{
+	"serves": func(path string) bool { return true },
+}
+
+// parsePage parses the files of a page, the page itself first.
+func parsePage(files ...string) (*template.Template, error) {
+	return template.New(filepath.Base(files[0])).Funcs(pageFuncs).ParseFiles(files...)
+}
+
 var apiUrls = map[string]string{
 	"base": "https://groupietrackers.herokuapp.com/api",
 }
@@ -29,6 +50,10 @@ type ArtistsData struct {
 	ConcertDates  string   `json:"concertDates"`
 	Relations     string   `json:"relations"`
 	LocationsData []string
+	// Metadata is the local enrichment of the artist, nil when there is none
+	Metadata *artistMetadata `json:"metadata,omitempty"`
+	// Tags are the genres and tags of the metadata and those given on /admin
+	Tags []artistTag `json:"tags,omitempty"`
 }
 
 type LocationsDataLevel2 struct {
@@ -91,66 +116,113 @@ var PredefinedErrors = map[string]ErrorPageData{
 		CodeNumber: http.StatusInternalServerError,
 		Info:       "Internal server error",
 	},
+	"ForbiddenError": {
+		Name:       "ForbiddenError",
+		Code:       strconv.Itoa(http.StatusForbidden),
+		CodeNumber: http.StatusForbidden,
+		Info:       "This form has expired, please reload the page and try again",
+	},
+	"ServiceUnavailableError": {
+		Name:       "ServiceUnavailableError",
+		Code:       strconv.Itoa(http.StatusServiceUnavailable),
+		CodeNumber: http.StatusServiceUnavailable,
+		Info:       "Artist data is temporarily unavailable",
+	},
+	"UnauthorizedError": {
+		Name:       "UnauthorizedError",
+		Code:       strconv.Itoa(http.StatusUnauthorized),
+		CodeNumber: http.StatusUnauthorized,
+		Info:       "Please log in to the admin area",
+	},
+	"MaintenanceError": {
+		Name:       "MaintenanceError",
+		Code:       strconv.Itoa(http.StatusServiceUnavailable),
+		CodeNumber: http.StatusServiceUnavailable,
+		Info:       "The site is down for maintenance, please come back later",
+	},
+	"TooManyRequestsError": {
+		Name:       "TooManyRequestsError",
+		Code:       strconv.Itoa(http.StatusTooManyRequests),
+		CodeNumber: http.StatusTooManyRequests,
+		Info:       "Too many requests, please wait a minute and try again",
+	},
 }
 
 var (
-	BadRequestError       = PredefinedErrors["BadRequestError"]
-	NotFoundError         = PredefinedErrors["NotFoundError"]
-	MethodNotAllowedError = PredefinedErrors["MethodNotAllowedError"]
-	InternalServerError   = PredefinedErrors["InternalServerError"]
+	BadRequestError         = PredefinedErrors["BadRequestError"]
+	NotFoundError           = PredefinedErrors["NotFoundError"]
+	MethodNotAllowedError   = PredefinedErrors["MethodNotAllowedError"]
+	InternalServerError     = PredefinedErrors["InternalServerError"]
+	ForbiddenError          = PredefinedErrors["ForbiddenError"]
+	ServiceUnavailableError = PredefinedErrors["ServiceUnavailableError"]
+	UnauthorizedError       = PredefinedErrors["UnauthorizedError"]
+	MaintenanceError        = PredefinedErrors["MaintenanceError"]
+	TooManyRequestsError    = PredefinedErrors["TooManyRequestsError"]
 )
 
-func sendGetRequest(url string, data_obj interface{}, client *http.Client) {
-	// Use the default client if none is provided
+// upstreamErrorPage picks the error page for a failed upstream request.
+// While endpoint discovery has not succeeded the upstream is most likely
+// asleep, so the page tells the user to come back later.
+func upstreamErrorPage() ErrorPageData {
+	if currentDiscoveryStatus().Source != discoveryDiscovered {
+		return ServiceUnavailableError
+	}
+	return InternalServerError
+}
+
+// upstreamClient makes the upstream requests. Its timeout keeps a hung
+// upstream from blocking discovery, syncs and the requests waiting on them.
+var upstreamClient = &http.Client{Timeout: 15 * time.Second}
+
+func sendGetRequest(url string, data_obj interface{}, client *http.Client) error {
+	// Use the upstream client if none is provided
 	if client == nil {
-		client = http.DefaultClient
+		client = upstreamClient
 	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		fmt.Print(err.Error())
+		fmt.Println(err.Error())
+		recordUpstreamError(url, err)
+		return err
 	}
 	// req.Header.Add("x-rapidapi-key", "YOU_API_KEY")
 	res, err := client.Do(req)
 	if err != nil {
-		fmt.Print(err.Error())
+		fmt.Println(err.Error())
+		recordUpstreamError(url, err)
+		return err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("GET %s: unexpected status %s", url, res.Status)
+		fmt.Println(err.Error())
+		recordUpstreamError(url, err)
+		return err
+	}
 	body, readErr := ioutil.ReadAll(res.Body)
 	if readErr != nil {
-		fmt.Print(err.Error())
+		fmt.Println(readErr.Error())
+		recordUpstreamError(url, readErr)
+		return readErr
 	}
 
 	jsonErr := json.Unmarshal(body, &data_obj)
 	if jsonErr != nil {
-		log.Fatal(jsonErr)
+		fmt.Println(jsonErr.Error())
+		recordUpstreamError(url, jsonErr)
+		return jsonErr
 	}
-}
-
-func getApiUrls() {
-	type ApiInfo struct {
-		Artists   string `json:"artists"`
-		Locations string `json:"locations"`
-		Dates     string `json:"dates"`
-		Relations string `json:"relation"`
-	}
-
-	var data_obj ApiInfo
-	sendGetRequest(apiUrls["base"], &data_obj, nil)
-
-	apiUrls["artists"] = data_obj.Artists
-	apiUrls["dates"] = data_obj.Dates
-	apiUrls["locations"] = data_obj.Locations
-	apiUrls["relations"] = data_obj.Relations
+	return nil
 }
 
 func generateUrl(path string, desiredUrl string) (string, string, string) {
 	var url string
 	if path == "/"+desiredUrl {
-		url = apiUrls[desiredUrl]
+		url = apiUrl(desiredUrl)
 		return url, "", ""
 	} else if strings.HasPrefix(path, "/"+desiredUrl+"/") {
 		id := strings.TrimPrefix(path, "/"+desiredUrl+"/")
-		url = apiUrls[desiredUrl+"s"]
+		url = apiUrl(desiredUrl + "s")
 		return url, id, ""
 	} else {
 		return "", "", "not found"
@@ -170,7 +242,7 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"index.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
@@ -182,10 +254,16 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var data_obj []ArtistsData
-	sendGetRequest(apiUrls["artists"], &data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
 
-	tmpl.Execute(w, data_obj)
+	tmpl.Execute(w, struct {
+		Artists  []ArtistsData
+		TagCloud []tagCount
+	}{snapshot.Artists, tagCloud(snapshot.Artists)})
 }
 
 func toJson(data interface{}) string {
@@ -202,18 +280,31 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, _, errUrl := generateUrl(r.URL.Path, "artists")
+	_, _, errUrl := generateUrl(r.URL.Path, "artists")
 	if errUrl == "not found" {
 		handleErrorPage(w, r, NotFoundError)
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	if r.URL.Query().Has("export") {
+		serveExport(w, r, "artists")
+		return
+	}
+
+	query, err := parseArtistQuery(r.URL.Query())
+	if err != nil {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+
+	tmpl, err := parsePage(
 		publicUrl+"artists.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/artist_filter.html",
+		publicUrl+"templates/favorite_star.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -221,11 +312,14 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var data_obj_array []ArtistsData
-	sendGetRequest(url, &data_obj_array, nil)
-
-	var location_data_obj LocationsDataLevel1
-	sendGetRequest(apiUrls["locations"], &location_data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	// The snapshot is shared, LocationsData is filled on a copy
+	data_obj_array := slices.Clone(snapshot.Artists)
+	location_data_obj := snapshot.Locations
 
 	var unique_locations []string
 
@@ -244,6 +338,12 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	data_obj_array, listing, ok := listArtists(data_obj_array, concertCounts(snapshot.Dates), query, "/artists")
+	if !ok {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
 	jsonData, err := json.Marshal(data_obj_array)
 	if err != nil {
 		log.Fatal(err)
@@ -258,12 +358,21 @@ func handleArtists(w http.ResponseWriter, r *http.Request) {
 		Artists             []ArtistsData
 		ArtistsJsonData     string
 		UniqueLocationsData string
+		MatchingMembers     []Member
+		Listing             *artistListing
+		Stars               *favoriteStars
+		Facets              *artistFacets
+		Exports             []listingLink
 	}
 
 	var data_obj_sender = ArtistsDataForPass{
 		Artists:             data_obj_array,
 		ArtistsJsonData:     string(jsonData),
 		UniqueLocationsData: string(uniqueLocationsDataData),
+		Listing:             &listing,
+		Stars:               currentStars(r),
+		Facets:              facetsFor(snapshot.Artists, query),
+		Exports:             exportLinks(r, "/artists"),
 	}
 
 	tmpl.Execute(w, data_obj_sender)
@@ -275,13 +384,23 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, id, errUrl := generateUrl(r.URL.Path, "artist")
+	if strings.HasSuffix(r.URL.Path, "/tour.pdf") {
+		handleTourSheet(w, r)
+		return
+	}
+
+	_, id, errUrl := generateUrl(r.URL.Path, "artist")
 	if errUrl == "not found" {
 		handleErrorPage(w, r, NotFoundError)
 		return
 	}
+	artistId, err := strconv.Atoi(id)
+	if err != nil {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"artist.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
@@ -289,6 +408,9 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 		publicUrl+"templates/artist_dates.html",
 		publicUrl+"templates/artist_locations.html",
 		publicUrl+"templates/artist_relation.html",
+		publicUrl+"templates/artist_shared.html",
+		publicUrl+"templates/artist_similar.html",
+		publicUrl+"templates/favorite_star.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -296,28 +418,40 @@ func handleArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var data_obj ArtistsData
-	sendGetRequest(url+"/"+id, &data_obj, nil)
-
-	var date_data_obj DatesDataLevel2
-	sendGetRequest(data_obj.ConcertDates, &date_data_obj, nil)
-
-	var location_data_obj LocationsDataLevel2
-	sendGetRequest(data_obj.Locations, &location_data_obj, nil)
-
-	var relation_data_obj RelationsDataLevel2
-	sendGetRequest(data_obj.Relations, &relation_data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	data_obj, found := snapshot.findArtist(artistId)
+	if !found {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+	date_data_obj := snapshot.findDates(artistId)
+	location_data_obj := snapshot.findLocations(artistId)
+	relation_data_obj := snapshot.findRelation(artistId)
 
 	templateData := struct {
 		ArtistInfo      ArtistsData
 		ArtistDates     DatesDataLevel2
 		ArtistLocations LocationsDataLevel2
 		Relation        RelationsDataLevel2
+		SharedVenues    []alsoPlayedHere
+		Similar         []similarArtist
+		Stars           *favoriteStars
+		ConcertFeed     string
+		TourSheet       string
 	}{
 		ArtistInfo:      data_obj,
 		ArtistDates:     date_data_obj,
 		ArtistLocations: location_data_obj,
 		Relation:        relation_data_obj,
+		SharedVenues:    artistSharedVenues(data_obj.Id),
+		Similar:         similarArtists(snapshot, artistId, similarOnPage),
+		Stars:           currentStars(r),
+		ConcertFeed:     "/feeds/concerts.atom?artist=" + strconv.Itoa(artistId),
+		TourSheet:       "/artist/" + strconv.Itoa(artistId) + "/tour.pdf",
 	}
 
 	// fmt.Printf("%+v\n", templateData)
@@ -332,18 +466,24 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, _, errUrl := generateUrl(r.URL.Path, "locations")
+	_, _, errUrl := generateUrl(r.URL.Path, "locations")
 	if errUrl == "not found" {
 		handleErrorPage(w, r, NotFoundError)
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	if r.URL.Query().Has("export") {
+		serveExport(w, r, "locations")
+		return
+	}
+
+	tmpl, err := parsePage(
 		publicUrl+"locations.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/locations_list.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -352,18 +492,20 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var data_obj_array []ArtistsData
-	sendGetRequest(apiUrls["artists"], &data_obj_array, nil)
-
-	var data_obj LocationsDataLevel1
-	sendGetRequest(url, &data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
 
 	templateData := struct {
 		ArtistsData   []ArtistsData
 		LocationsData LocationsDataLevel1
+		Exports       []listingLink
 	}{
-		ArtistsData:   data_obj_array,
-		LocationsData: data_obj,
+		ArtistsData:   snapshot.Artists,
+		LocationsData: snapshot.Locations,
+		Exports:       exportLinks(r, "/locations"),
 	}
 
 	tmpl.Execute(w, templateData)
@@ -375,18 +517,24 @@ func handleDates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, _, errUrl := generateUrl(r.URL.Path, "dates")
+	_, _, errUrl := generateUrl(r.URL.Path, "dates")
 	if errUrl == "not found" {
 		handleErrorPage(w, r, NotFoundError)
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	if r.URL.Query().Has("export") {
+		serveExport(w, r, "dates")
+		return
+	}
+
+	tmpl, err := parsePage(
 		publicUrl+"dates.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/dates_list.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -395,18 +543,20 @@ func handleDates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var data_obj_array []ArtistsData
-	sendGetRequest(apiUrls["artists"], &data_obj_array, nil)
-
-	var data_obj DatesDataLevel1
-	sendGetRequest(url, &data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
 
 	templateData := struct {
 		ArtistsData []ArtistsData
 		DatesData   DatesDataLevel1
+		Exports     []listingLink
 	}{
-		ArtistsData: data_obj_array,
-		DatesData:   data_obj,
+		ArtistsData: snapshot.Artists,
+		DatesData:   snapshot.Dates,
+		Exports:     exportLinks(r, "/dates"),
 	}
 
 	tmpl.Execute(w, templateData)
@@ -418,12 +568,18 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	if r.URL.Query().Has("export") {
+		serveExport(w, r, "tours")
+		return
+	}
+
+	tmpl, err := parsePage(
 		publicUrl+"relations.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/relations_list.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -431,18 +587,22 @@ func handleRelations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var data_obj_array []ArtistsData
-	sendGetRequest(apiUrls["artists"], &data_obj_array, nil)
-
-	var relation_data_obj RelationsDataLevel1
-	sendGetRequest(apiUrls["relations"], &relation_data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	data_obj_array := snapshot.Artists
+	relation_data_obj := snapshot.Relations
 
 	templateData := struct {
 		ArtistsData   []ArtistsData
 		RelationsData RelationsDataLevel1
+		Exports       []listingLink
 	}{
 		ArtistsData:   data_obj_array,
 		RelationsData: relation_data_obj,
+		Exports:       exportLinks(r, "/tours"),
 	}
 
 	tmpl.Execute(w, templateData)
@@ -458,6 +618,46 @@ func handleErrorPage(w http.ResponseWriter, r *http.Request, errorType ErrorPage
 	tmpl.Execute(w, errorType)
 }
 
+// searchArtists returns the artists whose name, creation date, first album,
+// members, locations or tags contain the search text. LocationsData must be
+// filled.
+func searchArtists(artists []ArtistsData, searchText string) []ArtistsData {
+	var filteredArtists []ArtistsData
+	for _, artist := range artists {
+		alreadyAdded := false
+
+		if strings.Contains(strings.ToLower(artist.Name), strings.ToLower(searchText)) && !alreadyAdded {
+			filteredArtists = append(filteredArtists, artist)
+			alreadyAdded = true
+		} else if strings.Contains(strconv.Itoa(artist.CreationDate), strings.ToLower(searchText)) && !alreadyAdded {
+			filteredArtists = append(filteredArtists, artist)
+			alreadyAdded = true
+		} else if strings.Contains(artist.FirstAlbum, strings.ToLower(searchText)) && !alreadyAdded {
+			filteredArtists = append(filteredArtists, artist)
+			alreadyAdded = true
+		}
+		for _, member := range artist.Members {
+			if strings.Contains(strings.ToLower(member), strings.ToLower(searchText)) && !alreadyAdded {
+				filteredArtists = append(filteredArtists, artist)
+				alreadyAdded = true
+			}
+		}
+		for _, location := range artist.LocationsData {
+			if strings.Contains(strings.ToLower(location), strings.ToLower(searchText)) && !alreadyAdded {
+				filteredArtists = append(filteredArtists, artist)
+				alreadyAdded = true
+			}
+		}
+		for _, tag := range artist.Tags {
+			if strings.Contains(strings.ToLower(tag.Name), strings.ToLower(searchText)) && !alreadyAdded {
+				filteredArtists = append(filteredArtists, artist)
+				alreadyAdded = true
+			}
+		}
+	}
+	return filteredArtists
+}
+
 func handleSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		handleErrorPage(w, r, MethodNotAllowedError)
@@ -466,15 +666,24 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	searchText := r.URL.Query().Get("search_text")
 	if searchText == "" {
-		handleIndex(w, r)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	var data_obj []ArtistsData
-	sendGetRequest(apiUrls["artists"], &data_obj, nil)
+	query, err := parseArtistQuery(r.URL.Query())
+	if err != nil {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
 
-	var location_data_obj LocationsDataLevel1
-	sendGetRequest(apiUrls["locations"], &location_data_obj, nil)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	// The snapshot is shared, LocationsData is filled on a copy
+	data_obj := slices.Clone(snapshot.Artists)
+	location_data_obj := snapshot.Locations
 
 	var unique_locations []string
 
@@ -493,45 +702,36 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	var filteredArtists []ArtistsData
-	for _, artist := range data_obj {
-		alreadyAdded := false
+	filteredArtists := searchArtists(data_obj, searchText)
 
-		if strings.Contains(strings.ToLower(artist.Name), strings.ToLower(searchText)) && !alreadyAdded {
-			filteredArtists = append(filteredArtists, artist)
-			alreadyAdded = true
-		} else if strings.Contains(strconv.Itoa(artist.CreationDate), strings.ToLower(searchText)) && !alreadyAdded {
-			filteredArtists = append(filteredArtists, artist)
-			alreadyAdded = true
-		} else if strings.Contains(artist.FirstAlbum, strings.ToLower(searchText)) && !alreadyAdded {
-			filteredArtists = append(filteredArtists, artist)
-			alreadyAdded = true
-		}
-		for _, member := range artist.Members {
-			if strings.Contains(strings.ToLower(member), strings.ToLower(searchText)) && !alreadyAdded {
-				filteredArtists = append(filteredArtists, artist)
-				alreadyAdded = true
-			}
-		}
-		for _, location := range artist.LocationsData {
-			if strings.Contains(strings.ToLower(location), strings.ToLower(searchText)) && !alreadyAdded {
-				filteredArtists = append(filteredArtists, artist)
-				alreadyAdded = true
-			}
+	if len(filteredArtists) == 0 {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	// A full member name lands on the member page, partial ones are linked
+	matchingMembers := memberMatches(data_obj, searchText)
+	for _, member := range matchingMembers {
+		if strings.EqualFold(member.Name, normalizeMemberName(searchText)) {
+			http.Redirect(w, r, "/member/"+member.Slug, http.StatusSeeOther)
+			return
 		}
 	}
 
-	if len(filteredArtists) == 0 {
+	filteredArtists, listing, ok := listArtists(filteredArtists, concertCounts(snapshot.Dates), query, "/search")
+	if !ok {
 		handleErrorPage(w, r, NotFoundError)
 		return
 	}
 
-	tmpl, err := template.ParseFiles(
+	tmpl, err := parsePage(
 		publicUrl+"artists.html",
 		publicUrl+"templates/header.html",
 		publicUrl+"templates/menu.html",
 		publicUrl+"templates/hero.html",
 		publicUrl+"templates/artist_filter.html",
+		publicUrl+"templates/favorite_star.html",
+		publicUrl+"templates/export_links.html",
 		publicUrl+"templates/footer.html",
 	)
 	if err != nil {
@@ -553,21 +753,95 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
 		Artists             []ArtistsData
 		ArtistsJsonData     string
 		UniqueLocationsData string
+		MatchingMembers     []Member
+		Listing             *artistListing
+		Stars               *favoriteStars
+		Facets              *artistFacets
+		Exports             []listingLink
 	}
 
 	var data_obj_sender = ArtistsDataForPass{
 		Artists:             filteredArtists,
 		ArtistsJsonData:     string(jsonData),
 		UniqueLocationsData: string(uniqueLocationsDataData),
+		MatchingMembers:     matchingMembers,
+		Listing:             &listing,
+		Stars:               currentStars(r),
+		Facets:              facetsFor(snapshot.Artists, query),
+		Exports:             exportLinks(r, "/artists"),
 	}
 
 	tmpl.Execute(w, data_obj_sender)
 }
 
 func main() {
-	getApiUrls()
+	// The upstream, listen address and cache directory can be overridden
+	if base := os.Getenv("GROUPIE_API_BASE"); base != "" {
+		apiUrls["base"] = base
+	}
+	addr := ":8080"
+	if envAddr := os.Getenv("GROUPIE_ADDR"); envAddr != "" {
+		addr = envAddr
+	}
+	if cacheDir := os.Getenv("GROUPIE_CACHE_DIR"); cacheDir != "" {
+		artistImages.Dir = cacheDir + "/images"
+	}
+	// Upstream data, sessions, favorites, accounts and webhooks are kept in
+	// the data directory
+	dataDir := "data"
+	if envDataDir := os.Getenv("GROUPIE_DATA_DIR"); envDataDir != "" {
+		dataDir = envDataDir
+	}
+	sessionKey := []byte(os.Getenv("GROUPIE_SESSION_SECRET"))
+	if len(sessionKey) == 0 {
+		key, err := session.LoadOrCreateKey(dataDir + "/session.key")
+		if err != nil {
+			log.Fatal(err)
+		}
+		sessionKey = key
+	}
+	sessions = session.New(sessionKey)
+	// Behind a proxy serving https, cookies must not travel over plain http
+	if secure := os.Getenv("GROUPIE_SECURE_COOKIES"); secure != "" {
+		on, err := strconv.ParseBool(secure)
+		if err != nil {
+			log.Fatalf("GROUPIE_SECURE_COOKIES: %v", err)
+		}
+		sessions.Secure = on
+	}
+	store, err := datastore.Open(dataDir + "/upstream.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dataStore = store
+	if webhookStore, err = datastore.Open(dataDir + "/webhooks.json"); err != nil {
+		log.Fatal(err)
+	}
+	if tagStore, err = datastore.Open(dataDir + "/tags.json"); err != nil {
+		log.Fatal(err)
+	}
+	favoriteStore = favorites.NewFileStore(dataDir + "/favorites.json")
+	accountStore = accounts.NewFileStore(dataDir + "/accounts.json")
+	// Local corrections of the upstream data are checked before serving
+	if err := loadOverrides(dataDir + "/overrides"); err != nil {
+		log.Fatalf("reading overrides: %v", err)
+	}
+	// Artist metadata must describe known artists, the first snapshot
+	// validates it
+	if err := loadMetadata(dataDir + "/metadata"); err != nil {
+		log.Fatalf("reading artist metadata: %v", err)
+	}
+	// The admin area is only served when a token or password is set
+	adminAuth.Token = os.Getenv("GROUPIE_ADMIN_TOKEN")
+	adminAuth.User = os.Getenv("GROUPIE_ADMIN_USER")
+	adminAuth.Password = os.Getenv("GROUPIE_ADMIN_PASSWORD")
+	if adminAuth.User == "" {
+		adminAuth.User = "admin"
+	}
+
 	http.Handle("/static/", http.FileServer(http.Dir("./frontend/public/")))
 	http.Handle("/img/", http.FileServer(http.Dir("./frontend/public/")))
+	http.Handle("/img/artist/", artistImages)
 
 	http.HandleFunc("/", handleIndex)
 
@@ -575,6 +849,8 @@ func main() {
 	http.HandleFunc("/artist/", handleArtist) //for dynamic routes
 
 	http.HandleFunc("/locations", handleLocations)
+	http.HandleFunc("/location", handleLocation)
+	http.HandleFunc("/location/", handleLocation)
 
 	http.HandleFunc("/dates", handleDates)
 
@@ -582,9 +858,54 @@ func main() {
 
 	http.HandleFunc("/search", handleSearch)
 
+	http.HandleFunc("/compare", handleCompare)
+
+	http.HandleFunc("/festivals", handleFestivals)
+
+	http.HandleFunc("/tag/", handleTag)
+	http.HandleFunc("/api/tags", handleTagsJson)
+	http.HandleFunc("/api/tag/", handleTagJson)
+	http.HandleFunc("/api/artist/", handleSimilarJson)
+
+	http.HandleFunc("/members", handleMembers)
+	http.HandleFunc("/member/", handleMember)
+
+	http.HandleFunc("/stats", handleStats)
+	http.HandleFunc("/api/stats", handleStatsJson)
+
+	http.HandleFunc("/favorites", handleFavorites)
+	http.HandleFunc("/timeline", handleTimeline)
+
+	http.HandleFunc("/changes", handleChanges)
+	http.HandleFunc("/api/changes", handleChangesJson)
+	http.HandleFunc("/feeds/concerts.atom", handleConcertsFeed)
+	http.HandleFunc("/feeds/artists.atom", handleArtistsFeed)
+
+	http.HandleFunc("/register", handleRegister)
+	http.HandleFunc("/login", handleLogin)
+	http.HandleFunc("/logout", handleLogout)
+	http.HandleFunc("/account", handleAccount)
+	http.HandleFunc("/webhooks", handleWebhooks)
+
+	http.HandleFunc("/admin", handleAdmin)
+
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/readyz", handleReadyz)
+
 	// Start the server on port 8080
-	fmt.Println("Starting server on 0.0.0.0:8080")
-	err := http.ListenAndServe(":8080", nil)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Starting server on " + addr)
+	// Discovery keeps retrying while the upstream is asleep, so it runs
+	// once the server listens and /healthz can answer meanwhile
+	go func() {
+		getApiUrls()
+		go watchApiUrls()
+		watchSnapshot(snapshotRefreshInterval)
+	}()
+	err = http.Serve(listener, withMaintenance(http.DefaultServeMux))
 	if err != nil {
 		fmt.Println(err)
 	}
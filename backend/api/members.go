@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Member is a person together with every band they appear in. Members are
+// matched across bands by the slug of their normalized name.
+type Member struct {
+	Slug  string
+	Name  string
+	Bands []memberBand
+}
+
+// memberBand is one band of a member with the band's concerts.
+type memberBand struct {
+	Artist   ArtistsData
+	Concerts []Concert
+}
+
+// memberLetter is one entry of the alphabetical navigation of /members.
+type memberLetter struct {
+	Letter  string
+	Members []Member
+}
+
+// transliterations maps letters with diacritics found in band and member
+// names to their plain form, so "Motörhead" and "Motorhead" share a slug.
+var transliterations = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "ß", "ss",
+)
+
+// normalizeMemberName trims a name and collapses its inner whitespace.
+func normalizeMemberName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// memberSlug turns a name into the url slug of its member page, e.g.
+// `Ryan "Byrd" Berty` into "ryan-byrd-berty".
+func memberSlug(name string) string {
+	name = transliterations.Replace(strings.ToLower(normalizeMemberName(name)))
+	var slug strings.Builder
+	dash := false
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// buildMembers collects the members of all artists, sorted by name. The
+// first spelling of a name is the one displayed.
+func buildMembers(snapshot *dataSnapshot) []Member {
+	index := map[string]int{}
+	var members []Member
+	for _, artist := range snapshot.Artists {
+		band := memberBand{Artist: artist, Concerts: artistConcerts(artist, snapshot.findRelation(artist.Id))}
+		for _, name := range artist.Members {
+			slug := memberSlug(name)
+			if slug == "" {
+				continue
+			}
+			i, found := index[slug]
+			if !found {
+				i = len(members)
+				index[slug] = i
+				members = append(members, Member{Slug: slug, Name: normalizeMemberName(name)})
+			}
+			members[i].Bands = append(members[i].Bands, band)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Slug < members[j].Slug
+	})
+	return members
+}
+
+// findMember returns the member with the given slug.
+func findMember(members []Member, slug string) (Member, bool) {
+	for _, member := range members {
+		if member.Slug == slug {
+			return member, true
+		}
+	}
+	return Member{}, false
+}
+
+// memberLetters groups members by the first letter of their slug. Letters
+// without members are kept so the navigation shows the full alphabet;
+// names starting with anything else are grouped under "#".
+func memberLetters(members []Member) []memberLetter {
+	letters := make([]memberLetter, 0, 27)
+	for r := 'A'; r <= 'Z'; r++ {
+		letters = append(letters, memberLetter{Letter: string(r)})
+	}
+	letters = append(letters, memberLetter{Letter: "#"})
+
+	for _, member := range members {
+		i := len(letters) - 1
+		if first := member.Slug[0]; first >= 'a' && first <= 'z' {
+			i = int(first - 'a')
+		}
+		letters[i].Members = append(letters[i].Members, member)
+	}
+	return letters
+}
+
+// memberMatches returns the members of the given artists whose name
+// contains text, for linking search results to member pages.
+func memberMatches(artists []ArtistsData, text string) []Member {
+	text = strings.ToLower(normalizeMemberName(text))
+	seen := map[string]bool{}
+	var matches []Member
+	for _, artist := range artists {
+		for _, name := range artist.Members {
+			slug := memberSlug(name)
+			if seen[slug] || !strings.Contains(strings.ToLower(normalizeMemberName(name)), text) {
+				continue
+			}
+			seen[slug] = true
+			matches = append(matches, Member{Slug: slug, Name: normalizeMemberName(name)})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Slug < matches[j].Slug
+	})
+	return matches
+}
+
+func handleMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"members.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	members := buildMembers(snapshot)
+
+	templateData := struct {
+		Count   int
+		Letters []memberLetter
+	}{
+		Count:   len(members),
+		Letters: memberLetters(members),
+	}
+
+	tmpl.Execute(w, templateData)
+}
+
+func handleMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	slug := strings.TrimPrefix(r.URL.Path, "/member/")
+	if slug == "" || strings.Contains(slug, "/") {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"member.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	members := buildMembers(snapshot)
+	member, found := findMember(members, slug)
+	if !found {
+		// Names typed by hand: redirect to the canonical slug
+		if canonical := memberSlug(slug); canonical != slug {
+			if _, found := findMember(members, canonical); found {
+				http.Redirect(w, r, "/member/"+canonical, http.StatusMovedPermanently)
+				return
+			}
+		}
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	tmpl.Execute(w, member)
+}
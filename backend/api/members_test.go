@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMemberSlug(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"Mikkey Dee", "mikkey-dee"},
+		{"  Mikkey   Dee ", "mikkey-dee"},
+		{`Ryan "Byrd" Berty`, "ryan-byrd-berty"},
+		{"Patrick O'Shea", "patrick-o-shea"},
+		{"Roger Meddows-Taylor", "roger-meddows-taylor"},
+		{"Jürgen Möller", "jurgen-moller"},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		if got := memberSlug(tc.name); got != tc.expected {
+			t.Errorf("memberSlug(%q) = %q want %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildMembers(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spelling differences still end up on one page
+	snapshot.Artists[5].Members[2] = " mikkey  dee"
+
+	members := buildMembers(snapshot)
+	if len(members) != 28 {
+		t.Errorf("found %d members want 28", len(members))
+	}
+	mikkey, found := findMember(members, "mikkey-dee")
+	if !found || mikkey.Name != "Mikkey Dee" || len(mikkey.Bands) != 2 {
+		t.Fatalf("Mikkey Dee is %+v", mikkey)
+	}
+	if mikkey.Bands[0].Artist.Name != "Scorpions" || len(mikkey.Bands[0].Concerts) != 5 || mikkey.Bands[1].Artist.Name != "Motörhead" {
+		t.Errorf("Mikkey Dee plays in %+v", mikkey.Bands)
+	}
+
+	letters := memberLetters(members)
+	if len(letters) != 27 || letters[1].Letter != "B" || len(letters[1].Members) != 4 || letters[25].Members != nil {
+		t.Errorf("found %d letters, %d members under %s", len(letters), len(letters[1].Members), letters[1].Letter)
+	}
+}
+
+func TestHandleMembers(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleMembers, "GET", "/members")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `<a href="/member/mikkey-dee" class="link-body-emphasis">Mikkey Dee</a>`) {
+		t.Errorf("HandleMembers returned %v without the member links", rr.Code)
+	}
+
+	rr = serve(handleMember, "GET", "/member/mikkey-dee")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `id="band_4"`) || !strings.Contains(rr.Body.String(), `id="band_6"`) {
+		t.Errorf("HandleMember returned %v without both bands", rr.Code)
+	}
+
+	testCases := []struct {
+		method       string
+		target       string
+		expectedCode int
+	}{
+		{"GET", "/member/Mikkey%20Dee", http.StatusMovedPermanently},
+		{"GET", "/member/nobody", http.StatusNotFound},
+		{"GET", "/member/", http.StatusNotFound},
+		{"GET", "/member/mikkey-dee/bands", http.StatusNotFound},
+		{"POST", "/member/mikkey-dee", http.StatusMethodNotAllowed},
+		{"POST", "/members", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range testCases {
+		handler := handleMember
+		if tc.target == "/members" {
+			handler = handleMembers
+		}
+		if rr := serve(handler, tc.method, tc.target); rr.Code != tc.expectedCode {
+			t.Errorf("%s %s returned %v want %v", tc.method, tc.target, rr.Code, tc.expectedCode)
+		}
+	}
+}
+
+func TestSearchMembers(t *testing.T) {
+	newFakeUpstream(t)
+
+	// A full name lands on the member page
+	rr := serve(handleSearch, "GET", "/search?search_text=mikkey+dee")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/member/mikkey-dee" {
+		t.Errorf("searching a member returned %v to %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	// A partial name links the members above the artist cards
+	rr = serve(handleSearch, "GET", "/search?search_text=roger")
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `href="/member/roger-meddows-taylor"`) || !strings.Contains(body, `href="/member/roger-waters"`) {
+		t.Errorf("searching a partial name returned %v without member links", rr.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"mymain/backend/api/metadata"
+)
+
+// metadataState holds the local artist metadata merged into every snapshot
+// read from the store and what the last check found wrong with it. main
+// loads it from the metadata directory next to the data. validated tells
+// whether a snapshot was checked since the files were loaded.
+var metadataState struct {
+	sync.RWMutex
+	set       *metadata.Set
+	problems  error
+	validated bool
+}
+
+// currentMetadata returns the artist metadata in use.
+func currentMetadata() *metadata.Set {
+	metadataState.RLock()
+	defer metadataState.RUnlock()
+	return metadataState.set
+}
+
+// loadMetadata reads the artist metadata kept in dir.
+func loadMetadata(dir string) error {
+	set, err := metadata.Load(dir)
+	if err != nil {
+		return err
+	}
+	metadataState.Lock()
+	defer metadataState.Unlock()
+	metadataState.set = set
+	metadataState.validated = false
+	return nil
+}
+
+// checkMetadata looks for metadata of artists the snapshot does not have.
+// The first snapshot after the files are loaded, at startup, validates them
+// and every unknown artist is logged. The upstream may drop artists at any
+// later sync, which is warned about on /admin and in the log when the
+// warning changes. The other artists keep their metadata either way.
+// Hidden artists count as known.
+func checkMetadata(snapshot *dataSnapshot) {
+	var known []int
+	for _, artist := range snapshot.Artists {
+		known = append(known, artist.Id)
+	}
+	for _, hide := range currentOverrides().Hide {
+		known = append(known, hide.Id)
+	}
+	problems := currentMetadata().Check(known)
+
+	metadataState.Lock()
+	defer metadataState.Unlock()
+	switch {
+	case !metadataState.validated && problems != nil:
+		log.Printf("artist metadata does not match the %d upstream artists, fix these entries:", len(known))
+		for _, problem := range strings.Split(problems.Error(), "\n") {
+			log.Printf("  %s", problem)
+		}
+	case !metadataState.validated:
+		log.Printf("artist metadata matches the %d upstream artists", len(known))
+	case problems != nil && (metadataState.problems == nil || problems.Error() != metadataState.problems.Error()):
+		log.Printf("artist metadata: %v", problems)
+	}
+	metadataState.validated = true
+	metadataState.problems = problems
+}
+
+// metadataProblems returns what the last check found wrong with the
+// metadata, "" when nothing.
+func metadataProblems() string {
+	metadataState.RLock()
+	defer metadataState.RUnlock()
+	if metadataState.problems == nil {
+		return ""
+	}
+	return metadataState.problems.Error()
+}
+
+// facetOption is a value of a listing filter.
+type facetOption struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// artistFacets are the genres, countries of origin and tags the artists
+// listing can be filtered by.
+type artistFacets struct {
+	Genres  []facetOption
+	Origins []facetOption
+	Tags    []facetOption
+}
+
+// artistMetadata is the metadata of an artist as the pages show it.
+type artistMetadata struct {
+	*metadata.Artist
+	OriginName string `json:"-"`
+}
+
+// attachMetadata merges the metadata into the artists of a snapshot just
+// read from the store.
+func attachMetadata(snapshot *dataSnapshot, set *metadata.Set) {
+	for i, artist := range snapshot.Artists {
+		entry := set.Get(artist.Id)
+		if entry == nil {
+			continue
+		}
+		shown := &artistMetadata{Artist: entry}
+		if entry.Origin != "" {
+			shown.OriginName = countryName(entry.Origin)
+		}
+		snapshot.Artists[i].Metadata = shown
+	}
+}
+
+// facetsFor lists the genres, origins and tags of the artists, marking
+// those the query filters by. It is nil when no artist has any.
+func facetsFor(artists []ArtistsData, query artistQuery) *artistFacets {
+	facets := &artistFacets{}
+	for _, tag := range countTags(artists) {
+		facets.Tags = append(facets.Tags, facetOption{Value: tag.Slug, Label: tag.Name, Selected: tag.Slug == query.Tag})
+	}
+	for _, artist := range artists {
+		if artist.Metadata == nil {
+			continue
+		}
+		for _, genre := range artist.Metadata.Genres {
+			key := metadata.GenreKey(genre)
+			if !slices.ContainsFunc(facets.Genres, func(option facetOption) bool { return option.Value == key }) {
+				facets.Genres = append(facets.Genres, facetOption{Value: key, Label: genre, Selected: key == query.Genre})
+			}
+		}
+		if origin := artist.Metadata.Origin; origin != "" && !slices.ContainsFunc(facets.Origins, func(option facetOption) bool { return option.Value == origin }) {
+			facets.Origins = append(facets.Origins, facetOption{Value: origin, Label: countryName(origin), Selected: origin == query.Origin})
+		}
+	}
+	byLabel := func(a, b facetOption) int { return strings.Compare(strings.ToLower(a.Label), strings.ToLower(b.Label)) }
+	slices.SortFunc(facets.Genres, byLabel)
+	slices.SortFunc(facets.Origins, byLabel)
+	slices.SortFunc(facets.Tags, byLabel)
+	if len(facets.Genres) == 0 && len(facets.Origins) == 0 && len(facets.Tags) == 0 {
+		return nil
+	}
+	return facets
+}
@@ -0,0 +1,274 @@
+// Package metadata reads the local files that enrich the upstream artists
+// with what the upstream does not have: genres, tags, a biography, the
+// country of origin, a website, the discography, the roles of the members
+// and the years the artist was active.
+//
+// Every *.json file of the metadata directory holds a list of artists,
+// each keyed by the upstream artist id:
+//
+//	[
+//	  {
+//	    "id": 1,
+//	    "genres": ["Rock", "Glam rock"],
+//	    "tags": ["Stadium rock", "British"],
+//	    "origin": "uk",
+//	    "biography": "Formed in London in 1970.",
+//	    "website": "https://www.queenonline.com",
+//	    "active": [{"from": 1970}],
+//	    "discography": [{"title": "Queen", "year": 1973}],
+//	    "members": [{"name": "Freddie Mercury", "roles": ["vocals", "piano"], "active": [{"from": 1970, "to": 1991}]}]
+//	  }
+//	]
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Years is a period of activity. To is zero while it lasts.
+type Years struct {
+	From int `json:"from"`
+	To   int `json:"to,omitempty"`
+}
+
+func (y Years) String() string {
+	if y.To == 0 {
+		return fmt.Sprintf("%d–present", y.From)
+	}
+	if y.To == y.From {
+		return strconv.Itoa(y.From)
+	}
+	return fmt.Sprintf("%d–%d", y.From, y.To)
+}
+
+// YearsText joins periods of activity, as in "1965–1995, 2005".
+func YearsText(periods []Years) string {
+	texts := make([]string, len(periods))
+	for i, period := range periods {
+		texts[i] = period.String()
+	}
+	return strings.Join(texts, ", ")
+}
+
+// Album is a release of the discography.
+type Album struct {
+	Title string `json:"title"`
+	Year  int    `json:"year"`
+}
+
+// Member is what is known about a member, found by the name the upstream
+// lists.
+type Member struct {
+	Name   string   `json:"name"`
+	Roles  []string `json:"roles,omitempty"`
+	Active []Years  `json:"active,omitempty"`
+}
+
+// Artist is the metadata of the upstream artist Id. Origin is a country
+// slug as used in the location slugs, such as "uk" or "new_zealand".
+type Artist struct {
+	Id          int      `json:"id"`
+	Genres      []string `json:"genres,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Biography   string   `json:"biography,omitempty"`
+	Origin      string   `json:"origin,omitempty"`
+	Website     string   `json:"website,omitempty"`
+	Active      []Years  `json:"active,omitempty"`
+	Discography []Album  `json:"discography,omitempty"`
+	Members     []Member `json:"members,omitempty"`
+	Source      string   `json:"-"`
+}
+
+// ActiveText is the years the artist was active, "" when unknown.
+func (a *Artist) ActiveText() string {
+	return YearsText(a.Active)
+}
+
+// MemberDetails describes the member with the given name, as in
+// "vocals, piano · 1970–1991", "" when nothing is known.
+func (a *Artist) MemberDetails(name string) string {
+	index := slices.IndexFunc(a.Members, func(member Member) bool { return strings.EqualFold(member.Name, strings.TrimSpace(name)) })
+	if index < 0 {
+		return ""
+	}
+	member := a.Members[index]
+	var parts []string
+	if len(member.Roles) > 0 {
+		parts = append(parts, strings.Join(member.Roles, ", "))
+	}
+	if len(member.Active) > 0 {
+		parts = append(parts, YearsText(member.Active))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// HasGenre tells whether the artist has the genre, compared by GenreKey.
+func (a *Artist) HasGenre(key string) bool {
+	return slices.ContainsFunc(a.Genres, func(genre string) bool { return GenreKey(genre) == key })
+}
+
+// GenreKey is the form of a genre used in urls and filters.
+func GenreKey(genre string) string {
+	return strings.ToLower(strings.TrimSpace(genre))
+}
+
+// Set is the metadata of every file, by artist id.
+type Set struct {
+	Files   []string
+	Artists map[int]*Artist
+}
+
+// Get returns the metadata of the artist, nil when there is none.
+func (s *Set) Get(id int) *Artist {
+	if s == nil {
+		return nil
+	}
+	return s.Artists[id]
+}
+
+var countryPattern = regexp.MustCompile(`^[a-z_]+$`)
+
+// Load reads every *.json file of dir, in name order. A missing directory
+// is an empty Set. Every problem found in the files is reported.
+func Load(dir string) (*Set, error) {
+	set := &Set{Artists: map[int]*Artist{}}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	var problems []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := set.Add(filepath.Base(path), data); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Add parses the metadata file name and adds its artists to the set. An
+// artist may only be described by one file.
+func (s *Set) Add(name string, data []byte) error {
+	var artists []Artist
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&artists); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	var problems []error
+	for i := range artists {
+		artist := &artists[i]
+		artist.Source = name
+		if err := artist.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("%s: artist %d: %w", name, artist.Id, err))
+			continue
+		}
+		if other, found := s.Artists[artist.Id]; found {
+			problems = append(problems, fmt.Errorf("%s: artist %d is already described in %s", name, artist.Id, other.Source))
+			continue
+		}
+		if s.Artists == nil {
+			s.Artists = map[int]*Artist{}
+		}
+		s.Artists[artist.Id] = artist
+	}
+	s.Files = append(s.Files, name)
+	return errors.Join(problems...)
+}
+
+func validYears(periods []Years) bool {
+	for _, period := range periods {
+		if period.From < 1900 || period.From > 2100 || (period.To != 0 && period.To < period.From) || period.To > 2100 {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the values of one artist and trims its texts.
+func (a *Artist) validate() error {
+	var problems []error
+	if a.Id <= 0 {
+		problems = append(problems, errors.New("id must be a positive number"))
+	}
+	for i, genre := range a.Genres {
+		a.Genres[i] = strings.TrimSpace(genre)
+		if a.Genres[i] == "" {
+			problems = append(problems, errors.New("genres contain an empty genre"))
+		}
+	}
+	for i, tag := range a.Tags {
+		a.Tags[i] = strings.TrimSpace(tag)
+		if a.Tags[i] == "" {
+			problems = append(problems, errors.New("tags contain an empty tag"))
+		}
+	}
+	a.Biography = strings.TrimSpace(a.Biography)
+	if a.Origin != "" && !countryPattern.MatchString(a.Origin) {
+		problems = append(problems, fmt.Errorf("origin %q is not a country slug such as new_zealand", a.Origin))
+	}
+	if a.Website != "" {
+		parsed, err := url.Parse(a.Website)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems = append(problems, fmt.Errorf("website %q is not an absolute http or https url", a.Website))
+		}
+	}
+	if !validYears(a.Active) {
+		problems = append(problems, errors.New("active years must run from a year between 1900 and 2100 to a later one"))
+	}
+	for i, album := range a.Discography {
+		if strings.TrimSpace(album.Title) == "" || album.Year < 1900 || album.Year > 2100 {
+			problems = append(problems, fmt.Errorf("discography[%d] needs a title and a year", i))
+		}
+	}
+	for i, member := range a.Members {
+		if strings.TrimSpace(member.Name) == "" {
+			problems = append(problems, fmt.Errorf("members[%d] has no name", i))
+		}
+		if !validYears(member.Active) {
+			problems = append(problems, fmt.Errorf("members[%d]: active years must run from a year between 1900 and 2100 to a later one", i))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Check reports the artists of the set that are not among the known ids.
+func (s *Set) Check(known []int) error {
+	if s == nil {
+		return nil
+	}
+	var unknown []int
+	for id := range s.Artists {
+		if !slices.Contains(known, id) {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	var problems []error
+	for _, id := range unknown {
+		problems = append(problems, fmt.Errorf("%s: artist %d does not exist upstream", s.Artists[id].Source, id))
+	}
+	return errors.Join(problems...)
+}
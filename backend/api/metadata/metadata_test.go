@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"b-floyd.json": `[{"id": 3, "genres": ["Progressive rock"], "origin": "uk"}]`,
+		"a-queen.json": `[{
+			"id": 1,
+			"genres": [" Rock ", "Glam rock"],
+			"tags": ["British "],
+			"origin": "uk",
+			"biography": "  Formed in London in 1970. ",
+			"website": "https://www.queenonline.com",
+			"active": [{"from": 1970}],
+			"discography": [{"title": "Queen", "year": 1973}],
+			"members": [{"name": "Freddie Mercury", "roles": ["vocals", "piano"], "active": [{"from": 1970, "to": 1991}]}]
+		}]`,
+		"notes.txt": "not read",
+	})
+	set, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(set.Files, ",") != "a-queen.json,b-floyd.json" {
+		t.Errorf("files read in order %v", set.Files)
+	}
+	queen := set.Get(1)
+	if queen == nil || queen.Source != "a-queen.json" || queen.Genres[0] != "Rock" || queen.Tags[0] != "British" || queen.Biography != "Formed in London in 1970." {
+		t.Fatalf("Queen is %+v", queen)
+	}
+	if !queen.HasGenre("rock") || !queen.HasGenre(GenreKey("Glam Rock")) || queen.HasGenre("pop") {
+		t.Error("HasGenre does not compare by GenreKey")
+	}
+	if got := queen.ActiveText(); got != "1970–present" {
+		t.Errorf("ActiveText is %q", got)
+	}
+	if got := queen.MemberDetails("freddie mercury "); got != "vocals, piano · 1970–1991" {
+		t.Errorf("MemberDetails is %q", got)
+	}
+	if got := queen.MemberDetails("Brian May"); got != "" {
+		t.Errorf("MemberDetails of an unknown member is %q", got)
+	}
+	if set.Get(2) != nil {
+		t.Error("an artist without metadata has some")
+	}
+	var none *Set
+	if none.Get(1) != nil {
+		t.Error("a nil set has metadata")
+	}
+
+	empty, err := Load(filepath.Join(dir, "missing"))
+	if err != nil || len(empty.Artists) != 0 {
+		t.Errorf("a missing directory gives %v, %v", empty, err)
+	}
+}
+
+func TestYears(t *testing.T) {
+	for _, test := range []struct {
+		periods []Years
+		want    string
+	}{
+		{nil, ""},
+		{[]Years{{From: 1970}}, "1970–present"},
+		{[]Years{{From: 1965, To: 1995}, {From: 2005, To: 2005}}, "1965–1995, 2005"},
+	} {
+		if got := YearsText(test.periods); got != test.want {
+			t.Errorf("YearsText(%v) = %q, want %q", test.periods, got, test.want)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidFiles(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown field", `[{"id": 1, "genre": "Rock"}]`, `unknown field "genre"`},
+		{"no id", `[{"genres": ["Rock"]}]`, "id must be a positive number"},
+		{"empty genre", `[{"id": 1, "genres": ["Rock", " "]}]`, "empty genre"},
+		{"empty tag", `[{"id": 1, "tags": [""]}]`, "empty tag"},
+		{"origin", `[{"id": 1, "origin": "United Kingdom"}]`, "not a country slug"},
+		{"website", `[{"id": 1, "website": "queenonline.com"}]`, "not an absolute http or https url"},
+		{"years", `[{"id": 1, "active": [{"from": 1991, "to": 1970}]}]`, "active years"},
+		{"album", `[{"id": 1, "discography": [{"title": "Queen"}]}]`, "discography[0] needs a title and a year"},
+		{"member", `[{"id": 1, "members": [{"roles": ["bass"]}]}]`, "members[0] has no name"},
+	} {
+		_, err := Load(writeFiles(t, map[string]string{"bad.json": test.content}))
+		if err == nil || !strings.Contains(err.Error(), "bad.json") || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error is %v, want %q", test.name, err, test.want)
+		}
+	}
+
+	_, err := Load(writeFiles(t, map[string]string{
+		"a.json": `[{"id": 1, "genres": ["Rock"]}]`,
+		"b.json": `[{"id": 1, "genres": ["Pop"]}]`,
+	}))
+	if err == nil || !strings.Contains(err.Error(), "b.json: artist 1 is already described in a.json") {
+		t.Errorf("a duplicate artist gives %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	set, err := Load(writeFiles(t, map[string]string{
+		"artists.json": `[{"id": 1}, {"id": 99}, {"id": 42}]`,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Check([]int{1, 42, 99}); err != nil {
+		t.Errorf("known artists give %v", err)
+	}
+	err = set.Check([]int{1, 2, 3})
+	if err == nil || err.Error() != "artists.json: artist 42 does not exist upstream\nartists.json: artist 99 does not exist upstream" {
+		t.Errorf("unknown artists give %v", err)
+	}
+}
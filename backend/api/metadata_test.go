@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/fakeapi"
+)
+
+// useMetadata loads the given metadata files for the test.
+func useMetadata(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		metadataState.Lock()
+		metadataState.set, metadataState.problems = nil, nil
+		metadataState.Unlock()
+	})
+	if err := loadMetadata(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var testMetadata = map[string]string{
+	"artists.json": `[
+		{
+			"id": 1,
+			"genres": ["Rock", "Glam rock"],
+			"origin": "uk",
+			"biography": "Formed in London in 1970.",
+			"website": "https://www.queenonline.com",
+			"active": [{"from": 1970}],
+			"discography": [{"title": "Queen", "year": 1973}, {"title": "A Night at the Opera", "year": 1975}],
+			"members": [{"name": "Freddie Mercury", "roles": ["vocals", "piano"], "active": [{"from": 1970, "to": 1991}]}]
+		},
+		{"id": 3, "genres": ["Progressive rock", "Rock"], "origin": "uk"},
+		{"id": 4, "genres": ["Hard rock"], "origin": "germany"}
+	]`,
+}
+
+func TestArtistMetadata(t *testing.T) {
+	newFakeUpstream(t)
+	useMetadata(t, testMetadata)
+
+	body := serve(handleArtist, http.MethodGet, "/artist/1").Body.String()
+	for _, expected := range []string{
+		`href="/tag/glam-rock">Glam rock</a>`,
+		`<a href="/artists?origin=uk" id="artist_origin">UK</a>`,
+		"Active 1970–present.",
+		"Formed in London in 1970.",
+		`href="https://www.queenonline.com"`,
+		"<li>1975 · A Night at the Opera</li>",
+		"vocals, piano · 1970–1991",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("artist page does not contain %q", expected)
+		}
+	}
+	if body := serve(handleArtist, http.MethodGet, "/artist/2").Body.String(); strings.Contains(body, `id="artist_metadata"`) {
+		t.Error("the page of an artist without metadata shows some")
+	}
+
+	// The listing is filtered by genre and origin
+	for target, expected := range map[string][]string{
+		"/artists?genre=rock":                  {"Pink Floyd", "Queen"},
+		"/artists?genre=Hard+Rock":             {"Scorpions"},
+		"/artists?origin=uk&genre=glam+rock":   {"Queen"},
+		"/search?search_text=s&origin=germany": {"Scorpions"},
+	} {
+		handler := handleArtists
+		if strings.HasPrefix(target, "/search") {
+			handler = handleSearch
+		}
+		body := serve(handler, http.MethodGet, target).Body.String()
+		for _, name := range []string{"Bobby McFerrins", "Motörhead", "Pink Floyd", "Queen", "Scorpions", "SOJA"} {
+			listed := strings.Contains(body, `alt="`+name+`"`)
+			if want := strings.Contains(strings.Join(expected, ","), name); listed != want {
+				t.Errorf("%s lists %s: %v, want %v", target, name, listed, want)
+			}
+		}
+	}
+
+	// The filter form offers every genre and origin, the filtered ones selected
+	body = serve(handleArtists, http.MethodGet, "/artists?origin=germany").Body.String()
+	for _, expected := range []string{
+		`<option value="glam rock">Glam rock</option>`,
+		`<option value="progressive rock">Progressive rock</option>`,
+		`<option value="germany" selected>Germany</option>`,
+		`<option value="uk">UK</option>`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("filter form does not contain %q", expected)
+		}
+	}
+
+	// The page script filters on the metadata in the artists JSON
+	snapshot, _ := currentSnapshot()
+	data, _ := json.Marshal(snapshot.Artists)
+	var artists []map[string]any
+	json.Unmarshal(data, &artists)
+	if metadata, _ := artists[0]["metadata"].(map[string]any); metadata["origin"] != "uk" || metadata["GenreLinks"] != nil {
+		t.Errorf("the JSON of Queen has metadata %v", artists[0]["metadata"])
+	}
+	if _, found := artists[1]["metadata"]; found {
+		t.Error("the JSON of SOJA has metadata")
+	}
+}
+
+func TestCheckMetadata(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useMetadata(t, map[string]string{"artists.json": `[{"id": 1}, {"id": 4}, {"id": 99}]`})
+	if problems := metadataProblems(); problems != "" {
+		t.Errorf("checking before the first sync gives %v", problems)
+	}
+
+	// The first snapshot validates the metadata and logs every unknown
+	// artist, the known artists keep theirs
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "artist metadata does not match the 6 upstream artists") || !strings.Contains(logged.String(), "artists.json: artist 99 does not exist upstream") {
+		t.Errorf("the first check logged %q", logged.String())
+	}
+	if problems := metadataProblems(); problems != "artists.json: artist 99 does not exist upstream" {
+		t.Errorf("checking unknown artists gives %q", problems)
+	}
+	snapshot, _ := currentSnapshot()
+	if snapshot.Artists[0].Metadata == nil {
+		t.Error("Queen lost its metadata")
+	}
+
+	// An artist the upstream drops is warned about at the next sync
+	artists := fakeapi.DefaultArtists()
+	upstream.SetArtists(artists[:3])
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if problems := metadataProblems(); !strings.Contains(problems, "artist 4 does not exist upstream") {
+		t.Errorf("checking after Scorpions left gives %q", problems)
+	}
+	useFavorites(t)
+	useAdmin(t)
+	if body := adminRequest(http.MethodGet, "/admin", nil, nil).Body.String(); !strings.Contains(body, "artist 4 does not exist upstream") {
+		t.Error("the admin page does not warn about the metadata")
+	}
+
+	// Hidden artists are still known
+	upstream.SetArtists(artists)
+	useOverrides(t, map[string]string{"hide.json": `{"hide": [{"id": 4}]}`})
+	useMetadata(t, map[string]string{"artists.json": `[{"id": 4}]`})
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if problems := metadataProblems(); problems != "" {
+		t.Errorf("metadata of a hidden artist gives %q", problems)
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mymain/backend/api/overrides"
+)
+
+// overrideState holds the local corrections applied to every snapshot read
+// from the store. main loads them from the overrides directory next to the
+// data, the admin page reloads them.
+// lastError tells why the files could not be read the last time.
+var overrideState struct {
+	sync.RWMutex
+	dir       string
+	set       *overrides.Set
+	lastError string
+}
+
+// currentOverrides returns the corrections in use.
+func currentOverrides() *overrides.Set {
+	overrideState.RLock()
+	defer overrideState.RUnlock()
+	if overrideState.set == nil {
+		return &overrides.Set{}
+	}
+	return overrideState.set
+}
+
+// loadOverrides reads the corrections kept in dir. When a file has a
+// problem the corrections in use are kept.
+func loadOverrides(dir string) error {
+	set, err := overrides.Load(dir)
+	overrideState.Lock()
+	defer overrideState.Unlock()
+	overrideState.dir = dir
+	if err != nil {
+		overrideState.lastError = err.Error()
+		return err
+	}
+	overrideState.set, overrideState.lastError = set, ""
+	return nil
+}
+
+// reloadOverrides reads the corrections again and rebuilds the snapshot
+// with them, without syncing the upstream.
+func reloadOverrides() error {
+	overrideState.RLock()
+	dir := overrideState.dir
+	overrideState.RUnlock()
+	if err := loadOverrides(dir); err != nil {
+		return err
+	}
+	snapshot, err := readStore()
+	if err != nil {
+		return err
+	}
+	snapshotState.Lock()
+	defer snapshotState.Unlock()
+	snapshotState.data = snapshot
+	return nil
+}
+
+// artistLabel names an artist in the records of the admin page.
+func artistLabel(artist ArtistsData) string {
+	return fmt.Sprintf("%s (%d)", artist.Name, artist.Id)
+}
+
+// missingArtist says why a correction of the artist found nothing to change.
+func missingArtist(set *overrides.Set, id int) string {
+	if set.Hidden(id) {
+		return "the artist is hidden"
+	}
+	return "no such artist upstream"
+}
+
+// applyOverrides corrects a snapshot just read from the store and returns
+// what every correction did. Artists are hidden first, so corrections of a
+// hidden artist do nothing; concerts are added under renamed locations.
+func applyOverrides(snapshot *dataSnapshot, set *overrides.Set) []overrides.Record {
+	var records []overrides.Record
+	records = append(records, hideArtists(snapshot, set)...)
+	records = append(records, patchArtists(snapshot, set)...)
+	records = append(records, renameLocations(snapshot, set)...)
+	records = append(records, addConcerts(snapshot, set)...)
+	return records
+}
+
+func hideArtists(snapshot *dataSnapshot, set *overrides.Set) []overrides.Record {
+	var records []overrides.Record
+	for _, hide := range set.Hide {
+		record := overrides.Record{Source: hide.Source, Kind: overrides.KindHide, Target: "artist " + strconv.Itoa(hide.Id), Note: hide.Note}
+		if artist, found := snapshot.findArtist(hide.Id); found {
+			record.Target, record.Change, record.Applied = artistLabel(artist), "hidden", true
+		} else {
+			record.Change = "no such artist upstream"
+		}
+		records = append(records, record)
+	}
+
+	snapshot.Artists = slices.DeleteFunc(snapshot.Artists, func(artist ArtistsData) bool { return set.Hidden(artist.Id) })
+	snapshot.Locations.Index = slices.DeleteFunc(snapshot.Locations.Index, func(locations LocationsDataLevel2) bool { return set.Hidden(locations.Id) })
+	snapshot.Dates.Index = slices.DeleteFunc(snapshot.Dates.Index, func(dates DatesDataLevel2) bool { return set.Hidden(dates.Id) })
+	snapshot.Relations.Index = slices.DeleteFunc(snapshot.Relations.Index, func(relation RelationsDataLevel2) bool { return set.Hidden(relation.Id) })
+	return records
+}
+
+func patchArtists(snapshot *dataSnapshot, set *overrides.Set) []overrides.Record {
+	var records []overrides.Record
+	for _, patch := range set.Artists {
+		record := overrides.Record{Source: patch.Source, Kind: overrides.KindArtist, Target: "artist " + strconv.Itoa(patch.Id), Note: patch.Note}
+		index := slices.IndexFunc(snapshot.Artists, func(artist ArtistsData) bool { return artist.Id == patch.Id })
+		if index < 0 {
+			record.Change = missingArtist(set, patch.Id)
+			records = append(records, record)
+			continue
+		}
+
+		artist := &snapshot.Artists[index]
+		record.Target = artistLabel(*artist)
+		var changed []string
+		if patch.Name != nil && *patch.Name != artist.Name {
+			changed = append(changed, fmt.Sprintf("name %q → %q", artist.Name, *patch.Name))
+			artist.Name = *patch.Name
+		}
+		if patch.Image != nil && *patch.Image != artist.Image {
+			changed = append(changed, fmt.Sprintf("image %s → %s", artist.Image, *patch.Image))
+			artist.Image = *patch.Image
+		}
+		if patch.Members != nil && !slices.Equal(patch.Members, artist.Members) {
+			changed = append(changed, fmt.Sprintf("members %s → %s", strings.Join(artist.Members, ", "), strings.Join(patch.Members, ", ")))
+			artist.Members = slices.Clone(patch.Members)
+		}
+		if patch.CreationDate != nil && *patch.CreationDate != artist.CreationDate {
+			changed = append(changed, fmt.Sprintf("creation date %d → %d", artist.CreationDate, *patch.CreationDate))
+			artist.CreationDate = *patch.CreationDate
+		}
+		if patch.FirstAlbum != nil && *patch.FirstAlbum != artist.FirstAlbum {
+			changed = append(changed, fmt.Sprintf("first album %s → %s", artist.FirstAlbum, *patch.FirstAlbum))
+			artist.FirstAlbum = *patch.FirstAlbum
+		}
+		if len(changed) == 0 {
+			record.Change = "same as upstream"
+		} else {
+			record.Change, record.Applied = strings.Join(changed, "; "), true
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func renameLocations(snapshot *dataSnapshot, set *overrides.Set) []overrides.Record {
+	if len(set.Locations) == 0 {
+		return nil
+	}
+
+	moved := map[string]int{}
+	for i := range snapshot.Locations.Index {
+		var renamed []string
+		for _, location := range snapshot.Locations.Index[i].Locations {
+			if to := set.Location(location); !slices.Contains(renamed, to) {
+				renamed = append(renamed, to)
+			}
+		}
+		snapshot.Locations.Index[i].Locations = renamed
+	}
+	for i := range snapshot.Relations.Index {
+		relation := &snapshot.Relations.Index[i]
+		merged := map[string][]string{}
+		// Sorted, so merged dates keep the same order on every read
+		for _, location := range slices.Sorted(maps.Keys(relation.DatesLocations)) {
+			to := set.Location(location)
+			if to != location {
+				moved[location] += len(relation.DatesLocations[location])
+			}
+			for _, date := range relation.DatesLocations[location] {
+				if !slices.Contains(merged[to], date) {
+					merged[to] = append(merged[to], date)
+				}
+			}
+		}
+		relation.DatesLocations = merged
+	}
+
+	// Concerts moved to another location keep the time they were first seen
+	for key, at := range snapshot.FirstSeen {
+		parts := strings.Split(key, "/")
+		if len(parts) != 4 || parts[0] != "concert" {
+			continue
+		}
+		to := set.Location(parts[2])
+		if to == parts[2] {
+			continue
+		}
+		renamed := strings.Join([]string{parts[0], parts[1], to, parts[3]}, "/")
+		if seen, found := snapshot.FirstSeen[renamed]; !found || at.Before(seen) {
+			snapshot.FirstSeen[renamed] = at
+		}
+	}
+
+	var records []overrides.Record
+	for _, rename := range set.Locations {
+		record := overrides.Record{Source: rename.Source, Kind: overrides.KindLocation, Target: rename.From + " → " + rename.To, Note: rename.Note}
+		if count := moved[rename.From]; count > 0 {
+			record.Change, record.Applied = fmt.Sprintf("%d concerts moved", count), true
+		} else {
+			record.Change = "no concerts at this location upstream"
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func addConcerts(snapshot *dataSnapshot, set *overrides.Set) []overrides.Record {
+	var records []overrides.Record
+	for _, concert := range set.Concerts {
+		location := set.Location(concert.Location)
+		record := overrides.Record{Source: concert.Source, Kind: overrides.KindConcert, Note: concert.Note}
+		artist, found := snapshot.findArtist(concert.ArtistId)
+		if !found {
+			record.Target = fmt.Sprintf("artist %d at %s on %s", concert.ArtistId, location, concert.Date)
+			record.Change = missingArtist(set, concert.ArtistId)
+			records = append(records, record)
+			continue
+		}
+		record.Target = fmt.Sprintf("%s at %s on %s", artistLabel(artist), location, concert.Date)
+
+		relation := relationEntry(snapshot, artist.Id)
+		if slices.Contains(relation.DatesLocations[location], concert.Date) {
+			record.Change = "already upstream"
+			records = append(records, record)
+			continue
+		}
+		relation.DatesLocations[location] = append(relation.DatesLocations[location], concert.Date)
+		if locations := locationsEntry(snapshot, artist.Id); !slices.Contains(locations.Locations, location) {
+			locations.Locations = append(locations.Locations, location)
+		}
+		dates := datesEntry(snapshot, artist.Id)
+		dates.Dates = append(dates.Dates, concert.Date)
+
+		if date, err := parseDate(concert.Date); err == nil {
+			key := concertSeenKey(Concert{ArtistId: artist.Id, Location: location, Date: date})
+			if _, seen := snapshot.FirstSeen[key]; !seen && !concert.Since.IsZero() {
+				if snapshot.FirstSeen == nil {
+					snapshot.FirstSeen = map[string]time.Time{}
+				}
+				snapshot.FirstSeen[key] = concert.Since
+			}
+		}
+		record.Change, record.Applied = "added", true
+		records = append(records, record)
+	}
+	return records
+}
+
+// relationEntry returns the relation of the artist in the snapshot, adding
+// an empty one when the upstream has none.
+func relationEntry(snapshot *dataSnapshot, id int) *RelationsDataLevel2 {
+	index := slices.IndexFunc(snapshot.Relations.Index, func(relation RelationsDataLevel2) bool { return relation.Id == id })
+	if index < 0 {
+		snapshot.Relations.Index = append(snapshot.Relations.Index, RelationsDataLevel2{Id: id})
+		index = len(snapshot.Relations.Index) - 1
+	}
+	relation := &snapshot.Relations.Index[index]
+	if relation.DatesLocations == nil {
+		relation.DatesLocations = map[string][]string{}
+	}
+	return relation
+}
+
+// locationsEntry returns the locations of the artist in the snapshot, adding
+// an empty entry when the upstream has none.
+func locationsEntry(snapshot *dataSnapshot, id int) *LocationsDataLevel2 {
+	index := slices.IndexFunc(snapshot.Locations.Index, func(locations LocationsDataLevel2) bool { return locations.Id == id })
+	if index < 0 {
+		snapshot.Locations.Index = append(snapshot.Locations.Index, LocationsDataLevel2{Id: id})
+		index = len(snapshot.Locations.Index) - 1
+	}
+	return &snapshot.Locations.Index[index]
+}
+
+// datesEntry returns the dates of the artist in the snapshot, adding an
+// empty entry when the upstream has none.
+func datesEntry(snapshot *dataSnapshot, id int) *DatesDataLevel2 {
+	index := slices.IndexFunc(snapshot.Dates.Index, func(dates DatesDataLevel2) bool { return dates.Id == id })
+	if index < 0 {
+		snapshot.Dates.Index = append(snapshot.Dates.Index, DatesDataLevel2{Id: id})
+		index = len(snapshot.Dates.Index) - 1
+	}
+	return &snapshot.Dates.Index[index]
+}
@@ -0,0 +1,275 @@
+// Package overrides reads the local corrections applied on top of the
+// upstream data.
+//
+// Corrections are kept in JSON files, one object per file with any of these
+// lists:
+//
+//	{
+//	  "artists":   [{"id": 1, "name": "Queen", "firstAlbum": "14-12-1973", "note": "typo upstream"}],
+//	  "hide":      [{"id": 7, "note": "duplicate of 3"}],
+//	  "locations": [{"from": "north_carolina-usa", "to": "charlotte-usa"}],
+//	  "concerts":  [{"artist": 1, "location": "tokyo-japan", "date": "01-03-2020"}]
+//	}
+//
+// Artist patches replace the fields they set. Locations are renamed by
+// slug; renaming two slugs to the same one merges them. Every correction
+// remembers the file it comes from, so the admin page can tell where a
+// value was changed.
+package overrides
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of correction, as shown in Records.
+const (
+	KindArtist   = "artist"
+	KindHide     = "hide"
+	KindLocation = "location"
+	KindConcert  = "concert"
+)
+
+// ArtistPatch replaces the fields it sets on the artist with Id.
+type ArtistPatch struct {
+	Id           int      `json:"id"`
+	Name         *string  `json:"name,omitempty"`
+	Image        *string  `json:"image,omitempty"`
+	Members      []string `json:"members,omitempty"`
+	CreationDate *int     `json:"creationDate,omitempty"`
+	FirstAlbum   *string  `json:"firstAlbum,omitempty"`
+	Note         string   `json:"note,omitempty"`
+	Source       string   `json:"-"`
+}
+
+// Hide removes the artist with Id and everything about it.
+type Hide struct {
+	Id     int    `json:"id"`
+	Note   string `json:"note,omitempty"`
+	Source string `json:"-"`
+}
+
+// LocationRename replaces the location slug From by To.
+type LocationRename struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Note   string `json:"note,omitempty"`
+	Source string `json:"-"`
+}
+
+// Concert adds a concert of the artist, the date as "dd-mm-yyyy". Since is
+// when the file adding it last changed, used as the time the concert was
+// first seen.
+type Concert struct {
+	ArtistId int       `json:"artist"`
+	Location string    `json:"location"`
+	Date     string    `json:"date"`
+	Note     string    `json:"note,omitempty"`
+	Source   string    `json:"-"`
+	Since    time.Time `json:"-"`
+}
+
+// File is the content of one overrides file.
+type File struct {
+	Artists   []ArtistPatch    `json:"artists"`
+	Hide      []Hide           `json:"hide"`
+	Locations []LocationRename `json:"locations"`
+	Concerts  []Concert        `json:"concerts"`
+}
+
+// Set is the corrections of every file. The zero Set changes nothing.
+type Set struct {
+	Files     []string
+	Artists   []ArtistPatch
+	Hide      []Hide
+	Locations []LocationRename
+	Concerts  []Concert
+}
+
+// Record tells what one correction did to the current data. Applied is
+// false when it found nothing to change, Change then says why.
+type Record struct {
+	Source  string
+	Kind    string
+	Target  string
+	Change  string
+	Note    string
+	Applied bool
+}
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9_]+(-[a-z0-9_]+)+$`)
+	dateLayout  = "02-01-2006"
+)
+
+// Load reads every *.json file of dir, in name order. A missing directory
+// is an empty Set. Every problem found in the files is reported.
+func Load(dir string) (*Set, error) {
+	set := &Set{}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+
+	var problems []error
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := set.Add(filepath.Base(path), data, info.ModTime()); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if err := errors.Join(problems...); err != nil {
+		return nil, err
+	}
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Add parses the overrides file name, last changed at modified, and adds
+// its corrections to the set.
+func (s *Set) Add(name string, data []byte, modified time.Time) error {
+	var file File
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := file.validate(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	s.Files = append(s.Files, name)
+	for _, patch := range file.Artists {
+		patch.Source = name
+		s.Artists = append(s.Artists, patch)
+	}
+	for _, hide := range file.Hide {
+		hide.Source = name
+		s.Hide = append(s.Hide, hide)
+	}
+	for _, rename := range file.Locations {
+		rename.Source = name
+		s.Locations = append(s.Locations, rename)
+	}
+	for _, concert := range file.Concerts {
+		concert.Source, concert.Since = name, modified
+		s.Concerts = append(s.Concerts, concert)
+	}
+	return nil
+}
+
+// validate checks the entries of one file on their own.
+func (f File) validate() error {
+	var problems []error
+	for i, patch := range f.Artists {
+		at := fmt.Sprintf("artists[%d]", i)
+		if patch.Id <= 0 {
+			problems = append(problems, fmt.Errorf("%s: id must be a positive number", at))
+		}
+		if patch.Name != nil && strings.TrimSpace(*patch.Name) == "" {
+			problems = append(problems, fmt.Errorf("%s: name is empty", at))
+		}
+		if patch.Members != nil && slices.ContainsFunc(patch.Members, func(member string) bool { return strings.TrimSpace(member) == "" }) {
+			problems = append(problems, fmt.Errorf("%s: members contain an empty name", at))
+		}
+		if patch.CreationDate != nil && *patch.CreationDate <= 0 {
+			problems = append(problems, fmt.Errorf("%s: creationDate must be a year", at))
+		}
+		if patch.FirstAlbum != nil {
+			if _, err := time.Parse(dateLayout, *patch.FirstAlbum); err != nil {
+				problems = append(problems, fmt.Errorf("%s: firstAlbum %q is not a dd-mm-yyyy date", at, *patch.FirstAlbum))
+			}
+		}
+	}
+	for i, hide := range f.Hide {
+		if hide.Id <= 0 {
+			problems = append(problems, fmt.Errorf("hide[%d]: id must be a positive number", i))
+		}
+	}
+	for i, rename := range f.Locations {
+		at := fmt.Sprintf("locations[%d]", i)
+		for _, slug := range []string{rename.From, rename.To} {
+			if !slugPattern.MatchString(slug) {
+				problems = append(problems, fmt.Errorf("%s: %q is not a location slug such as city-country", at, slug))
+			}
+		}
+		if rename.From == rename.To {
+			problems = append(problems, fmt.Errorf("%s: %q is renamed to itself", at, rename.From))
+		}
+	}
+	for i, concert := range f.Concerts {
+		at := fmt.Sprintf("concerts[%d]", i)
+		if concert.ArtistId <= 0 {
+			problems = append(problems, fmt.Errorf("%s: artist must be a positive number", at))
+		}
+		if !slugPattern.MatchString(concert.Location) {
+			problems = append(problems, fmt.Errorf("%s: %q is not a location slug such as city-country", at, concert.Location))
+		}
+		if _, err := time.Parse(dateLayout, concert.Date); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %q is not a dd-mm-yyyy date", at, concert.Date))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Validate checks that the corrections of all files agree: a location is
+// renamed to one slug only and not to a slug that is renamed itself.
+func (s *Set) Validate() error {
+	var problems []error
+	renamed := map[string]LocationRename{}
+	for _, rename := range s.Locations {
+		if other, found := renamed[rename.From]; found && other.To != rename.To {
+			problems = append(problems, fmt.Errorf("%s: %q is renamed to %q and to %q in %s", rename.Source, rename.From, rename.To, other.To, other.Source))
+		}
+		renamed[rename.From] = rename
+	}
+	for _, rename := range s.Locations {
+		if next, found := renamed[rename.To]; found {
+			problems = append(problems, fmt.Errorf("%s: %q is renamed to %q, which %s renames to %q", rename.Source, rename.From, rename.To, next.Source, next.To))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Hidden tells whether the artist is hidden.
+func (s *Set) Hidden(id int) bool {
+	return slices.ContainsFunc(s.Hide, func(hide Hide) bool { return hide.Id == id })
+}
+
+// Location returns the slug a location is shown under.
+func (s *Set) Location(slug string) string {
+	for _, rename := range s.Locations {
+		if rename.From == slug {
+			return rename.To
+		}
+	}
+	return slug
+}
+
+// ArtistName returns the name the artist is shown under.
+func (s *Set) ArtistName(id int, name string) string {
+	for _, patch := range s.Artists {
+		if patch.Id == id && patch.Name != nil {
+			name = *patch.Name
+		}
+	}
+	return name
+}
@@ -0,0 +1,114 @@
+package overrides
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"b-locations.json": `{"locations": [{"from": "north_carolina-usa", "to": "charlotte-usa", "note": "a state, not a city"}]}`,
+		"a-artists.json": `{
+			"artists": [{"id": 1, "members": ["Freddie Mercury", "John Deacon"], "firstAlbum": "13-07-1973"}],
+			"hide": [{"id": 4}],
+			"concerts": [{"artist": 1, "location": "tokyo-japan", "date": "01-03-2020"}]
+		}`,
+		"notes.txt": "not read",
+	})
+	set, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(set.Files, ",") != "a-artists.json,b-locations.json" {
+		t.Errorf("files read in order %v", set.Files)
+	}
+	if len(set.Artists) != 1 || *set.Artists[0].FirstAlbum != "13-07-1973" || set.Artists[0].Name != nil || set.Artists[0].Source != "a-artists.json" {
+		t.Errorf("artist patches are %+v", set.Artists)
+	}
+	if concert := set.Concerts[0]; concert.Source != "a-artists.json" || concert.Since.IsZero() {
+		t.Errorf("concert is %+v", concert)
+	}
+	if !set.Hidden(4) || set.Hidden(1) {
+		t.Error("Hidden does not match the hide list")
+	}
+	if set.Location("north_carolina-usa") != "charlotte-usa" || set.Location("osaka-japan") != "osaka-japan" {
+		t.Error("Location does not rename")
+	}
+	if set.ArtistName(1, "Queen") != "Queen" {
+		t.Error("ArtistName changed a name that is not patched")
+	}
+
+	empty, err := Load(filepath.Join(dir, "missing"))
+	if err != nil || len(empty.Files) != 0 {
+		t.Errorf("missing directory gave %+v, %v", empty, err)
+	}
+}
+
+func TestLoadReportsProblems(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{"bad json", map[string]string{"a.json": `{"artists": [`}, []string{"a.json:"}},
+		{"unknown field", map[string]string{"a.json": `{"artist": []}`}, []string{`a.json: json: unknown field "artist"`}},
+		{"bad values", map[string]string{"a.json": `{
+			"artists": [{"id": 0, "name": " ", "firstAlbum": "1973"}],
+			"hide": [{"id": -1}],
+			"locations": [{"from": "Tokyo", "to": "tokyo-japan"}, {"from": "tokyo-japan", "to": "tokyo-japan"}],
+			"concerts": [{"artist": 1, "location": "tokyo-japan", "date": "2020-03-01"}]
+		}`}, []string{
+			"artists[0]: id must be a positive number",
+			"artists[0]: name is empty",
+			`artists[0]: firstAlbum "1973" is not a dd-mm-yyyy date`,
+			"hide[0]: id must be a positive number",
+			`locations[0]: "Tokyo" is not a location slug`,
+			`locations[1]: "tokyo-japan" is renamed to itself`,
+			`concerts[0]: "2020-03-01" is not a dd-mm-yyyy date`,
+		}},
+		{"both files bad", map[string]string{"a.json": `{"hide": [{"id": 0}]}`, "b.json": `{"hide": [{"id": 0}]}`}, []string{"a.json: hide[0]", "b.json: hide[0]"}},
+		{"conflicting renames", map[string]string{
+			"a.json": `{"locations": [{"from": "georgia-usa", "to": "atlanta-usa"}]}`,
+			"b.json": `{"locations": [{"from": "georgia-usa", "to": "tbilisi-georgia"}]}`,
+		}, []string{`b.json: "georgia-usa" is renamed to "tbilisi-georgia" and to "atlanta-usa" in a.json`}},
+		{"chained renames", map[string]string{
+			"a.json": `{"locations": [{"from": "georgia-usa", "to": "atlanta-usa"}, {"from": "atlanta-usa", "to": "atlanta_ga-usa"}]}`,
+		}, []string{`a.json: "georgia-usa" is renamed to "atlanta-usa", which a.json renames to "atlanta_ga-usa"`}},
+	}
+	for _, tc := range testCases {
+		_, err := Load(writeFiles(t, tc.files))
+		if err == nil {
+			t.Errorf("%s: no error", tc.name)
+			continue
+		}
+		for _, expected := range tc.expected {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: %q does not contain %q", tc.name, err, expected)
+			}
+		}
+	}
+}
+
+func TestArtistName(t *testing.T) {
+	var set Set
+	if err := set.Add("names.json", []byte(`{"artists": [{"id": 2, "name": "S.O.J.A."}]}`), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := set.ArtistName(2, "SOJA"); got != "S.O.J.A." {
+		t.Errorf("ArtistName = %q", got)
+	}
+}
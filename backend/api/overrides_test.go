@@ -0,0 +1,234 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/fakeapi"
+	"mymain/backend/api/overrides"
+)
+
+// useOverrides loads the given override files for the test.
+func useOverrides(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		overrideState.Lock()
+		overrideState.dir, overrideState.set, overrideState.lastError = "", nil, ""
+		overrideState.Unlock()
+	})
+	if err := loadOverrides(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+var testCorrections = map[string]string{
+	"queen.json": `{
+		"artists": [
+			{"id": 1, "members": ["Freddie Mercury", "Brian May", "John Deacon", "Roger Taylor"], "note": "typos upstream"},
+			{"id": 2, "name": "SOJA"},
+			{"id": 99, "name": "Nobody"}
+		],
+		"concerts": [
+			{"artist": 1, "location": "tokyo-japan", "date": "01-03-2020", "note": "announced on the radio"},
+			{"artist": 1, "location": "osaka-japan", "date": "28-01-2020"},
+			{"artist": 4, "location": "berlin-germany", "date": "01-06-2020"}
+		]
+	}`,
+	"places.json": `{
+		"hide": [{"id": 4, "note": "duplicate"}],
+		"locations": [
+			{"from": "north_carolina-usa", "to": "charlotte-usa", "note": "a state, not a city"},
+			{"from": "georgia-usa", "to": "los_angeles-usa"}
+		]
+	}`,
+}
+
+func TestOverridesAreApplied(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useOverrides(t, testCorrections)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(snapshot *dataSnapshot) {
+		t.Helper()
+		queen, _ := snapshot.findArtist(1)
+		if !slices.Contains(queen.Members, "John Deacon") || len(queen.Members) != 4 {
+			t.Errorf("Queen members are %v", queen.Members)
+		}
+		if _, found := snapshot.findArtist(4); found || len(snapshot.Artists) != 5 {
+			t.Error("the hidden artist is still there")
+		}
+		relation := snapshot.findRelation(1)
+		if _, found := relation.DatesLocations["north_carolina-usa"]; found {
+			t.Error("north_carolina-usa was not renamed")
+		}
+		if dates := relation.DatesLocations["charlotte-usa"]; len(dates) != 1 || dates[0] != "23-08-2019" {
+			t.Errorf("charlotte-usa has %v", dates)
+		}
+		if dates := relation.DatesLocations["los_angeles-usa"]; len(dates) != 2 {
+			t.Errorf("georgia-usa was not merged into los_angeles-usa: %v", dates)
+		}
+		if dates := relation.DatesLocations["tokyo-japan"]; len(dates) != 1 || dates[0] != "01-03-2020" {
+			t.Errorf("tokyo-japan has %v", dates)
+		}
+		locations := snapshot.findLocations(1).Locations
+		if !slices.Contains(locations, "tokyo-japan") || slices.Contains(locations, "georgia-usa") || len(locations) != 8 {
+			t.Errorf("Queen locations are %v", locations)
+		}
+		if !slices.Contains(snapshot.findDates(1).Dates, "01-03-2020") {
+			t.Error("the added date is not in the dates of Queen")
+		}
+		if _, found := snapshot.FirstSeen["concert/1/charlotte-usa/23-08-2019"]; !found {
+			t.Error("the renamed concert lost the time it was first seen")
+		}
+	}
+	snapshot, _ := currentSnapshot()
+	check(snapshot)
+
+	applied := map[string]bool{}
+	for _, record := range snapshot.Overrides {
+		applied[record.Kind+" "+record.Target] = record.Applied
+	}
+	expected := map[string]bool{
+		"hide Scorpions (4)": true,
+		"artist Queen (1)":   true,
+		"artist SOJA (2)":    false,
+		"artist artist 99":   false,
+		"location north_carolina-usa → charlotte-usa":      true,
+		"location georgia-usa → los_angeles-usa":           true,
+		"concert Queen (1) at tokyo-japan on 01-03-2020":   true,
+		"concert Queen (1) at osaka-japan on 28-01-2020":   false,
+		"concert artist 4 at berlin-germany on 01-06-2020": false,
+	}
+	for target, want := range expected {
+		if got, found := applied[target]; !found || got != want {
+			t.Errorf("record %q applied %v (found %v), want %v", target, got, found, want)
+		}
+	}
+
+	// The pages show the corrected data
+	if body := serve(handleArtist, http.MethodGet, "/artist/1").Body.String(); !strings.Contains(body, "John Deacon") || !strings.Contains(body, "/location/charlotte-usa") || strings.Contains(body, "north_carolina") {
+		t.Error("the artist page does not show the corrections")
+	}
+	if rr := serve(handleArtist, http.MethodGet, "/artist/4"); rr.Code != http.StatusNotFound {
+		t.Errorf("the hidden artist page returned %d", rr.Code)
+	}
+
+	// The corrections are applied again after the next sync
+	artists := fakeapi.DefaultArtists()
+	artists[0].FirstAlbum = "13-07-1973"
+	upstream.SetArtists(artists)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ = currentSnapshot()
+	check(snapshot)
+	if queen, _ := snapshot.findArtist(1); queen.FirstAlbum != "13-07-1973" {
+		t.Errorf("the upstream change was lost: %q", queen.FirstAlbum)
+	}
+}
+
+func TestOverridesInAdmin(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t)
+	useAdmin(t)
+	dir := useOverrides(t, testCorrections)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := adminRequest(http.MethodGet, "/admin", nil, nil)
+	cookies, body := rr.Result().Cookies(), rr.Body.String()
+	for _, expected := range []string{
+		"<code>places.json</code>, <code>queen.json</code>",
+		"a state, not a city",
+		"1 concerts moved",
+		"not applied: no such artist upstream",
+		"not applied: the artist is hidden",
+		"not applied: already upstream",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("admin page does not contain %q", expected)
+		}
+	}
+
+	// A broken file keeps the corrections in use
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"hide": [{"id": "four"}]}`), 0o644)
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"overrides"}}); rr.Header().Get("Location") != "/admin?done=overrides_failed" {
+		t.Errorf("reloading a broken file redirected to %q", rr.Header().Get("Location"))
+	}
+	if !currentOverrides().Hidden(4) {
+		t.Error("the corrections in use were dropped")
+	}
+	if body := adminRequest(http.MethodGet, "/admin", nil, nil).Body.String(); !strings.Contains(body, `id="override_error"`) || !strings.Contains(body, "broken.json") {
+		t.Error("admin page does not show the problem")
+	}
+
+	// Fixed files are applied without a sync
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"hide": [{"id": 3}]}`), 0o644)
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"overrides"}}); rr.Header().Get("Location") != "/admin?done=overrides" {
+		t.Errorf("reloading fixed files redirected to %q", rr.Header().Get("Location"))
+	}
+	if snapshot, _ := currentSnapshot(); len(snapshot.Artists) != 4 {
+		t.Errorf("%d artists after hiding another one", len(snapshot.Artists))
+	}
+}
+
+func TestWebhooksFollowOverrides(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useWebhooks(t)
+	useOverrides(t, map[string]string{"places.json": `{
+		"artists": [{"id": 1, "name": "Queen + Adam Lambert"}],
+		"hide": [{"id": 3}],
+		"locations": [{"from": "georgia-usa", "to": "atlanta-usa"}]
+	}`})
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	receiver := newHookReceiver(t, http.StatusOK)
+	receiver.follow(t, "a", nil, []string{"usa"})
+
+	artists := fakeapi.DefaultArtists()
+	artists[0].Concerts["georgia-usa"] = append(artists[0].Concerts["georgia-usa"], "01-09-2020")
+	artists[2].Concerts["new_york-usa"] = []string{"02-09-2020"}
+	upstream.SetArtists(artists)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	webhookQueue.Wait()
+
+	if len(receiver.payloads) != 1 || len(receiver.payloads[0].Concerts) != 1 {
+		t.Fatalf("receiver got %+v", receiver.payloads)
+	}
+	concert := receiver.payloads[0].Concerts[0]
+	if concert.ArtistName != "Queen + Adam Lambert" || concert.Location != "atlanta-usa" || concert.Place != "Atlanta, USA" {
+		t.Errorf("payload concert is %+v", concert)
+	}
+}
+
+func TestApplyOverridesWithoutCorrections(t *testing.T) {
+	newFakeUpstream(t)
+	if _, err := syncStore(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := readStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records := applyOverrides(snapshot, &overrides.Set{}); len(records) != 0 || len(snapshot.Artists) != 6 {
+		t.Errorf("the empty set changed the snapshot: %+v", records)
+	}
+}
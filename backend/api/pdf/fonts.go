@@ -0,0 +1,118 @@
+package pdf
+
+import "strings"
+
+// Widths of the printable ASCII characters, from space to tilde, in
+// thousandths of the font size, from the Adobe metrics of the fonts.
+var asciiWidths = [][95]int{
+	Regular: {
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	},
+	Bold: {
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	},
+}
+
+// latinLetters are the letters of the Latin-1 characters from À to ÿ
+// without their accents, which have the width of the plain letter. A space
+// stands for the few that are not letters.
+const latinLetters = "AAAAAAACEEEEIIIIDNOOOOO OUUUUYPsaaaaaaaceeeeiiiidnooooo ouuuuypy"
+
+// winAnsi are the characters of the Windows code page that PDF readers
+// know as WinAnsiEncoding, from 0x80 to 0x9f, besides Latin-1.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// encode turns text into the bytes of WinAnsiEncoding. Characters it
+// does not have are written as "?".
+func encode(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			out = append(out, byte(r))
+		case winAnsi[r] != 0:
+			out = append(out, winAnsi[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// charWidth is the width of a character in thousandths of the font size.
+func charWidth(font Font, r rune) int {
+	switch {
+	case r >= ' ' && r <= '~':
+		return asciiWidths[font][r-' ']
+	case r >= 0xc0 && r <= 0xff && latinLetters[r-0xc0] != ' ':
+		return charWidth(font, rune(latinLetters[r-0xc0]))
+	case r == '•':
+		return 350
+	case r == '–':
+		return 556
+	case r == '—':
+		return 1000
+	}
+	return 556
+}
+
+// Width returns how wide text is in points when written in font at size.
+func Width(font Font, size float64, text string) float64 {
+	total := 0
+	for _, r := range text {
+		total += charWidth(font, r)
+	}
+	return float64(total) * size / 1000
+}
+
+// Fit shortens text with an ellipsis until it is at most width points wide.
+func Fit(font Font, size float64, text string, width float64) string {
+	if Width(font, size, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		short := strings.TrimRight(string(runes), " ") + "…"
+		if Width(font, size, short) <= width {
+			return short
+		}
+	}
+	return ""
+}
+
+// Wrap breaks text into lines at most width points wide, between words.
+// A word wider than a line gets a line of its own.
+func Wrap(font Font, size float64, text string, width float64) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && Width(font, size, line+" "+word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
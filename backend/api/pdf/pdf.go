@@ -0,0 +1,260 @@
+// Package pdf writes simple PDF documents: pages of text in the standard
+// Helvetica fonts, lines, gray boxes and JPEG images. It needs no font
+// files, since every PDF reader has Helvetica, and no external tools.
+//
+// Coordinates are in points (1/72 inch) from the bottom left corner of the
+// page, as in PDF itself.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Size of an A4 page in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font is one of the standard fonts of the documents.
+type Font int
+
+const (
+	Regular Font = iota
+	Bold
+)
+
+// fontNames are the PDF names of the fonts, in the order of Font.
+var fontNames = []string{"Helvetica", "Helvetica-Bold"}
+
+// Image is a JPEG image added to a document, drawn by Page.Image.
+type Image struct {
+	Width  int
+	Height int
+	gray   bool
+	data   []byte
+	index  int
+}
+
+// Page is a page of a document. Its drawing operators are kept until the
+// document is written.
+type Page struct {
+	content bytes.Buffer
+	images  []*Image
+}
+
+// Document is a PDF document being built.
+type Document struct {
+	Title  string
+	pages  []*Page
+	images []*Image
+}
+
+// New returns an empty document.
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage adds an A4 page at the end of the document.
+func (d *Document) AddPage() *Page {
+	page := &Page{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Pages returns the pages added so far.
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// AddImage adds a JPEG image to the document so pages can draw it.
+func (d *Document) AddImage(data []byte) (*Image, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format != "jpeg" {
+		return nil, fmt.Errorf("pdf: %s images are not supported", format)
+	}
+	img := &Image{Width: config.Width, Height: config.Height, data: data, index: len(d.images)}
+	switch config.ColorModel {
+	case color.GrayModel:
+		img.gray = true
+	case color.YCbCrModel:
+	default:
+		return nil, fmt.Errorf("pdf: only gray and color JPEG images are supported")
+	}
+	d.images = append(d.images, img)
+	return img, nil
+}
+
+// Text writes text with its baseline starting at x, y.
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n", font+1, number(size), number(x), number(y), escape(encode(text)))
+}
+
+// Gray sets the gray level, from 0 for black to 1 for white, of what is
+// drawn next.
+func (p *Page) Gray(level float64) {
+	fmt.Fprintf(&p.content, "%s g %s G\n", number(level), number(level))
+}
+
+// Line draws a line of the given width from x1, y1 to x2, y2.
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", number(width), number(x1), number(y1), number(x2), number(y2))
+}
+
+// Box fills a rectangle with its bottom left corner at x, y.
+func (p *Page) Box(x, y, width, height float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", number(x), number(y), number(width), number(height))
+}
+
+// Image draws img in a box with its bottom left corner at x, y.
+func (p *Page) Image(img *Image, x, y, width, height float64) {
+	p.images = append(p.images, img)
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", number(width), number(height), number(x), number(y), img.index+1)
+}
+
+// number formats a coordinate without needless digits.
+func number(value float64) string {
+	text := strings.TrimRight(fmt.Sprintf("%.2f", value), "0")
+	text = strings.TrimSuffix(text, ".")
+	if text == "-0" {
+		return "0"
+	}
+	return text
+}
+
+// escape escapes the characters that end or break a PDF string.
+func escape(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch c {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n', '\r':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// writer numbers the objects of a document as it writes them and keeps
+// their offsets for the cross-reference table.
+type writer struct {
+	out     io.Writer
+	written int64
+	offsets []int64
+	err     error
+}
+
+func (w *writer) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.out, format, args...)
+	w.written += int64(n)
+	w.err = err
+}
+
+// object starts object number id, which must be the next one.
+func (w *writer) object(id int) {
+	w.offsets = append(w.offsets, w.written)
+	w.printf("%d 0 obj\n", id)
+}
+
+// stream writes an object holding a stream, with its dictionary entries.
+func (w *writer) stream(id int, entries string, data []byte) {
+	w.object(id)
+	w.printf("<< %s/Length %d >>\nstream\n", entries, len(data))
+	if w.err == nil {
+		var n int
+		n, w.err = w.out.Write(data)
+		w.written += int64(n)
+	}
+	w.printf("\nendstream\nendobj\n")
+}
+
+// compress deflates a content stream.
+func compress(data []byte) []byte {
+	var out bytes.Buffer
+	zw := zlib.NewWriter(&out)
+	zw.Write(data)
+	zw.Close()
+	return out.Bytes()
+}
+
+// WriteTo writes the document. The same document always gives the same
+// bytes.
+func (d *Document) WriteTo(out io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	// Objects: catalog, page tree, info, fonts, images, then every page
+	// followed by its content
+	const catalogId, pagesId, infoId, fontsId = 1, 2, 3, 4
+	imagesId := fontsId + len(fontNames)
+	pagesStart := imagesId + len(d.images)
+
+	w := &writer{out: out}
+	w.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	w.object(catalogId)
+	w.printf("<< /Type /Catalog /Pages %d 0 R >>\nendobj\n", pagesId)
+	w.object(pagesId)
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", pagesStart+2*i))
+	}
+	w.printf("<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(d.pages))
+	w.object(infoId)
+	w.printf("<< /Title (%s) /Producer (groupie-tracker) >>\nendobj\n", escape(encode(d.Title)))
+	for i, name := range fontNames {
+		w.object(fontsId + i)
+		w.printf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\nendobj\n", name)
+	}
+	for i, img := range d.images {
+		colors := "/DeviceRGB"
+		if img.gray {
+			colors = "/DeviceGray"
+		}
+		w.stream(imagesId+i, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode ", img.Width, img.Height, colors), img.data)
+	}
+
+	var fonts []string
+	for i := range fontNames {
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i+1, fontsId+i))
+	}
+	for i, page := range d.pages {
+		id := pagesStart + 2*i
+		var images []string
+		for _, img := range page.images {
+			entry := fmt.Sprintf("/Im%d %d 0 R", img.index+1, imagesId+img.index)
+			if !slices.Contains(images, entry) {
+				images = append(images, entry)
+			}
+		}
+		w.object(id)
+		w.printf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Contents %d 0 R /Resources << /Font << %s >> /XObject << %s >> >> >>\nendobj\n",
+			pagesId, number(A4Width), number(A4Height), id+1, strings.Join(fonts, " "), strings.Join(images, " "))
+		w.stream(id+1, "/Filter /FlateDecode ", compress(page.content.Bytes()))
+	}
+
+	xref := w.written
+	w.printf("xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		w.printf("%010d 00000 n \n", offset)
+	}
+	w.printf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, catalogId, infoId, xref)
+	return w.written, w.err
+}
@@ -0,0 +1,104 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/jpeg"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 4, 3)), nil)
+
+	doc := New("Motörhead (tour)")
+	img, err := doc.AddImage(photo.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := doc.AddPage()
+	page.Image(img, 10, 10, 40, 30)
+	page.Text(72, 700, Bold, 12, `Motörhead \ (live) – 2021`)
+	doc.AddPage().Text(72, 700, Regular, 10, "page 2")
+
+	var out bytes.Buffer
+	n, err := doc.WriteTo(&out)
+	if err != nil || n != int64(out.Len()) {
+		t.Fatalf("WriteTo wrote %d of %d bytes: %v", n, out.Len(), err)
+	}
+	data := out.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Errorf("the file is not framed as a PDF: %q...%q", data[:16], data[len(data)-16:])
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) || !bytes.Contains(data, []byte("/Width 4 /Height 3 /ColorSpace /DeviceGray")) {
+		t.Error("the page tree or the image are missing")
+	}
+	if !bytes.Contains(data, []byte("/Title (Mot\xf6rhead \\(tour\\))")) {
+		t.Error("the title is not encoded")
+	}
+
+	// Every offset of the cross-reference table points at its object
+	xref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	start, _ := strconv.Atoi(string(xref[1]))
+	lines := strings.Split(string(data[start:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for id := 1; id < count; id++ {
+		offset, _ := strconv.Atoi(strings.Fields(lines[2+id])[0])
+		if prefix := strconv.Itoa(id) + " 0 obj\n"; !bytes.HasPrefix(data[offset:], []byte(prefix)) {
+			t.Errorf("object %d is not at offset %d", id, offset)
+		}
+	}
+
+	// The content of the first page is deflated
+	stream := data[bytes.Index(data, []byte("/FlateDecode")):]
+	stream = stream[bytes.Index(stream, []byte("stream\n"))+7:]
+	reader, err := zlib.NewReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(reader)
+	if want := "BT /F2 12 Tf 72 700 Td (Mot\xf6rhead \\\\ \\(live\\) \x96 2021) Tj ET"; !strings.Contains(string(content), want) {
+		t.Errorf("the page content is %q", content)
+	}
+
+	var again bytes.Buffer
+	doc.WriteTo(&again)
+	if !bytes.Equal(again.Bytes(), data) {
+		t.Error("writing twice gives different files")
+	}
+}
+
+func TestAddImage(t *testing.T) {
+	if _, err := New("").AddImage([]byte("not an image")); err == nil {
+		t.Error("a broken image was added")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	if got := Width(Regular, 10, "Queen"); got != 30.02 {
+		t.Errorf("Queen is %v points wide", got)
+	}
+	if Width(Bold, 10, "Motörhead") != Width(Bold, 10, "Motorhead") {
+		t.Error("accented letters are not as wide as plain ones")
+	}
+	if got := encode("Beyoncé ★"); string(got) != "Beyonc\xe9 ?" {
+		t.Errorf("encode gives %q", got)
+	}
+
+	if got := Fit(Regular, 10, "Playa Del Carmen", 50); got != "Playa Del…" {
+		t.Errorf("Fit gives %q", got)
+	}
+	if got := Fit(Regular, 10, "Osaka", 50); got != "Osaka" {
+		t.Errorf("Fit shortened %q", got)
+	}
+	got := Wrap(Regular, 10, "Freddie Mercury, Brian May, John Deacon", 90)
+	if want := []string{"Freddie Mercury,", "Brian May, John", "Deacon"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrap gives %q, want %q", got, want)
+	}
+}
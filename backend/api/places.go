@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Place aggregates the concerts of a country or of a city.
+type Place struct {
+	Country     string
+	City        string
+	Name        string
+	CountryName string
+	Concerts    []Concert
+	Artists     []placeArtist
+	Cities      []Place
+}
+
+// placeArtist is an artist that played a place, with how often.
+type placeArtist struct {
+	Id       int
+	Name     string
+	Concerts int
+}
+
+// Url links the page of the place.
+func (p Place) Url() string {
+	if p.City == "" {
+		return countryUrl(p.Country)
+	}
+	return locationUrl(p.City + "-" + p.Country)
+}
+
+// First returns the earliest concert of the place.
+func (p Place) First() Concert {
+	if len(p.Concerts) == 0 {
+		return Concert{}
+	}
+	return p.Concerts[0]
+}
+
+// Last returns the latest concert of the place.
+func (p Place) Last() Concert {
+	if len(p.Concerts) == 0 {
+		return Concert{}
+	}
+	return p.Concerts[len(p.Concerts)-1]
+}
+
+// countryUrl links the page of a country slug.
+func countryUrl(country string) string {
+	return "/location/" + country
+}
+
+// locationUrl links the page of a location slug such as "osaka-japan",
+// which is /location/japan/osaka.
+func locationUrl(slug string) string {
+	city, country := splitLocation(slug)
+	if country == "" {
+		return countryUrl(city)
+	}
+	return "/location/" + country + "/" + city
+}
+
+// LocationUrl links the page of the city of the concert.
+func (c Concert) LocationUrl() string {
+	return locationUrl(c.Location)
+}
+
+// LocationUrl links the page of the overlapping city.
+func (o compareOverlap) LocationUrl() string {
+	return locationUrl(o.Location)
+}
+
+// LocationUrl links the page of the city of the event.
+func (e sharedEvent) LocationUrl() string {
+	return locationUrl(e.Location)
+}
+
+// LocationUrl links the page of the city of both appearances.
+func (a nearbyAppearance) LocationUrl() string {
+	return locationUrl(a.Location)
+}
+
+// buildPlaces groups the concerts by country and city. Countries are sorted
+// by name, their cities too; concerts are kept in date order.
+func buildPlaces(concerts []Concert) []Place {
+	countries := map[string]*Place{}
+	cities := map[string]*Place{}
+	for _, concert := range concerts {
+		country := countries[concert.Country]
+		if country == nil {
+			country = &Place{Country: concert.Country, Name: countryName(concert.Country), CountryName: countryName(concert.Country)}
+			countries[concert.Country] = country
+		}
+		country.Concerts = append(country.Concerts, concert)
+
+		city := cities[concert.Location]
+		if city == nil {
+			city = &Place{Country: concert.Country, City: concert.City, Name: locationName(concert.Location), CountryName: countryName(concert.Country)}
+			cities[concert.Location] = city
+		}
+		city.Concerts = append(city.Concerts, concert)
+	}
+
+	for _, city := range cities {
+		sortConcerts(city.Concerts)
+		city.Artists = placeArtists(city.Concerts)
+		country := countries[city.Country]
+		country.Cities = append(country.Cities, *city)
+	}
+
+	places := make([]Place, 0, len(countries))
+	for _, country := range countries {
+		sortConcerts(country.Concerts)
+		country.Artists = placeArtists(country.Concerts)
+		sort.Slice(country.Cities, func(i, j int) bool {
+			return country.Cities[i].Name < country.Cities[j].Name
+		})
+		places = append(places, *country)
+	}
+	sort.Slice(places, func(i, j int) bool {
+		return places[i].Name < places[j].Name
+	})
+	return places
+}
+
+// placeArtists counts the concerts per artist, most concerts first.
+func placeArtists(concerts []Concert) []placeArtist {
+	index := map[int]int{}
+	var artists []placeArtist
+	for _, concert := range concerts {
+		i, found := index[concert.ArtistId]
+		if !found {
+			i = len(artists)
+			index[concert.ArtistId] = i
+			artists = append(artists, placeArtist{Id: concert.ArtistId, Name: concert.ArtistName})
+		}
+		artists[i].Concerts++
+	}
+	sort.SliceStable(artists, func(i, j int) bool {
+		if artists[i].Concerts != artists[j].Concerts {
+			return artists[i].Concerts > artists[j].Concerts
+		}
+		return artists[i].Name < artists[j].Name
+	})
+	return artists
+}
+
+// findPlace returns the country, or the city of the country when city is
+// not empty.
+func findPlace(places []Place, country string, city string) (Place, bool) {
+	for _, place := range places {
+		if place.Country != country {
+			continue
+		}
+		if city == "" {
+			return place, true
+		}
+		for _, candidate := range place.Cities {
+			if candidate.City == city {
+				return candidate, true
+			}
+		}
+	}
+	return Place{}, false
+}
+
+// handleLocation serves the country index on /location, countries on
+// /location/{country} and cities on /location/{country}/{city}. Location
+// slugs as used by the upstream (/location/osaka-japan) redirect to their
+// city page, so templates can link them as they are.
+func handleLocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/location"), "/")
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+	if len(parts) == 1 && strings.Contains(parts[0], "-") {
+		http.Redirect(w, r, locationUrl(parts[0]), http.StatusMovedPermanently)
+		return
+	}
+
+	page := "location.html"
+	if path == "" {
+		page = "location_index.html"
+	}
+	tmpl, err := parsePage(
+		publicUrl+page,
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	places := buildPlaces(allConcerts(snapshot))
+
+	if path == "" {
+		tmpl.Execute(w, places)
+		return
+	}
+
+	city := ""
+	if len(parts) == 2 {
+		city = parts[1]
+	}
+	place, found := findPlace(places, parts[0], city)
+	if !found {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	tmpl.Execute(w, place)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocationUrl(t *testing.T) {
+	testCases := map[string]string{
+		"osaka-japan":             "/location/japan/osaka",
+		"playa_del_carmen-mexico": "/location/mexico/playa_del_carmen",
+		"dunedin-new_zealand":     "/location/new_zealand/dunedin",
+		"japan":                   "/location/japan",
+	}
+	for slug, expected := range testCases {
+		if got := locationUrl(slug); got != expected {
+			t.Errorf("locationUrl(%q) = %q want %q", slug, got, expected)
+		}
+	}
+}
+
+func TestLocationName(t *testing.T) {
+	testCases := map[string]string{
+		"dunedin-new_zealand": "Dunedin, New Zealand",
+		"los_angeles-usa":     "Los Angeles, USA",
+		"östersund-sweden":    "Östersund, Sweden",
+		"são_paulo-brazil":    "São Paulo, Brazil",
+	}
+	for slug, expected := range testCases {
+		if got := locationName(slug); got != expected {
+			t.Errorf("locationName(%q) = %q want %q", slug, got, expected)
+		}
+	}
+}
+
+func TestBuildPlaces(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	places := buildPlaces(allConcerts(snapshot))
+
+	var names []string
+	for _, place := range places {
+		names = append(names, place.Name)
+	}
+	expected := []string{"France", "Germany", "Indonesia", "Japan", "Mexico", "New Zealand", "UK", "USA"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("countries are %v want %v", names, expected)
+	}
+
+	germany, found := findPlace(places, "germany", "")
+	if !found || len(germany.Concerts) != 5 || len(germany.Cities) != 2 || germany.First().DateText() != "15-05-2020" || germany.Last().DateText() != "11-06-2021" {
+		t.Errorf("Germany is %d concerts in %d cities from %s to %s", len(germany.Concerts), len(germany.Cities), germany.First().DateText(), germany.Last().DateText())
+	}
+	// Scorpions played Germany most
+	if germany.Artists[0] != (placeArtist{Id: 4, Name: "Scorpions", Concerts: 3}) {
+		t.Errorf("Germany artists are %+v", germany.Artists)
+	}
+
+	london, found := findPlace(places, "uk", "london")
+	if !found || london.Name != "London, UK" || london.Url() != "/location/uk/london" || len(london.Concerts) != 3 || len(london.Artists) != 3 {
+		t.Errorf("London is %+v", london)
+	}
+	if london.First().ArtistName != "Motörhead" || london.Last().DateText() != "21-04-2020" {
+		t.Errorf("London concerts are %+v", london.Concerts)
+	}
+
+	if _, found := findPlace(places, "japan", "london"); found {
+		t.Errorf("found London in Japan")
+	}
+}
+
+func TestHandleLocation(t *testing.T) {
+	newFakeUpstream(t)
+
+	testCases := []struct {
+		method       string
+		target       string
+		expectedCode int
+		expected     string
+	}{
+		{"GET", "/location", http.StatusOK, `<a href="/location/japan/osaka">Osaka, Japan</a>`},
+		{"GET", "/location/", http.StatusOK, `id="country_new_zealand"`},
+		{"GET", "/location/japan", http.StatusOK, `<a href="/location/japan/osaka">osaka-japan</a>`},
+		{"GET", "/location/japan/osaka", http.StatusOK, `<a href="/artist/2">SOJA</a>`},
+		{"GET", "/location/osaka-japan", http.StatusMovedPermanently, ""},
+		{"GET", "/location/atlantis", http.StatusNotFound, ""},
+		{"GET", "/location/japan/paris", http.StatusNotFound, ""},
+		{"GET", "/location/japan/osaka/venue", http.StatusNotFound, ""},
+		{"POST", "/location/japan", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tc := range testCases {
+		rr := serve(handleLocation, tc.method, tc.target)
+		if rr.Code != tc.expectedCode {
+			t.Errorf("%s %s returned %v want %v", tc.method, tc.target, rr.Code, tc.expectedCode)
+		}
+		if !strings.Contains(rr.Body.String(), tc.expected) {
+			t.Errorf("%s %s does not contain %s", tc.method, tc.target, tc.expected)
+		}
+	}
+
+	if rr := serve(handleLocation, "GET", "/location/osaka-japan"); rr.Header().Get("Location") != "/location/japan/osaka" {
+		t.Errorf("location slug redirects to %q", rr.Header().Get("Location"))
+	}
+
+	// Location slugs on the other pages link the location pages
+	if rr := serve(handleArtist, "GET", "/artist/1"); !strings.Contains(rr.Body.String(), `<a href="/location/osaka-japan">osaka-japan</a>`) {
+		t.Errorf("artist page does not link its locations")
+	}
+}
@@ -0,0 +1,166 @@
+// Package session keeps anonymous visitors apart with a signed cookie.
+//
+// The cookie holds a random id and an HMAC-SHA256 signature of it, so ids
+// can not be forged or guessed, and nothing about the visitor needs to be
+// stored on the server. Values other than session ids can be signed with
+// the same key through Sign and Verify.
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultCookieName is the name of the session cookie.
+const DefaultCookieName = "gt_session"
+
+// Manager signs and reads session cookies.
+type Manager struct {
+	// CookieName is the name of the session cookie.
+	CookieName string
+	// MaxAge is how long a session cookie lives in the browser.
+	MaxAge time.Duration
+	// Secure marks the cookie for https only.
+	Secure bool
+
+	key []byte
+}
+
+// New returns a manager signing with key. An empty key is replaced by a
+// random one, which invalidates all sessions when the process restarts.
+func New(key []byte) *Manager {
+	if len(key) == 0 {
+		key = NewId()
+	}
+	return &Manager{
+		CookieName: DefaultCookieName,
+		MaxAge:     365 * 24 * time.Hour,
+		key:        key,
+	}
+}
+
+// NewId returns 32 random bytes.
+func NewId() []byte {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// LoadOrCreateKey reads the signing key stored at path, creating a random
+// one on first use so sessions survive restarts.
+func LoadOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) > 0 {
+		return key, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	key = NewId()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, key, 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Sign returns value followed by its signature.
+func (m *Manager) Sign(value string) string {
+	return value + "." + m.signature(value)
+}
+
+// Verify returns the value of a signed string if the signature matches.
+func (m *Manager) Verify(signed string) (string, bool) {
+	index := strings.LastIndex(signed, ".")
+	if index < 0 {
+		return "", false
+	}
+	value, signature := signed[:index], signed[index+1:]
+	if !hmac.Equal([]byte(signature), []byte(m.signature(value))) {
+		return "", false
+	}
+	return value, true
+}
+
+func (m *Manager) signature(value string) string {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Id returns the session id of the request, if it has a valid cookie.
+func (m *Manager) Id(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(m.CookieName)
+	if err != nil {
+		return "", false
+	}
+	return m.Verify(cookie.Value)
+}
+
+// Ensure returns the session id of the request, starting a new session
+// with a fresh cookie when there is none.
+func (m *Manager) Ensure(w http.ResponseWriter, r *http.Request) string {
+	if id, ok := m.Id(r); ok {
+		return id
+	}
+	return m.Start(w)
+}
+
+// Start begins a new session, replacing the cookie of any previous one.
+// Logging in starts a new session so an id known before can not be used
+// to ride on the login.
+func (m *Manager) Start(w http.ResponseWriter) string {
+	id := base64.RawURLEncoding.EncodeToString(NewId())
+	m.SetCookie(w, id)
+	return id
+}
+
+// CSRFToken returns the token that forms posted within the session must
+// carry.
+func (m *Manager) CSRFToken(id string) string {
+	return m.signature("csrf:" + id)
+}
+
+// CheckCSRF tells whether token is the CSRF token of the session.
+func (m *Manager) CheckCSRF(id string, token string) bool {
+	return hmac.Equal([]byte(token), []byte(m.CSRFToken(id)))
+}
+
+// SetCookie sends the signed cookie for the session id.
+func (m *Manager) SetCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.CookieName,
+		Value:    m.Sign(id),
+		Path:     "/",
+		MaxAge:   int(m.MaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   m.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Clear removes the session cookie.
+func (m *Manager) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	manager := New([]byte("secret"))
+	signed := manager.Sign("abc")
+	if value, ok := manager.Verify(signed); !ok || value != "abc" {
+		t.Errorf("Verify(%q) = %q, %v want abc, true", signed, value, ok)
+	}
+
+	testCases := []string{
+		"",
+		"abc",
+		"abd" + signed[3:],
+		signed + "x",
+		New([]byte("other")).Sign("abc"),
+	}
+	for _, tc := range testCases {
+		if value, ok := manager.Verify(tc); ok {
+			t.Errorf("Verify(%q) accepted %q", tc, value)
+		}
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	manager := New([]byte("secret"))
+
+	rr := httptest.NewRecorder()
+	id := manager.Ensure(rr, httptest.NewRequest("GET", "/", nil))
+	cookies := rr.Result().Cookies()
+	if id == "" || len(cookies) != 1 || cookies[0].Name != DefaultCookieName || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Ensure started %q with cookies %+v", id, cookies)
+	}
+
+	// The next request carries the cookie and keeps the session
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	if again := manager.Ensure(rr, req); again != id || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Ensure with the cookie returned %q want %q", again, id)
+	}
+
+	// A tampered cookie starts a new session
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: DefaultCookieName, Value: "forged." + cookies[0].Value[len(id)+1:]})
+	if _, ok := manager.Id(req); ok {
+		t.Error("Id accepted a forged cookie")
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "session.key")
+	key, err := LoadOrCreateKey(path)
+	if err != nil || len(key) != 32 {
+		t.Fatalf("LoadOrCreateKey created %d bytes, %v", len(key), err)
+	}
+	again, err := LoadOrCreateKey(path)
+	if err != nil || string(again) != string(key) {
+		t.Errorf("LoadOrCreateKey did not reuse the stored key: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file is %v, %v", info, err)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	manager := New([]byte("secret"))
+	token := manager.CSRFToken("abc")
+	if !manager.CheckCSRF("abc", token) {
+		t.Error("CheckCSRF rejected the token of the session")
+	}
+	for _, tc := range []struct{ id, token string }{
+		{"abd", token},
+		{"abc", ""},
+		{"abc", manager.Sign("abc")},
+		{"abc", New([]byte("other")).CSRFToken("abc")},
+	} {
+		if manager.CheckCSRF(tc.id, tc.token) {
+			t.Errorf("CheckCSRF(%q, %q) accepted", tc.id, tc.token)
+		}
+	}
+}
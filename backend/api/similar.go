@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mymain/backend/api/similar"
+)
+
+// Number of similar artists on the artist page and at most in the JSON.
+const (
+	similarOnPage = 4
+	maxSimilar    = 20
+)
+
+// similarArtist is a recommended artist with why it was picked.
+type similarArtist struct {
+	Artist  ArtistsData `json:"artist"`
+	Score   float64     `json:"score"`
+	Reasons []string    `json:"reasons"`
+}
+
+// similarInputs describes the artists of the snapshot for the scores.
+func similarInputs(snapshot *dataSnapshot) []similar.Artist {
+	var inputs []similar.Artist
+	for _, artist := range snapshot.Artists {
+		input := similar.Artist{Id: artist.Id, Created: artist.CreationDate, Members: len(artist.Members)}
+		relation := snapshot.findRelation(artist.Id)
+		var first, last time.Time
+		for location, dates := range relation.DatesLocations {
+			input.Cities = append(input.Cities, location)
+			for _, text := range dates {
+				date, err := parseDate(text)
+				if err != nil {
+					continue
+				}
+				if first.IsZero() || date.Before(first) {
+					first = date
+				}
+				if date.After(last) {
+					last = date
+				}
+			}
+		}
+		input.Tour = [2]time.Time{first, last}
+		for _, tag := range artist.Tags {
+			input.Tags = append(input.Tags, tag.Slug)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// similarReasons tells in words what a match has in common.
+func similarReasons(match similar.Match, tags []artistTag) []string {
+	var reasons []string
+	if count := len(match.Cities); count > 0 {
+		names := make([]string, 0, 3)
+		for _, city := range match.Cities[:min(count, 3)] {
+			names = append(names, locationName(city))
+		}
+		if count > 3 {
+			names = append(names, fmt.Sprintf("%d more", count-3))
+		}
+		reasons = append(reasons, "both played "+strings.Join(names, " · "))
+	}
+	if match.OverlapDays > 0 {
+		reasons = append(reasons, fmt.Sprintf("on tour at the same time for %d days", match.OverlapDays))
+	}
+	if len(match.Tags) > 0 {
+		var names []string
+		for _, tag := range tags {
+			for _, slug := range match.Tags {
+				if tag.Slug == slug {
+					names = append(names, tag.Name)
+				}
+			}
+		}
+		reasons = append(reasons, "both tagged "+strings.Join(names, ", "))
+	}
+	switch {
+	case match.YearsApart == 0:
+		reasons = append(reasons, "created the same year")
+	case match.YearsApart <= 5:
+		reasons = append(reasons, fmt.Sprintf("created %d years apart", match.YearsApart))
+	}
+	if match.SameMembers {
+		reasons = append(reasons, "as many members")
+	}
+	return reasons
+}
+
+// similarArtists returns the n artists most like the one with the given id.
+func similarArtists(snapshot *dataSnapshot, id int, n int) []similarArtist {
+	artist, found := snapshot.findArtist(id)
+	if !found {
+		return nil
+	}
+	var list []similarArtist
+	for _, match := range similar.Rank(similarInputs(snapshot), id, n) {
+		other, _ := snapshot.findArtist(match.Id)
+		list = append(list, similarArtist{Artist: other, Score: match.Score, Reasons: similarReasons(match, artist.Tags)})
+	}
+	return list
+}
+
+// handleSimilarJson serves /api/artist/{id}/similar, the best matches of
+// the artist; ?limit= sets how many, 5 by default.
+func handleSimilarJson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	text, found := strings.CutPrefix(r.URL.Path, "/api/artist/")
+	text, isSimilar := strings.CutSuffix(text, "/similar")
+	id, err := strconv.Atoi(text)
+	if !found || !isSimilar || err != nil {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	limit := 5
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSimilar {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be between 1 and %d", maxSimilar)})
+			return
+		}
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, found := snapshot.findArtist(id); !found {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "no such artist"})
+		return
+	}
+	list := similarArtists(snapshot, id, limit)
+	if list == nil {
+		list = []similarArtist{}
+	}
+	writeJson(w, http.StatusOK, list)
+}
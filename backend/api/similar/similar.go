@@ -0,0 +1,151 @@
+// Package similar ranks artists by how much they have in common with one
+// of them: the cities they played, when they toured, when they were
+// created, how many members they have and their tags.
+//
+// Scores only depend on the artists given, compared in a fixed order, so
+// the same data always gives the same ranking.
+package similar
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Weights of the parts of a score. They add up to 1, so scores run from 0
+// for nothing in common to 1 for the same artist.
+const (
+	citiesWeight  = 0.35
+	toursWeight   = 0.2
+	eraWeight     = 0.15
+	membersWeight = 0.1
+	tagsWeight    = 0.2
+)
+
+// eraSpan is the difference of creation years beyond which eras have
+// nothing in common.
+const eraSpan = 20
+
+// Artist is what the scores compare of an artist. Cities are location
+// slugs and Tags tag slugs, both without repeats; Tour is the first and
+// last concert, zero when there is none.
+type Artist struct {
+	Id      int
+	Cities  []string
+	Tour    [2]time.Time
+	Created int
+	Members int
+	Tags    []string
+}
+
+// Match is an artist compared with the one recommendations are made for,
+// with what they have in common.
+type Match struct {
+	Id     int
+	Score  float64
+	Cities []string
+	Tags   []string
+	// OverlapDays is how many days their tours overlap
+	OverlapDays int
+	YearsApart  int
+	SameMembers bool
+}
+
+// jaccard is the share of a and b that both have, with the shared values
+// in order.
+func jaccard(a []string, b []string) (float64, []string) {
+	var shared []string
+	union := len(b)
+	for _, value := range a {
+		if slices.Contains(b, value) {
+			shared = append(shared, value)
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0, nil
+	}
+	slices.Sort(shared)
+	return float64(len(shared)) / float64(union), shared
+}
+
+// days counts the days from a to b, both included.
+func days(a time.Time, b time.Time) int {
+	return int(b.Sub(a).Hours()/24) + 1
+}
+
+// tourOverlap is the share of the shorter tour spent while the other one
+// was on the road, with the number of shared days.
+func tourOverlap(a [2]time.Time, b [2]time.Time) (float64, int) {
+	if a[0].IsZero() || b[0].IsZero() {
+		return 0, 0
+	}
+	start, end := a[0], a[1]
+	if b[0].After(start) {
+		start = b[0]
+	}
+	if b[1].Before(end) {
+		end = b[1]
+	}
+	if end.Before(start) {
+		return 0, 0
+	}
+	overlap := days(start, end)
+	return float64(overlap) / float64(min(days(a[0], a[1]), days(b[0], b[1]))), overlap
+}
+
+// Compare scores how much b has in common with a.
+func Compare(a Artist, b Artist) Match {
+	match := Match{Id: b.Id}
+	var cities, tags, tours float64
+	cities, match.Cities = jaccard(a.Cities, b.Cities)
+	tags, match.Tags = jaccard(a.Tags, b.Tags)
+	tours, match.OverlapDays = tourOverlap(a.Tour, b.Tour)
+
+	var era float64
+	if a.Created > 0 && b.Created > 0 {
+		match.YearsApart = int(math.Abs(float64(a.Created - b.Created)))
+		era = math.Max(0, 1-float64(match.YearsApart)/eraSpan)
+	}
+	var members float64
+	if a.Members > 0 && b.Members > 0 {
+		match.SameMembers = a.Members == b.Members
+		members = 1 - math.Abs(float64(a.Members-b.Members))/float64(max(a.Members, b.Members))
+	}
+
+	score := citiesWeight*cities + toursWeight*tours + eraWeight*era + membersWeight*members + tagsWeight*tags
+	// Rounded so sums done in another order compare the same
+	match.Score = math.Round(score*1000) / 1000
+	return match
+}
+
+// Rank compares every other artist with the one of the given id and
+// returns the best n matches, best first. Equal scores are ordered by id
+// and artists with nothing in common are left out.
+func Rank(artists []Artist, id int, n int) []Match {
+	index := slices.IndexFunc(artists, func(artist Artist) bool { return artist.Id == id })
+	if index < 0 || n <= 0 {
+		return nil
+	}
+	var matches []Match
+	for _, other := range artists {
+		if other.Id == id {
+			continue
+		}
+		if match := Compare(artists[index], other); match.Score > 0 {
+			matches = append(matches, match)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Id < matches[j].Id
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
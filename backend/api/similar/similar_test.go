@@ -0,0 +1,105 @@
+package similar
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func day(text string) time.Time {
+	date, err := time.Parse("02-01-2006", text)
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
+
+func tour(first string, last string) [2]time.Time {
+	return [2]time.Time{day(first), day(last)}
+}
+
+var fixture = []Artist{
+	{Id: 1, Cities: []string{"london-uk", "paris-france", "tokyo-japan"}, Tour: tour("01-01-2020", "10-01-2020"), Created: 1970, Members: 4, Tags: []string{"rock", "british"}},
+	{Id: 2, Cities: []string{"paris-france", "tokyo-japan", "berlin-germany"}, Tour: tour("06-01-2020", "25-01-2020"), Created: 1975, Members: 4, Tags: []string{"rock"}},
+	{Id: 3, Cities: []string{"london-uk"}, Tour: tour("01-06-2021", "01-07-2021"), Created: 1995, Members: 2, Tags: []string{"british"}},
+	{Id: 4, Cities: []string{"lima-peru"}, Created: 2015, Members: 1},
+	// Like 3 in every way, only its id differs
+	{Id: 5, Cities: []string{"london-uk"}, Tour: tour("01-06-2021", "01-07-2021"), Created: 1995, Members: 2, Tags: []string{"british"}},
+}
+
+func TestCompare(t *testing.T) {
+	match := Compare(fixture[0], fixture[1])
+	// 0.35 × 2/4 cities + 0.2 × 5/10 days + 0.15 × (1 - 5/20) years + 0.1 × 4/4 members + 0.2 × 1/2 tags
+	expected := Match{Id: 2, Score: 0.588, Cities: []string{"paris-france", "tokyo-japan"}, Tags: []string{"rock"}, OverlapDays: 5, YearsApart: 5, SameMembers: true}
+	if !reflect.DeepEqual(match, expected) {
+		t.Errorf("match is %+v, want %+v", match, expected)
+	}
+
+	if match := Compare(fixture[0], fixture[0]); match.Score != 1 {
+		t.Errorf("an artist scores %v with itself", match.Score)
+	}
+	// Nothing in common: no city, no tour, 45 years and 3 members apart
+	if match := Compare(fixture[0], fixture[3]); match.Score != 0.025 || match.Cities != nil || match.OverlapDays != 0 {
+		t.Errorf("unrelated artists match %+v", match)
+	}
+	if Compare(fixture[0], fixture[2]).Score != Compare(fixture[2], fixture[0]).Score {
+		t.Error("the score depends on the order of the artists")
+	}
+}
+
+func TestTourOverlap(t *testing.T) {
+	for _, test := range []struct {
+		a, b  [2]time.Time
+		share float64
+		days  int
+	}{
+		{tour("01-01-2020", "10-01-2020"), tour("06-01-2020", "25-01-2020"), 0.5, 5},
+		{tour("01-01-2020", "31-12-2020"), tour("15-03-2020", "15-03-2020"), 1, 1},
+		{tour("01-01-2020", "10-01-2020"), tour("11-01-2020", "20-01-2020"), 0, 0},
+		{tour("01-01-2020", "10-01-2020"), [2]time.Time{}, 0, 0},
+	} {
+		if share, days := tourOverlap(test.a, test.b); share != test.share || days != test.days {
+			t.Errorf("tourOverlap(%v, %v) = %v, %d, want %v, %d", test.a, test.b, share, days, test.share, test.days)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	ids := func(matches []Match) []int {
+		var list []int
+		for _, match := range matches {
+			list = append(list, match.Id)
+		}
+		return list
+	}
+
+	if got := ids(Rank(fixture, 1, 10)); !reflect.DeepEqual(got, []int{2, 3, 5, 4}) {
+		t.Errorf("Rank of 1 is %v", got)
+	}
+	// Equal scores are ordered by id
+	if got := ids(Rank(fixture, 4, 2)); !reflect.DeepEqual(got, []int{3, 5}) {
+		t.Errorf("Rank of 4 is %v", got)
+	}
+	if Rank(fixture, 99, 3) != nil || Rank(fixture, 1, 0) != nil {
+		t.Error("an unknown artist or no room gives matches")
+	}
+
+	// The order of the artists and of their cities does not matter
+	want := Rank(fixture, 3, 4)
+	random := rand.New(rand.NewSource(1))
+	for range 20 {
+		shuffled := slices.Clone(fixture)
+		random.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		for i := range shuffled {
+			shuffled[i].Cities = slices.Clone(shuffled[i].Cities)
+			random.Shuffle(len(shuffled[i].Cities), func(a, b int) {
+				shuffled[i].Cities[a], shuffled[i].Cities[b] = shuffled[i].Cities[b], shuffled[i].Cities[a]
+			})
+		}
+		if got := Rank(shuffled, 3, 4); !reflect.DeepEqual(got, want) {
+			t.Fatalf("shuffled artists rank %+v, want %+v", got, want)
+		}
+	}
+}
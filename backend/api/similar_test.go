@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSimilarArtists(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		Id    int
+		Score float64
+	}
+	var got []result
+	for _, match := range similarArtists(snapshot, 1, 5) {
+		got = append(got, result{match.Artist.Id, match.Score})
+	}
+	expected := []result{{2, 0.358}, {3, 0.344}, {6, 0.197}, {4, 0.184}, {5, 0.112}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("artists similar to Queen are %v, want %v", got, expected)
+	}
+
+	reasons := similarArtists(snapshot, 4, 1)[0].Reasons
+	if want := []string{"both played Hamburg, Germany", "on tour at the same time for 392 days"}; !reflect.DeepEqual(reasons, want) {
+		t.Errorf("Scorpions and Motörhead have in common %q", reasons)
+	}
+	if similarArtists(snapshot, 99, 5) != nil {
+		t.Error("an unknown artist has similar artists")
+	}
+}
+
+func TestSimilarArtistsByTags(t *testing.T) {
+	newFakeUpstream(t)
+	useTags(t)
+	useMetadata(t, testTagMetadata)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ := currentSnapshot()
+
+	// Shared tags lift Pink Floyd above SOJA
+	list := similarArtists(snapshot, 1, 2)
+	if list[0].Artist.Id != 3 || !strings.Contains(strings.Join(list[0].Reasons, "|"), "both tagged Rock, British") {
+		t.Errorf("the best match of Queen is %s for %q", list[0].Artist.Name, list[0].Reasons)
+	}
+}
+
+func TestHandleSimilar(t *testing.T) {
+	newFakeUpstream(t)
+
+	body := serve(handleArtist, http.MethodGet, "/artist/1").Body.String()
+	if !strings.Contains(body, `id="similar_artists"`) || !strings.Contains(body, `id="similar_4"`) || strings.Contains(body, `id="similar_5"`) {
+		t.Error("the artist page does not show the four most similar artists")
+	}
+
+	rr := serve(handleSimilarJson, http.MethodGet, "/api/artist/1/similar?limit=2")
+	var list []struct {
+		Artist  ArtistsData `json:"artist"`
+		Score   float64     `json:"score"`
+		Reasons []string    `json:"reasons"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("the JSON returned %d: %v", rr.Code, err)
+	}
+	if len(list) != 2 || list[0].Artist.Name != "SOJA" || list[0].Score != 0.358 || len(list[0].Reasons) == 0 {
+		t.Errorf("the JSON serves %s", rr.Body.String())
+	}
+
+	for target, code := range map[string]int{
+		"/api/artist/1/similar":          http.StatusOK,
+		"/api/artist/1/similar?limit=0":  http.StatusBadRequest,
+		"/api/artist/1/similar?limit=21": http.StatusBadRequest,
+		"/api/artist/99/similar":         http.StatusNotFound,
+		"/api/artist/1":                  http.StatusNotFound,
+		"/api/artist/one/similar":        http.StatusNotFound,
+	} {
+		if rr := serve(handleSimilarJson, http.MethodGet, target); rr.Code != code {
+			t.Errorf("%s returned %d, want %d", target, rr.Code, code)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxCountryBars limits the concerts per country chart to the busiest
+// countries.
+const maxCountryBars = 15
+
+// StatsCount is one labelled value of a statistic.
+type StatsCount struct {
+	Label string `json:"label"`
+	Value int    `json:"value"`
+}
+
+// StatsHeadline holds the headline numbers shown above the charts.
+type StatsHeadline struct {
+	Artists             int     `json:"artists"`
+	Members             int     `json:"members"`
+	Concerts            int     `json:"concerts"`
+	Countries           int     `json:"countries"`
+	Cities              int     `json:"cities"`
+	AverageMembers      float64 `json:"averageMembers"`
+	OldestArtist        string  `json:"oldestArtist"`
+	OldestCreation      int     `json:"oldestCreation"`
+	EarliestAlbumArtist string  `json:"earliestAlbumArtist"`
+	EarliestAlbum       string  `json:"earliestAlbum"`
+}
+
+// Stats are the figures of the /stats page, computed from a snapshot.
+type Stats struct {
+	Headline           StatsHeadline `json:"headline"`
+	Decades            []StatsCount  `json:"decades"`
+	FirstAlbumsPerYear []StatsCount  `json:"firstAlbumsPerYear"`
+	MemberCounts       []StatsCount  `json:"memberCounts"`
+	ConcertsPerCountry []StatsCount  `json:"concertsPerCountry"`
+	BusiestMonths      []StatsCount  `json:"busiestMonths"`
+}
+
+// computeStats derives every statistic from the artists and relations of
+// the snapshot.
+func computeStats(snapshot *dataSnapshot) Stats {
+	var stats Stats
+	headline := &stats.Headline
+	headline.Artists = len(snapshot.Artists)
+
+	decades := map[int]int{}
+	albumYears := map[int]int{}
+	memberCounts := map[int]int{}
+	members := map[string]bool{}
+	var earliestAlbum time.Time
+	for _, artist := range snapshot.Artists {
+		decades[artist.CreationDate/10*10]++
+		memberCounts[len(artist.Members)]++
+		for _, member := range artist.Members {
+			members[member] = true
+		}
+		if headline.OldestCreation == 0 || artist.CreationDate < headline.OldestCreation {
+			headline.OldestCreation = artist.CreationDate
+			headline.OldestArtist = artist.Name
+		}
+		if album, err := parseDate(artist.FirstAlbum); err == nil {
+			albumYears[album.Year()]++
+			if earliestAlbum.IsZero() || album.Before(earliestAlbum) {
+				earliestAlbum = album
+				headline.EarliestAlbum = artist.FirstAlbum
+				headline.EarliestAlbumArtist = artist.Name
+			}
+		}
+	}
+	headline.Members = len(members)
+	if headline.Artists > 0 {
+		total := 0
+		for count, artists := range memberCounts {
+			total += count * artists
+		}
+		headline.AverageMembers = float64(total) / float64(headline.Artists)
+	}
+
+	countries := map[string]int{}
+	cities := map[string]bool{}
+	months := make([]int, 12)
+	concerts := allConcerts(snapshot)
+	for _, concert := range concerts {
+		countries[concert.Country]++
+		cities[concert.Location] = true
+		months[concert.Date.Month()-1]++
+	}
+	headline.Concerts = len(concerts)
+	headline.Countries = len(countries)
+	headline.Cities = len(cities)
+
+	stats.Decades = rangeCounts(decades, 10, func(decade int) string {
+		return strconv.Itoa(decade) + "s"
+	})
+	stats.FirstAlbumsPerYear = sortedCounts(albumYears)
+	stats.MemberCounts = rangeCounts(memberCounts, 1, strconv.Itoa)
+
+	for country, count := range countries {
+		stats.ConcertsPerCountry = append(stats.ConcertsPerCountry, StatsCount{Label: countryName(country), Value: count})
+	}
+	sort.Slice(stats.ConcertsPerCountry, func(i, j int) bool {
+		a, b := stats.ConcertsPerCountry[i], stats.ConcertsPerCountry[j]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		return a.Label < b.Label
+	})
+	if len(stats.ConcertsPerCountry) > maxCountryBars {
+		stats.ConcertsPerCountry = stats.ConcertsPerCountry[:maxCountryBars]
+	}
+
+	for i, count := range months {
+		stats.BusiestMonths = append(stats.BusiestMonths, StatsCount{Label: time.Month(i + 1).String(), Value: count})
+	}
+	return stats
+}
+
+// rangeCounts lists the counts from the lowest to the highest key in steps
+// of step, including the empty keys in between.
+func rangeCounts(counts map[int]int, step int, label func(int) string) []StatsCount {
+	keys := make([]int, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return []StatsCount{}
+	}
+	sort.Ints(keys)
+
+	var list []StatsCount
+	for key := keys[0]; key <= keys[len(keys)-1]; key += step {
+		list = append(list, StatsCount{Label: label(key), Value: counts[key]})
+	}
+	return list
+}
+
+// sortedCounts lists the counts of the present keys in ascending order.
+func sortedCounts(counts map[int]int) []StatsCount {
+	keys := make([]int, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	list := make([]StatsCount, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, StatsCount{Label: strconv.Itoa(key), Value: counts[key]})
+	}
+	return list
+}
+
+// Geometry of the horizontal bar charts, in SVG user units.
+const (
+	chartWidth      = 640
+	chartLabelWidth = 140
+	chartBarHeight  = 22
+	chartBarGap     = 6
+	chartValueSpace = 40
+)
+
+type chartBar struct {
+	Label  string
+	Value  int
+	Y      int
+	Width  int
+	TextY  int
+	ValueX int
+}
+
+type barChart struct {
+	Id         string
+	Title      string
+	Width      int
+	Height     int
+	LabelWidth int
+	BarHeight  int
+	Bars       []chartBar
+}
+
+// newBarChart lays out one bar per count, scaled to the largest value.
+func newBarChart(id string, title string, counts []StatsCount) barChart {
+	chart := barChart{
+		Id:         id,
+		Title:      title,
+		Width:      chartWidth,
+		Height:     len(counts)*(chartBarHeight+chartBarGap) + chartBarGap,
+		LabelWidth: chartLabelWidth,
+		BarHeight:  chartBarHeight,
+	}
+	largest := 0
+	for _, count := range counts {
+		largest = max(largest, count.Value)
+	}
+	available := chartWidth - chartLabelWidth - chartValueSpace
+	for i, count := range counts {
+		bar := chartBar{
+			Label: count.Label,
+			Value: count.Value,
+			Y:     chartBarGap + i*(chartBarHeight+chartBarGap),
+		}
+		if largest > 0 {
+			bar.Width = count.Value * available / largest
+		}
+		bar.TextY = bar.Y + chartBarHeight/2
+		bar.ValueX = chartLabelWidth + bar.Width + 6
+		chart.Bars = append(chart.Bars, bar)
+	}
+	return chart
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"stats.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/bar_chart.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	stats := computeStats(snapshot)
+
+	templateData := struct {
+		Headline StatsHeadline
+		Charts   []barChart
+	}{
+		Headline: stats.Headline,
+		Charts: []barChart{
+			newBarChart("decades", "Artists per creation decade", stats.Decades),
+			newBarChart("first-albums", "First albums per year", stats.FirstAlbumsPerYear),
+			newBarChart("members", "Artists by number of members", stats.MemberCounts),
+			newBarChart("countries", "Concerts per country", stats.ConcertsPerCountry),
+			newBarChart("months", "Concerts per month", stats.BusiestMonths),
+		},
+	}
+
+	tmpl.Execute(w, templateData)
+}
+
+func handleStatsJson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJson(w, http.StatusOK, computeStats(snapshot))
+}
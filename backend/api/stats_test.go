@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComputeStats(t *testing.T) {
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := computeStats(snapshot)
+
+	expectedHeadline := StatsHeadline{
+		Artists:             6,
+		Members:             28, // Mikkey Dee plays in two bands
+		Concerts:            26,
+		Countries:           8,
+		Cities:              16,
+		AverageMembers:      29.0 / 6,
+		OldestArtist:        "Pink Floyd",
+		OldestCreation:      1965,
+		EarliestAlbumArtist: "Pink Floyd",
+		EarliestAlbum:       "05-08-1967",
+	}
+	if stats.Headline != expectedHeadline {
+		t.Errorf("headline is %+v want %+v", stats.Headline, expectedHeadline)
+	}
+
+	testCases := []struct {
+		name     string
+		got      []StatsCount
+		expected []StatsCount
+	}{
+		{"decades", stats.Decades, []StatsCount{{"1960s", 2}, {"1970s", 3}, {"1980s", 0}, {"1990s", 1}}},
+		{"first albums", stats.FirstAlbumsPerYear, []StatsCount{{"1967", 1}, {"1972", 1}, {"1973", 1}, {"1977", 1}, {"1982", 1}, {"2002", 1}}},
+		{"member counts", stats.MemberCounts, []StatsCount{{"1", 1}, {"2", 0}, {"3", 1}, {"4", 0}, {"5", 2}, {"6", 0}, {"7", 1}, {"8", 1}}},
+		{"countries", stats.ConcertsPerCountry, []StatsCount{{"USA", 6}, {"Germany", 5}, {"Japan", 4}, {"UK", 4}, {"France", 2}, {"Mexico", 2}, {"New Zealand", 2}, {"Indonesia", 1}}},
+	}
+	for _, tc := range testCases {
+		if !reflect.DeepEqual(tc.got, tc.expected) {
+			t.Errorf("%s are %v want %v", tc.name, tc.got, tc.expected)
+		}
+	}
+
+	if len(stats.BusiestMonths) != 12 || stats.BusiestMonths[0] != (StatsCount{"January", 4}) || stats.BusiestMonths[7] != (StatsCount{"August", 5}) {
+		t.Errorf("busiest months are %v", stats.BusiestMonths)
+	}
+}
+
+func TestNewBarChart(t *testing.T) {
+	chart := newBarChart("test", "Test", []StatsCount{{"a", 4}, {"b", 2}, {"c", 0}})
+
+	if chart.Height != 3*(chartBarHeight+chartBarGap)+chartBarGap {
+		t.Errorf("chart height is %d", chart.Height)
+	}
+	full := chartWidth - chartLabelWidth - chartValueSpace
+	for i, expected := range []int{full, full / 2, 0} {
+		if chart.Bars[i].Width != expected {
+			t.Errorf("bar %d is %d wide want %d", i, chart.Bars[i].Width, expected)
+		}
+	}
+
+	if empty := newBarChart("empty", "Empty", nil); empty.Bars != nil || empty.Height != chartBarGap {
+		t.Errorf("empty chart is %+v", empty)
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	upstream := newFakeUpstream(t)
+
+	rr := serve(handleStats, "GET", "/stats")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HandleStats returned %v", rr.Code)
+	}
+	for _, expected := range []string{`<svg id="chart-decades"`, `<title>Germany: 5</title>`, `<h2 class="fw-bold">26</h2>`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("stats page does not contain %s", expected)
+		}
+	}
+
+	rr = serve(handleStatsJson, "GET", "/api/stats")
+	var stats Stats
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("stats json returned %v: %v", rr.Code, err)
+	}
+	if stats.Headline.Concerts != 26 || len(stats.Decades) != 4 {
+		t.Errorf("stats json is %+v", stats)
+	}
+
+	if rr := serve(handleStats, "POST", "/stats"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HandleStats returned %v for POST", rr.Code)
+	}
+
+	snapshotState.data = nil
+	dataStore = memoryDataStore()
+	upstream.FailPath("/api/relation", http.StatusInternalServerError)
+	if rr := serve(handleStatsJson, "GET", "/api/stats"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("stats json returned %v while upstream is down", rr.Code)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"mymain/backend/api/changes"
+	"mymain/backend/api/datastore"
+)
+
+// Buckets of the upstream data in the store. Records are keyed by artist
+// id; the meta bucket holds the outcome of the last sync.
+const (
+	artistsBucket   = "artists"
+	locationsBucket = "locations"
+	datesBucket     = "dates"
+	relationsBucket = "relations"
+	metaBucket      = "meta"
+)
+
+// dataStore keeps the upstream data so pages survive upstream outages and
+// restarts. main replaces it with one kept in the data directory.
+var dataStore = memoryDataStore()
+
+// errStoreEmpty is returned while the store has never been synced.
+var errStoreEmpty = errors.New("the data store has not been synced yet")
+
+// syncReport counts the records a sync added, changed, removed and left
+// alone, over all buckets.
+type syncReport struct {
+	SyncedAt  time.Time `json:"synced_at"`
+	Added     int       `json:"added"`
+	Updated   int       `json:"updated"`
+	Removed   int       `json:"removed"`
+	Unchanged int       `json:"unchanged"`
+	Changes   int       `json:"changes"`
+}
+
+func memoryDataStore() *datastore.DB {
+	db, _ := datastore.Open("")
+	return db
+}
+
+// syncLock lets one sync run at a time, from the fetch to the commit, so a
+// slow sync never commits older upstream data over a newer one, recording
+// and sending its changes a second time.
+var syncLock sync.Mutex
+
+// fetchUpstream fetches the complete upstream data.
+func fetchUpstream() (*dataSnapshot, error) {
+	var upstream dataSnapshot
+	if err := sendGetRequest(apiUrl("artists"), &upstream.Artists, nil); err != nil {
+		return nil, err
+	}
+	if err := sendGetRequest(apiUrl("locations"), &upstream.Locations, nil); err != nil {
+		return nil, err
+	}
+	if err := sendGetRequest(apiUrl("dates"), &upstream.Dates, nil); err != nil {
+		return nil, err
+	}
+	if err := sendGetRequest(apiUrl("relations"), &upstream.Relations, nil); err != nil {
+		return nil, err
+	}
+	return &upstream, nil
+}
+
+// syncStore pulls the upstream data and upserts it into the store. Records
+// the upstream no longer has are removed. What changed since the previous
+// sync is added to the changelog and new concerts are sent to the webhooks
+// following them; the first sync records nothing. Nothing is written unless
+// every endpoint answered.
+func syncStore() (syncReport, error) {
+	syncLock.Lock()
+	defer syncLock.Unlock()
+
+	upstream, err := fetchUpstream()
+	if err != nil {
+		return syncReport{}, err
+	}
+
+	records := map[string]map[string]interface{}{
+		artistsBucket:   {},
+		locationsBucket: {},
+		datesBucket:     {},
+		relationsBucket: {},
+	}
+	for _, artist := range upstream.Artists {
+		records[artistsBucket][strconv.Itoa(artist.Id)] = artist
+	}
+	for _, locations := range upstream.Locations.Index {
+		records[locationsBucket][strconv.Itoa(locations.Id)] = locations
+	}
+	for _, dates := range upstream.Dates.Index {
+		records[datesBucket][strconv.Itoa(dates.Id)] = dates
+	}
+	for _, relation := range upstream.Relations.Index {
+		records[relationsBucket][strconv.Itoa(relation.Id)] = relation
+	}
+
+	report := syncReport{SyncedAt: time.Now()}
+	var recorded []changes.Change
+	err = dataStore.Update(func(tx *datastore.Tx) error {
+		synced := tx.Get(metaBucket, "sync") != nil
+		var previous changes.Dump
+		if synced {
+			var err error
+			if previous, err = storedDump(tx); err != nil {
+				return err
+			}
+		}
+
+		for bucket, byKey := range records {
+			for _, key := range tx.Keys(bucket) {
+				if _, kept := byKey[key]; !kept {
+					if _, err := tx.Delete(bucket, key); err != nil {
+						return err
+					}
+					report.Removed++
+				}
+			}
+			for key, record := range byKey {
+				existed := tx.Get(bucket, key) != nil
+				changed, err := tx.PutJson(bucket, key, record)
+				if err != nil {
+					return err
+				}
+				switch {
+				case !existed:
+					report.Added++
+				case changed:
+					report.Updated++
+				default:
+					report.Unchanged++
+				}
+			}
+		}
+		if synced {
+			var err error
+			recorded, err = recordChanges(tx, changes.Diff(previous, changesDump(upstream)), report.SyncedAt)
+			if err != nil {
+				return err
+			}
+			report.Changes = len(recorded)
+		}
+		if err := markSeen(tx, upstream, report.SyncedAt); err != nil {
+			return err
+		}
+		_, err := tx.PutJson(metaBucket, "sync", report)
+		return err
+	})
+	if err != nil {
+		return syncReport{}, err
+	}
+	notifyWebhooks(recorded)
+	return report, nil
+}
+
+// readStore builds a snapshot of the stored data with the local corrections
+// applied and the artist metadata and tags merged. LoadedAt is the time of the last successful sync.
+func readStore() (*dataSnapshot, error) {
+	var snapshot dataSnapshot
+	err := dataStore.View(func(tx *datastore.Tx) error {
+		var report syncReport
+		found, err := tx.GetJson(metaBucket, "sync", &report)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return errStoreEmpty
+		}
+		snapshot.LoadedAt = report.SyncedAt
+
+		return errors.Join(
+			readSeen(tx, &snapshot),
+			readBucket(tx, artistsBucket, func(record []byte) error {
+				var artist ArtistsData
+				if err := json.Unmarshal(record, &artist); err != nil {
+					return err
+				}
+				snapshot.Artists = append(snapshot.Artists, artist)
+				return nil
+			}),
+			readBucket(tx, locationsBucket, func(record []byte) error {
+				var locations LocationsDataLevel2
+				if err := json.Unmarshal(record, &locations); err != nil {
+					return err
+				}
+				snapshot.Locations.Index = append(snapshot.Locations.Index, locations)
+				return nil
+			}),
+			readBucket(tx, datesBucket, func(record []byte) error {
+				var dates DatesDataLevel2
+				if err := json.Unmarshal(record, &dates); err != nil {
+					return err
+				}
+				snapshot.Dates.Index = append(snapshot.Dates.Index, dates)
+				return nil
+			}),
+			readBucket(tx, relationsBucket, func(record []byte) error {
+				var relation RelationsDataLevel2
+				if err := json.Unmarshal(record, &relation); err != nil {
+					return err
+				}
+				snapshot.Relations.Index = append(snapshot.Relations.Index, relation)
+				return nil
+			}),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys sort as text, the upstream lists by id
+	sort.Slice(snapshot.Artists, func(i, j int) bool { return snapshot.Artists[i].Id < snapshot.Artists[j].Id })
+	sort.Slice(snapshot.Locations.Index, func(i, j int) bool { return snapshot.Locations.Index[i].Id < snapshot.Locations.Index[j].Id })
+	sort.Slice(snapshot.Dates.Index, func(i, j int) bool { return snapshot.Dates.Index[i].Id < snapshot.Dates.Index[j].Id })
+	sort.Slice(snapshot.Relations.Index, func(i, j int) bool { return snapshot.Relations.Index[i].Id < snapshot.Relations.Index[j].Id })
+	snapshot.Overrides = applyOverrides(&snapshot, currentOverrides())
+	attachMetadata(&snapshot, currentMetadata())
+	attachTags(&snapshot, currentMetadata(), adminTags())
+	return &snapshot, nil
+}
+
+// readBucket passes every record of the bucket to add.
+func readBucket(tx *datastore.Tx, bucket string, add func(record []byte) error) error {
+	for _, key := range tx.Keys(bucket) {
+		if err := add(tx.Get(bucket, key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mymain/backend/api/datastore"
+	"mymain/backend/api/fakeapi"
+)
+
+func TestSyncStore(t *testing.T) {
+	upstream := newFakeUpstream(t)
+
+	report, err := syncStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Six artists with their locations, dates and relations
+	if report.Added != 24 || report.Updated != 0 || report.Removed != 0 || report.Unchanged != 0 {
+		t.Errorf("first sync reported %+v", report)
+	}
+	if report, _ = syncStore(); report.Added != 0 || report.Unchanged != 24 {
+		t.Errorf("second sync reported %+v", report)
+	}
+
+	// Renaming an artist changes its record, dropping one removes four
+	artists := fakeapi.DefaultArtists()
+	artists[0].Name = "Queen + Adam Lambert"
+	upstream.SetArtists(artists[:5])
+	report, err = syncStore()
+	if err != nil || report.Updated != 1 || report.Removed != 4 || report.Unchanged != 19 {
+		t.Errorf("sync after the upstream changed reported %+v, %v", report, err)
+	}
+
+	snapshot, err := readStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Artists) != 5 || snapshot.Artists[0].Name != "Queen + Adam Lambert" || len(snapshot.Relations.Index) != 5 {
+		t.Errorf("store holds %d artists, the first %q", len(snapshot.Artists), snapshot.Artists[0].Name)
+	}
+
+	// A failing endpoint writes nothing
+	upstream.SetArtists(nil)
+	upstream.FailPath("/api/dates", http.StatusBadGateway)
+	if _, err := syncStore(); err == nil {
+		t.Fatal("sync succeeded with a failing endpoint")
+	}
+	if snapshot, _ := readStore(); len(snapshot.Artists) != 5 {
+		t.Errorf("failed sync left %d artists", len(snapshot.Artists))
+	}
+}
+
+func TestReadStoreEmpty(t *testing.T) {
+	newFakeUpstream(t)
+	if _, err := readStore(); err != errStoreEmpty {
+		t.Errorf("readStore of an empty store returned %v", err)
+	}
+}
+
+func TestStoreSurvivesUpstreamOutage(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	path := filepath.Join(t.TempDir(), "upstream.json")
+	store, err := datastore.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataStore = store
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the upstream is down, the stored copy is served
+	store, err = datastore.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataStore = store
+	snapshotState.data = nil
+	upstream.FailPath("/api/artists", http.StatusServiceUnavailable)
+
+	for target, handler := range map[string]http.HandlerFunc{
+		"/":         handleIndex,
+		"/artists":  handleArtists,
+		"/artist/3": handleArtist,
+		"/tours":    handleRelations,
+		"/stats":    handleStats,
+	} {
+		if rr := serve(handler, "GET", target); rr.Code != http.StatusOK {
+			t.Errorf("%s during the outage returned %v", target, rr.Code)
+		}
+	}
+	if rr := serve(handleArtist, "GET", "/artist/3"); !strings.Contains(rr.Body.String(), "Pink Floyd") {
+		t.Error("artist page during the outage misses the stored artist")
+	}
+	if snapshotState.lastError == nil {
+		t.Error("the failed sync is not reported")
+	}
+	if upstream.Calls("/api/artists/3") != 0 || upstream.Calls("/api/relation/3") != 0 {
+		t.Error("the artist page still fetches from the upstream")
+	}
+	// An outage longer than snapshotMaxAge keeps the instance ready
+	snapshotState.data.LoadedAt = time.Now().Add(-2 * snapshotMaxAge)
+	if rr := serve(handleReadyz, "GET", "/readyz"); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"degraded"`) {
+		t.Errorf("/readyz during a long outage: got %v, body %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestColdLoadSyncsOnce(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	upstream.FailPath("/api/artists", http.StatusServiceUnavailable)
+	upstream.SetLatency(100 * time.Millisecond)
+
+	// Requests finding no snapshot share one sync with the upstream
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := currentSnapshot(); err == nil {
+				t.Error("a snapshot was loaded while the upstream is down")
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := upstream.Calls("/api/artists"); calls != 1 {
+		t.Errorf("the upstream was called %d times", calls)
+	}
+
+	upstream.ClearErrors()
+	if _, err := currentSnapshot(); err != nil {
+		t.Errorf("the snapshot does not load once the upstream is back: %v", err)
+	}
+}
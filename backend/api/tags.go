@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mymain/backend/api/datastore"
+	"mymain/backend/api/metadata"
+)
+
+// tagStore keeps the tags operators give artists on /admin, a list of
+// names per artist id. main replaces it with one kept in the data directory.
+var tagStore = memoryDataStore()
+
+const tagsBucket = "tags"
+
+// artistTag is a tag of an artist, shown on /tag/{Slug}.
+type artistTag struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// tagCount is a tag with the number of artists that have it. Size is its
+// weight in the tag cloud, from 1 to 5.
+type tagCount struct {
+	artistTag
+	Count int `json:"count"`
+	Size  int `json:"-"`
+}
+
+// tagSlug turns a tag into the slug of its page, slugged like member names:
+// "Glam Rock" becomes "glam-rock".
+func tagSlug(name string) string {
+	return memberSlug(name)
+}
+
+// adminTags reads the tags given on /admin, by artist id.
+func adminTags() map[int][]string {
+	tags := map[int][]string{}
+	err := tagStore.View(func(tx *datastore.Tx) error {
+		for _, key := range tx.Keys(tagsBucket) {
+			id, err := strconv.Atoi(key)
+			if err != nil {
+				continue
+			}
+			var names []string
+			if _, err := tx.GetJson(tagsBucket, key, &names); err != nil {
+				return err
+			}
+			tags[id] = names
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("reading the tags: %v", err)
+	}
+	return tags
+}
+
+// parseTags splits a comma separated list of tags, dropping empty ones and
+// repeated slugs.
+func parseTags(text string) []string {
+	var names, slugs []string
+	for _, name := range strings.Split(text, ",") {
+		name = normalizeMemberName(name)
+		slug := tagSlug(name)
+		if slug == "" || slices.Contains(slugs, slug) {
+			continue
+		}
+		names, slugs = append(names, name), append(slugs, slug)
+	}
+	return names
+}
+
+// setAdminTags replaces the tags given to the artist on /admin and rebuilds
+// the snapshot with them.
+func setAdminTags(id int, names []string) error {
+	err := tagStore.Update(func(tx *datastore.Tx) error {
+		key := strconv.Itoa(id)
+		if len(names) == 0 {
+			_, err := tx.Delete(tagsBucket, key)
+			return err
+		}
+		_, err := tx.PutJson(tagsBucket, key, names)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	snapshot, err := readStore()
+	if err != nil {
+		return err
+	}
+	snapshotState.Lock()
+	defer snapshotState.Unlock()
+	snapshotState.data = snapshot
+	return nil
+}
+
+// attachTags gives the artists of a snapshot just read from the store
+// their tags: the genres and tags of their metadata, then those given on
+// /admin. A tag is kept once, with its first spelling.
+func attachTags(snapshot *dataSnapshot, set *metadata.Set, given map[int][]string) {
+	for i, artist := range snapshot.Artists {
+		var names []string
+		if entry := set.Get(artist.Id); entry != nil {
+			names = append(append(names, entry.Genres...), entry.Tags...)
+		}
+		names = append(names, given[artist.Id]...)
+
+		var tags []artistTag
+		for _, name := range names {
+			slug := tagSlug(name)
+			if slug == "" || slices.ContainsFunc(tags, func(tag artistTag) bool { return tag.Slug == slug }) {
+				continue
+			}
+			tags = append(tags, artistTag{Slug: slug, Name: name})
+		}
+		snapshot.Artists[i].Tags = tags
+	}
+}
+
+// hasTag tells whether the artist has the tag with the given slug.
+func (artist ArtistsData) hasTag(slug string) bool {
+	return slices.ContainsFunc(artist.Tags, func(tag artistTag) bool { return tag.Slug == slug })
+}
+
+// countTags counts the artists of every tag, sorted by name.
+func countTags(artists []ArtistsData) []tagCount {
+	var counts []tagCount
+	for _, artist := range artists {
+		for _, tag := range artist.Tags {
+			index := slices.IndexFunc(counts, func(count tagCount) bool { return count.Slug == tag.Slug })
+			if index < 0 {
+				counts = append(counts, tagCount{artistTag: tag})
+				index = len(counts) - 1
+			}
+			counts[index].Count++
+		}
+	}
+	slices.SortFunc(counts, func(a, b tagCount) int { return strings.Compare(a.Slug, b.Slug) })
+	return counts
+}
+
+// tagCloud counts the tags of the artists and sizes them from 1 for the
+// rarest to 5 for the most common.
+func tagCloud(artists []ArtistsData) []tagCount {
+	cloud := countTags(artists)
+	least, most := 0, 0
+	for i, tag := range cloud {
+		if i == 0 || tag.Count < least {
+			least = tag.Count
+		}
+		most = max(most, tag.Count)
+	}
+	for i := range cloud {
+		cloud[i].Size = 1
+		if most > least {
+			cloud[i].Size += (cloud[i].Count - least) * 4 / (most - least)
+		}
+	}
+	return cloud
+}
+
+// taggedArtists returns the tag with the given slug and its artists.
+func taggedArtists(artists []ArtistsData, slug string) (artistTag, []ArtistsData, bool) {
+	var tag artistTag
+	var tagged []ArtistsData
+	for _, artist := range artists {
+		index := slices.IndexFunc(artist.Tags, func(tag artistTag) bool { return tag.Slug == slug })
+		if index < 0 {
+			continue
+		}
+		if tagged == nil {
+			tag = artist.Tags[index]
+		}
+		tagged = append(tagged, artist)
+	}
+	return tag, tagged, tagged != nil
+}
+
+// tagSlugFromPath reads the slug of /tag/{slug} and /api/tag/{slug}.
+func tagSlugFromPath(path string, prefix string) (string, bool) {
+	slug := strings.TrimPrefix(path, prefix)
+	return slug, slug != "" && !strings.Contains(slug, "/")
+}
+
+func handleTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	slug, ok := tagSlugFromPath(r.URL.Path, "/tag/")
+	if !ok {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"tag.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	tag, artists, found := taggedArtists(snapshot.Artists, slug)
+	if !found {
+		// Tags typed by hand: redirect to the canonical slug
+		if canonical := tagSlug(slug); canonical != slug {
+			if _, _, found := taggedArtists(snapshot.Artists, canonical); found {
+				http.Redirect(w, r, "/tag/"+canonical, http.StatusMovedPermanently)
+				return
+			}
+		}
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	// Tags the artists share with this one, most common first
+	related := slices.DeleteFunc(countTags(artists), func(count tagCount) bool { return count.Slug == slug })
+	slices.SortStableFunc(related, func(a, b tagCount) int { return b.Count - a.Count })
+
+	tmpl.Execute(w, struct {
+		Tag     artistTag
+		Artists []ArtistsData
+		Related []tagCount
+	}{tag, artists, related})
+}
+
+// tagJson is a tag as /api/tags and /api/tag/{slug} serve it.
+type tagJson struct {
+	tagCount
+	Artists []int `json:"artists"`
+}
+
+// handleTagsJson serves every tag with the ids of its artists.
+func handleTagsJson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	tags := []tagJson{}
+	for _, count := range countTags(snapshot.Artists) {
+		tag := tagJson{tagCount: count, Artists: []int{}}
+		for _, artist := range snapshot.Artists {
+			if artist.hasTag(count.Slug) {
+				tag.Artists = append(tag.Artists, artist.Id)
+			}
+		}
+		tags = append(tags, tag)
+	}
+	writeJson(w, http.StatusOK, tags)
+}
+
+// handleTagJson serves a tag with its artists.
+func handleTagJson(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	slug, ok := tagSlugFromPath(r.URL.Path, "/api/tag/")
+	if !ok {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "no such tag"})
+		return
+	}
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		writeJson(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	tag, artists, found := taggedArtists(snapshot.Artists, tagSlug(slug))
+	if !found {
+		writeJson(w, http.StatusNotFound, map[string]string{"error": "no such tag"})
+		return
+	}
+	writeJson(w, http.StatusOK, struct {
+		artistTag
+		Artists []ArtistsData `json:"artists"`
+	}{tag, artists})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useTags keeps the tags given on /admin by the test apart.
+func useTags(t *testing.T) {
+	t.Helper()
+	saved := tagStore
+	tagStore = memoryDataStore()
+	t.Cleanup(func() { tagStore = saved })
+}
+
+var testTagMetadata = map[string]string{
+	"artists.json": `[
+		{"id": 1, "genres": ["Rock", "Glam rock"], "tags": ["British", "rock"]},
+		{"id": 3, "genres": ["Progressive rock", "Rock"], "tags": ["British"]},
+		{"id": 4, "genres": ["Hard rock", "Rock"]}
+	]`,
+}
+
+func TestParseTags(t *testing.T) {
+	got := parseTags(" Stadium  rock, ,british,British, Motörhead fans ")
+	if want := []string{"Stadium rock", "british", "Motörhead fans"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTags gives %q, want %q", got, want)
+	}
+	if got := tagSlug("Motörhead Fans"); got != "motorhead-fans" {
+		t.Errorf("tagSlug gives %q", got)
+	}
+}
+
+func TestAttachTags(t *testing.T) {
+	newFakeUpstream(t)
+	useTags(t)
+	useMetadata(t, testTagMetadata)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if err := setAdminTags(2, []string{"Reggae", "rock"}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, _ := currentSnapshot()
+	tags := map[int][]artistTag{}
+	for _, artist := range snapshot.Artists {
+		tags[artist.Id] = artist.Tags
+	}
+	expected := map[int][]artistTag{
+		1: {{"rock", "Rock"}, {"glam-rock", "Glam rock"}, {"british", "British"}},
+		2: {{"reggae", "Reggae"}, {"rock", "rock"}},
+		3: {{"progressive-rock", "Progressive rock"}, {"rock", "Rock"}, {"british", "British"}},
+		4: {{"hard-rock", "Hard rock"}, {"rock", "Rock"}},
+		5: nil,
+		6: nil,
+	}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("tags are %v, want %v", tags, expected)
+	}
+
+	cloud := map[string][2]int{}
+	for _, tag := range tagCloud(snapshot.Artists) {
+		cloud[tag.Slug] = [2]int{tag.Count, tag.Size}
+	}
+	wantCloud := map[string][2]int{
+		"british": {2, 2}, "glam-rock": {1, 1}, "hard-rock": {1, 1},
+		"progressive-rock": {1, 1}, "reggae": {1, 1}, "rock": {4, 5},
+	}
+	if !reflect.DeepEqual(cloud, wantCloud) {
+		t.Errorf("tag cloud is %v, want %v", cloud, wantCloud)
+	}
+}
+
+func TestHandleTag(t *testing.T) {
+	newFakeUpstream(t)
+	useTags(t)
+	useMetadata(t, testTagMetadata)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := serve(handleTag, http.MethodGet, "/tag/british")
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `id="artist_1"`) || !strings.Contains(body, `id="artist_3"`) || strings.Contains(body, `id="artist_4"`) {
+		t.Errorf("/tag/british returned %d without Queen and Pink Floyd only", rr.Code)
+	}
+	if !strings.Contains(body, `href="/tag/rock">Rock (2)</a>`) || !strings.Contains(body, `href="/artists?tag=british"`) {
+		t.Error("the tag page does not link related tags and the filtered listing")
+	}
+
+	if rr := serve(handleTag, http.MethodGet, "/tag/Glam%20Rock"); rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/tag/glam-rock" {
+		t.Errorf("a hand typed tag returned %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	for _, target := range []string{"/tag/", "/tag/jazz", "/tag/rock/1"} {
+		if rr := serve(handleTag, http.MethodGet, target); rr.Code != http.StatusNotFound {
+			t.Errorf("%s returned %d", target, rr.Code)
+		}
+	}
+
+	// The index page shows the tag cloud
+	if body := serve(handleIndex, http.MethodGet, "/").Body.String(); !strings.Contains(body, `<a href="/tag/rock" class="tag-cloud-5`) {
+		t.Error("the index page has no tag cloud")
+	}
+
+	// The listing is filtered and searched by tag
+	for target, expected := range map[string][]string{
+		"/artists?tag=glam-rock":      {"Queen"},
+		"/search?search_text=british": {"Pink Floyd", "Queen"},
+	} {
+		handler := handleArtists
+		if strings.HasPrefix(target, "/search") {
+			handler = handleSearch
+		}
+		body := serve(handler, http.MethodGet, target).Body.String()
+		for _, name := range []string{"Bobby McFerrins", "Motörhead", "Pink Floyd", "Queen", "Scorpions", "SOJA"} {
+			listed := strings.Contains(body, `alt="`+name+`"`)
+			if want := strings.Contains(strings.Join(expected, ","), name); listed != want {
+				t.Errorf("%s lists %s: %v, want %v", target, name, listed, want)
+			}
+		}
+	}
+	if body := serve(handleArtists, http.MethodGet, "/artists?tag=british").Body.String(); !strings.Contains(body, `<option value="british" selected>British</option>`) {
+		t.Error("the filter form does not select the tag")
+	}
+}
+
+func TestHandleTagsJson(t *testing.T) {
+	newFakeUpstream(t)
+	useTags(t)
+	useMetadata(t, testTagMetadata)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := serve(handleTagsJson, http.MethodGet, "/api/tags")
+	var tags []struct {
+		Slug    string `json:"slug"`
+		Name    string `json:"name"`
+		Count   int    `json:"count"`
+		Artists []int  `json:"artists"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &tags); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("/api/tags returned %d: %v", rr.Code, err)
+	}
+	if len(tags) != 5 || tags[0].Slug != "british" || tags[0].Count != 2 || !reflect.DeepEqual(tags[0].Artists, []int{1, 3}) {
+		t.Errorf("/api/tags serves %+v", tags)
+	}
+
+	rr = serve(handleTagJson, http.MethodGet, "/api/tag/hard-rock")
+	var tag struct {
+		Name    string `json:"name"`
+		Artists []struct {
+			Name string      `json:"name"`
+			Tags []artistTag `json:"tags"`
+		} `json:"artists"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &tag); err != nil || tag.Name != "Hard rock" || len(tag.Artists) != 1 || tag.Artists[0].Name != "Scorpions" || len(tag.Artists[0].Tags) != 2 {
+		t.Errorf("/api/tag/hard-rock serves %s", rr.Body.String())
+	}
+	if rr := serve(handleTagJson, http.MethodGet, "/api/tag/jazz"); rr.Code != http.StatusNotFound {
+		t.Errorf("an unknown tag returned %d", rr.Code)
+	}
+	if rr := serve(handleTagsJson, http.MethodPost, "/api/tags"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST returned %d", rr.Code)
+	}
+}
+
+func TestAdminTags(t *testing.T) {
+	newFakeUpstream(t)
+	useFavorites(t)
+	useAdmin(t)
+	useTags(t)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := adminRequest(http.MethodGet, "/admin", nil, nil).Result().Cookies()
+	rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"tags"}, "artist": {"2"}, "tags": {"Reggae, Roots reggae,"}})
+	if rr.Header().Get("Location") != "/admin?done=tags" {
+		t.Fatalf("saving tags returned %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if body := serve(handleArtist, http.MethodGet, "/artist/2").Body.String(); !strings.Contains(body, `href="/tag/roots-reggae">Roots reggae</a>`) {
+		t.Error("the artist page does not show the saved tags")
+	}
+	if body := adminRequest(http.MethodGet, "/admin", nil, nil).Body.String(); !strings.Contains(body, "<td>Reggae, Roots reggae</td>") {
+		t.Error("the admin page does not list the saved tags")
+	}
+
+	// An empty list removes them
+	adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"tags"}, "artist": {"2"}, "tags": {""}})
+	if snapshot, _ := currentSnapshot(); len(snapshot.Artists[1].Tags) != 0 || len(adminTags()) != 0 {
+		t.Errorf("the tags were not removed: %v", snapshot.Artists[1].Tags)
+	}
+
+	if rr := adminRequest(http.MethodPost, "/admin", cookies, url.Values{"action": {"tags"}, "artist": {"99"}, "tags": {"Jazz"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("tagging an unknown artist returned %d", rr.Code)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mymain/backend/api/imagecache"
+	"mymain/backend/api/pdf"
+)
+
+// Layout of the tour sheets, in points.
+const (
+	sheetMargin    = 50
+	sheetPhoto     = 160
+	sheetRowHeight = 18
+	sheetFooter    = 30
+)
+
+// sheetColumns are the columns of the concert table with their widths; the
+// last one takes the rest of the page.
+var sheetColumns = []struct {
+	Title string
+	Width float64
+}{
+	{"#", 30},
+	{"Date", 110},
+	{"City", 170},
+	{"Country", 0},
+}
+
+// tourSheetPhoto returns the artist image as a JPEG, or the placeholder
+// when it cannot be fetched.
+func tourSheetPhoto(artist ArtistsData) []byte {
+	data, err := artistImages.Get(artist.Image, "card")
+	if err == nil {
+		return data
+	}
+	log.Printf("tour sheet of %s without image: %v", artist.Name, err)
+	return placeholderPhoto()
+}
+
+// placeholderPhoto is the placeholder image as a JPEG.
+func placeholderPhoto() []byte {
+	size := imagecache.Sizes["card"]
+	var out bytes.Buffer
+	jpeg.Encode(&out, imagecache.Placeholder(size.Width, size.Height), nil)
+	return out.Bytes()
+}
+
+// sheetPage draws on the current page of a tour sheet from the top down and
+// starts a new page when the next line does not fit.
+type sheetPage struct {
+	doc   *pdf.Document
+	title string
+	page  *pdf.Page
+	y     float64
+}
+
+func (s *sheetPage) newPage() {
+	s.page = s.doc.AddPage()
+	s.y = pdf.A4Height - sheetMargin
+}
+
+// concertHeader draws the header row of the concert table.
+func (s *sheetPage) concertHeader() {
+	width := pdf.A4Width - 2*sheetMargin
+	s.page.Gray(0.85)
+	s.page.Box(sheetMargin, s.y-sheetRowHeight, width, sheetRowHeight)
+	s.page.Gray(0)
+	x := float64(sheetMargin)
+	for _, column := range sheetColumns {
+		s.page.Text(x+4, s.y-13, pdf.Bold, 10, column.Title)
+		x += column.Width
+	}
+	s.y -= sheetRowHeight
+}
+
+// concertRow draws a row of the concert table, on a new page under a
+// repeated header when the page is full.
+func (s *sheetPage) concertRow(number int, cells []string) {
+	if s.y-sheetRowHeight < sheetMargin+sheetFooter {
+		s.newPage()
+		s.page.Text(sheetMargin, s.y-12, pdf.Bold, 12, s.title+" (continued)")
+		s.y -= 24
+		s.concertHeader()
+	}
+	width := pdf.A4Width - 2*sheetMargin
+	if number%2 == 0 {
+		s.page.Gray(0.95)
+		s.page.Box(sheetMargin, s.y-sheetRowHeight, width, sheetRowHeight)
+		s.page.Gray(0)
+	}
+	x := float64(sheetMargin)
+	for i, column := range sheetColumns {
+		columnWidth := column.Width
+		if columnWidth == 0 {
+			columnWidth = sheetMargin + width - x
+		}
+		s.page.Text(x+4, s.y-13, pdf.Regular, 10, pdf.Fit(pdf.Regular, 10, cells[i], columnWidth-8))
+		x += columnWidth
+	}
+	s.y -= sheetRowHeight
+}
+
+// tourSheet lays out the printable sheet of an artist: image, members,
+// creation date and first album, then every concert by date.
+func tourSheet(snapshot *dataSnapshot, artist ArtistsData, photo []byte) (*pdf.Document, error) {
+	doc := pdf.New(artist.Name + " tour sheet")
+	img, err := doc.AddImage(photo)
+	if err != nil {
+		return nil, err
+	}
+	var concerts []Concert
+	for _, concert := range allConcerts(snapshot) {
+		if concert.ArtistId == artist.Id {
+			concerts = append(concerts, concert)
+		}
+	}
+
+	s := &sheetPage{doc: doc, title: artist.Name}
+	s.newPage()
+	s.page.Text(sheetMargin, s.y-26, pdf.Bold, 26, pdf.Fit(pdf.Bold, 26, artist.Name, pdf.A4Width-2*sheetMargin))
+	s.page.Gray(0.4)
+	s.page.Text(sheetMargin, s.y-46, pdf.Regular, 12, "Tour sheet")
+	s.page.Gray(0)
+	s.page.Line(sheetMargin, s.y-56, pdf.A4Width-sheetMargin, s.y-56, 0.5)
+	s.y -= 72
+
+	// The image keeps its proportions inside a square box
+	width, height := float64(sheetPhoto), float64(sheetPhoto)
+	if img.Width > img.Height {
+		height = sheetPhoto * float64(img.Height) / float64(img.Width)
+	} else {
+		width = sheetPhoto * float64(img.Width) / float64(img.Height)
+	}
+	s.page.Image(img, sheetMargin, s.y-height, width, height)
+
+	// Facts to the right of the image
+	x := float64(sheetMargin + sheetPhoto + 20)
+	columnWidth := pdf.A4Width - sheetMargin - x
+	firstAlbum := artist.FirstAlbum
+	if date, err := parseDate(artist.FirstAlbum); err == nil {
+		firstAlbum = date.Format("2 January 2006")
+	}
+	y := s.y
+	for _, fact := range [][2]string{
+		{"Created", strconv.Itoa(artist.CreationDate)},
+		{"First album", firstAlbum},
+		{"Concerts", strconv.Itoa(len(concerts))},
+	} {
+		s.page.Text(x, y-10, pdf.Bold, 10, fact[0])
+		s.page.Text(x+80, y-10, pdf.Regular, 11, fact[1])
+		y -= 18
+	}
+	y -= 6
+	s.page.Text(x, y-10, pdf.Bold, 10, "Members")
+	for _, member := range artist.Members {
+		for _, line := range pdf.Wrap(pdf.Regular, 11, member, columnWidth-80) {
+			s.page.Text(x+80, y-10, pdf.Regular, 11, line)
+			y -= 15
+		}
+	}
+	s.y = min(s.y-height, y) - 30
+
+	s.page.Text(sheetMargin, s.y-14, pdf.Bold, 14, "Concerts")
+	s.y -= 24
+	if len(concerts) == 0 {
+		s.page.Text(sheetMargin, s.y-12, pdf.Regular, 11, "No concerts announced.")
+	} else {
+		s.concertHeader()
+		for i, concert := range concerts {
+			s.concertRow(i+1, []string{
+				strconv.Itoa(i + 1),
+				concert.Date.Format("Mon 2 Jan 2006"),
+				placeName(concert.City),
+				countryName(concert.Country),
+			})
+		}
+	}
+
+	// Footers once the number of pages is known
+	pages := doc.Pages()
+	for i, page := range pages {
+		number := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		page.Gray(0.5)
+		page.Text(sheetMargin, sheetMargin-10, pdf.Regular, 8, artist.Name+" · groupie-tracker")
+		page.Text(pdf.A4Width-sheetMargin-pdf.Width(pdf.Regular, 8, number), sheetMargin-10, pdf.Regular, 8, number)
+	}
+	return doc, nil
+}
+
+// handleTourSheet serves /artist/{id}/tour.pdf, the artist and their
+// concerts on paper.
+func handleTourSheet(w http.ResponseWriter, r *http.Request) {
+	text, _ := strings.CutPrefix(r.URL.Path, "/artist/")
+	text, _ = strings.CutSuffix(text, "/tour.pdf")
+	id, err := strconv.Atoi(text)
+	if err != nil {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	artist, found := snapshot.findArtist(id)
+	if !found {
+		handleErrorPage(w, r, NotFoundError)
+		return
+	}
+	doc, err := tourSheet(snapshot, artist, tourSheetPhoto(artist))
+	if err != nil {
+		// An image the sheet cannot embed, such as a CMYK JPEG
+		log.Printf("tour sheet of %s: %v", artist.Name, err)
+		doc, err = tourSheet(snapshot, artist, placeholderPhoto())
+	}
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	var out bytes.Buffer
+	doc.WriteTo(&out)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-tour.pdf"`, memberSlug(artist.Name)))
+	w.Write(out.Bytes())
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"mymain/backend/api/pdf"
+)
+
+// pdfText returns the page contents of a PDF, inflated.
+func pdfText(t *testing.T, data []byte) string {
+	t.Helper()
+	var text strings.Builder
+	for _, part := range bytes.Split(data, []byte("/Filter /FlateDecode "))[1:] {
+		start := bytes.Index(part, []byte("stream\n")) + len("stream\n")
+		reader, err := zlib.NewReader(bytes.NewReader(part[start:]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(reader)
+		text.Write(content)
+	}
+	return text.String()
+}
+
+func TestHandleTourSheet(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	artistImages.Dir = t.TempDir()
+
+	rr := serve(handleArtist, http.MethodGet, "/artist/1/tour.pdf")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" || rr.Header().Get("Content-Disposition") != `inline; filename="queen-tour.pdf"` {
+		t.Fatalf("the tour sheet returned %d with %v", rr.Code, rr.Header())
+	}
+	data := rr.Body.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data, []byte("/Filter /DCTDecode")) {
+		t.Error("the tour sheet is not a PDF with the artist image")
+	}
+	if calls := upstream.Calls("/api/images/queen.jpeg"); calls != 1 {
+		t.Errorf("the image was fetched %d times", calls)
+	}
+
+	text := pdfText(t, data)
+	for _, want := range []string{"(Queen)", "(1970)", "(14 December 1973)", "(Freddie Mercury)", "(Doug Fogie)", "(Wed 30 Jan 2019)", "(Page 1 of 1)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("the tour sheet does not show %s", want)
+		}
+	}
+	// Concerts come by date
+	nagoya, angeles, dunedin := strings.Index(text, "(Nagoya)"), strings.Index(text, "(Los Angeles)"), strings.Index(text, "(Dunedin)")
+	if nagoya < 0 || nagoya > angeles || angeles > dunedin || !strings.Contains(text, "(New Zealand)") {
+		t.Error("the concerts are not in order with their locations")
+	}
+
+	for target, code := range map[string]int{
+		"/artist/99/tour.pdf":    http.StatusNotFound,
+		"/artist/queen/tour.pdf": http.StatusNotFound,
+		"/artist/1/tour.pdf/x":   http.StatusNotFound,
+	} {
+		if rr := serve(handleArtist, http.MethodGet, target); rr.Code != code {
+			t.Errorf("%s returned %d, want %d", target, rr.Code, code)
+		}
+	}
+	if rr := serve(handleArtist, http.MethodPost, "/artist/1/tour.pdf"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST returned %d", rr.Code)
+	}
+}
+
+func TestTourSheetPages(t *testing.T) {
+	s := &sheetPage{doc: pdf.New("test"), title: "Queen"}
+	s.newPage()
+	s.concertHeader()
+	for i := 1; i <= 60; i++ {
+		s.concertRow(i, []string{"1", "Wed 30 Jan 2019", "Playa Del Carmen", "Mexico"})
+	}
+	if pages := len(s.doc.Pages()); pages != 2 {
+		t.Errorf("60 concerts take %d pages", pages)
+	}
+
+	var out bytes.Buffer
+	s.doc.WriteTo(&out)
+	if text := pdfText(t, out.Bytes()); !strings.Contains(text, "(Queen \\(continued\\))") {
+		t.Error("the second page does not repeat the title")
+	}
+
+	newFakeUpstream(t)
+	snapshot, _ := currentSnapshot()
+	if _, err := tourSheet(snapshot, snapshot.Artists[0], []byte("not a jpeg")); err == nil {
+		t.Error("a broken image was embedded")
+	}
+}
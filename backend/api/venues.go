@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// sharedVenueWindow is how many days apart two appearances in the same city
+// may be to count as nearby, maxSharedVenueWindow the largest ?window= the
+// festivals page accepts.
+const (
+	sharedVenueWindow    = 7
+	maxSharedVenueWindow = 60
+)
+
+// venueKey identifies a concert: a location on a date.
+type venueKey struct {
+	Location string
+	Date     time.Time
+}
+
+// venueIndex cross-references the concerts of all artists by (location,
+// date) and by location.
+type venueIndex struct {
+	byVenue    map[venueKey][]Concert
+	byLocation map[string][]Concert
+}
+
+// sharedEvent is a location and date played by more than one artist.
+type sharedEvent struct {
+	Location string
+	Name     string
+	Date     time.Time
+	Concerts []Concert
+}
+
+// Kind is "festival" when three or more artists played, "co-billed"
+// otherwise.
+func (e sharedEvent) Kind() string {
+	if countArtists(e.Concerts) > 2 {
+		return "festival"
+	}
+	return "co-billed"
+}
+
+// DateText formats the event date like the upstream does.
+func (e sharedEvent) DateText() string {
+	return e.Date.Format(dateLayout)
+}
+
+// nearbyAppearance is a pair of different artists playing the same city on
+// different dates at most the venue window apart.
+type nearbyAppearance struct {
+	Location string
+	Name     string
+	First    Concert
+	Second   Concert
+	Days     int
+}
+
+// nearbyConcert is a concert of another artist relative to one of our own.
+type nearbyConcert struct {
+	Concert
+	Days int
+}
+
+// Offset describes when the other artist played, e.g. "2 days later".
+func (c nearbyConcert) Offset() string {
+	days, when := c.Days, "later"
+	if days < 0 {
+		days, when = -days, "earlier"
+	}
+	if days == 1 {
+		return "1 day " + when
+	}
+	return strconv.Itoa(days) + " days " + when
+}
+
+// alsoPlayedHere lists, for one concert of an artist, who else played that
+// city on the same day or around it.
+type alsoPlayedHere struct {
+	Concert
+	Name    string
+	SameDay []Concert
+	Nearby  []nearbyConcert
+}
+
+// buildVenueIndex indexes the given concerts.
+func buildVenueIndex(concerts []Concert) *venueIndex {
+	index := &venueIndex{
+		byVenue:    map[venueKey][]Concert{},
+		byLocation: map[string][]Concert{},
+	}
+	for _, concert := range concerts {
+		key := venueKey{Location: concert.Location, Date: concert.Date}
+		index.byVenue[key] = append(index.byVenue[key], concert)
+		index.byLocation[concert.Location] = append(index.byLocation[concert.Location], concert)
+	}
+	for _, list := range index.byLocation {
+		sortConcerts(list)
+	}
+	return index
+}
+
+// sharedEvents returns every location and date played by at least two
+// artists, sorted by date.
+func (index *venueIndex) sharedEvents() []sharedEvent {
+	var events []sharedEvent
+	for key, concerts := range index.byVenue {
+		if countArtists(concerts) < 2 {
+			continue
+		}
+		events = append(events, sharedEvent{
+			Location: key.Location,
+			Name:     locationName(key.Location),
+			Date:     key.Date,
+			Concerts: concerts,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Date.Equal(events[j].Date) {
+			return events[i].Date.Before(events[j].Date)
+		}
+		return events[i].Location < events[j].Location
+	})
+	return events
+}
+
+// nearbyAppearances returns the pairs of different artists that played the
+// same city on different dates at most window days apart, sorted by the
+// date of the first appearance.
+func (index *venueIndex) nearbyAppearances(window int) []nearbyAppearance {
+	var pairs []nearbyAppearance
+	for location, concerts := range index.byLocation {
+		for i, first := range concerts {
+			for _, second := range concerts[i+1:] {
+				days := daysBetween(first.Date, second.Date)
+				if days > window {
+					break
+				}
+				if days == 0 || first.ArtistId == second.ArtistId {
+					continue
+				}
+				pairs = append(pairs, nearbyAppearance{
+					Location: location,
+					Name:     locationName(location),
+					First:    first,
+					Second:   second,
+					Days:     days,
+				})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if !a.First.Date.Equal(b.First.Date) {
+			return a.First.Date.Before(b.First.Date)
+		}
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.First.ArtistId != b.First.ArtistId {
+			return a.First.ArtistId < b.First.ArtistId
+		}
+		return a.Second.ArtistId < b.Second.ArtistId
+	})
+	return pairs
+}
+
+// alsoPlayedHere returns the concerts of the artist that share their city
+// with other artists on the same day or within window days, sorted by date.
+func (index *venueIndex) alsoPlayedHere(artistId int, window int) []alsoPlayedHere {
+	var list []alsoPlayedHere
+	for _, concerts := range index.byLocation {
+		for _, own := range concerts {
+			if own.ArtistId != artistId {
+				continue
+			}
+			entry := alsoPlayedHere{Concert: own, Name: locationName(own.Location)}
+			for _, other := range concerts {
+				if other.ArtistId == artistId {
+					continue
+				}
+				days := daysBetween(own.Date, other.Date)
+				if other.Date.Before(own.Date) {
+					days = -days
+				}
+				switch {
+				case days == 0:
+					entry.SameDay = append(entry.SameDay, other)
+				case days >= -window && days <= window:
+					entry.Nearby = append(entry.Nearby, nearbyConcert{Concert: other, Days: days})
+				}
+			}
+			if len(entry.SameDay) > 0 || len(entry.Nearby) > 0 {
+				list = append(list, entry)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return concertLess(list[i].Concert, list[j].Concert)
+	})
+	return list
+}
+
+func countArtists(concerts []Concert) int {
+	artists := map[int]bool{}
+	for _, concert := range concerts {
+		artists[concert.ArtistId] = true
+	}
+	return len(artists)
+}
+
+// daysBetween returns the number of whole days between two dates.
+func daysBetween(a, b time.Time) int {
+	days := int(b.Sub(a).Hours() / 24)
+	if days < 0 {
+		return -days
+	}
+	return days
+}
+
+// parseVenueWindow reads ?window=, in days.
+func parseVenueWindow(text string) (int, error) {
+	if text == "" {
+		return sharedVenueWindow, nil
+	}
+	window, err := strconv.Atoi(text)
+	if err != nil || window < 1 || window > maxSharedVenueWindow {
+		return 0, fmt.Errorf("window must be between 1 and %d days", maxSharedVenueWindow)
+	}
+	return window, nil
+}
+
+// artistSharedVenues returns who else played the cities of the artist. It
+// uses the data snapshot and gives up quietly when that is unavailable, the
+// artist page is still useful without it.
+func artistSharedVenues(artistId int) []alsoPlayedHere {
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		return nil
+	}
+	return buildVenueIndex(allConcerts(snapshot)).alsoPlayedHere(artistId, sharedVenueWindow)
+}
+
+func handleFestivals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+
+	window, err := parseVenueWindow(r.URL.Query().Get("window"))
+	if err != nil {
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+
+	tmpl, err := parsePage(
+		publicUrl+"festivals.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	index := buildVenueIndex(allConcerts(snapshot))
+
+	templateData := struct {
+		Window int
+		Events []sharedEvent
+		Nearby []nearbyAppearance
+	}{
+		Window: window,
+		Events: index.sharedEvents(),
+		Nearby: index.nearbyAppearances(window),
+	}
+
+	tmpl.Execute(w, templateData)
+}
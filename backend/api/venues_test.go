@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestVenueIndex(t *testing.T) *venueIndex {
+	t.Helper()
+	newFakeUpstream(t)
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildVenueIndex(allConcerts(snapshot))
+}
+
+func TestSharedEvents(t *testing.T) {
+	index := newTestVenueIndex(t)
+
+	var got []string
+	for _, event := range index.sharedEvents() {
+		var names []string
+		for _, concert := range event.Concerts {
+			names = append(names, concert.ArtistName)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s: %s", event.DateText(), event.Location, event.Kind(), strings.Join(names, ", ")))
+	}
+	expected := []string{
+		"20-08-2019 los_angeles-usa co-billed: Queen, Pink Floyd",
+		"28-01-2020 osaka-japan co-billed: Queen, SOJA",
+		"21-04-2020 london-uk co-billed: Pink Floyd, Bobby McFerrins",
+		"15-05-2020 berlin-germany co-billed: Pink Floyd, Scorpions",
+		"10-06-2021 hamburg-germany co-billed: Scorpions, Motörhead",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("shared events are\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// Two artists are co-billed even when one of them lists the date twice
+	event := sharedEvent{Concerts: []Concert{{ArtistId: 1}, {ArtistId: 1}, {ArtistId: 2}}}
+	if kind := event.Kind(); kind != "co-billed" {
+		t.Errorf("two artists make a %s", kind)
+	}
+}
+
+func TestNearbyAppearances(t *testing.T) {
+	index := newTestVenueIndex(t)
+
+	format := func(pairs []nearbyAppearance) []string {
+		var list []string
+		for _, pair := range pairs {
+			list = append(list, fmt.Sprintf("%s %s %s > %s %s (%d)", pair.Location, pair.First.ArtistName, pair.First.DateText(), pair.Second.ArtistName, pair.Second.DateText(), pair.Days))
+		}
+		return list
+	}
+
+	expected := []string{
+		"los_angeles-usa Queen 20-08-2019 > SOJA 21-08-2019 (1)",
+		"los_angeles-usa Pink Floyd 20-08-2019 > SOJA 21-08-2019 (1)",
+		"paris-france Scorpions 16-05-2020 > Pink Floyd 18-05-2020 (2)",
+		"hamburg-germany Motörhead 10-06-2021 > Scorpions 11-06-2021 (1)",
+	}
+	if got := format(index.nearbyAppearances(7)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("nearby appearances are\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// A narrower window drops Paris
+	if got := index.nearbyAppearances(1); len(got) != 3 {
+		t.Errorf("nearby appearances within a day are %v", format(got))
+	}
+}
+
+func TestAlsoPlayedHere(t *testing.T) {
+	index := newTestVenueIndex(t)
+
+	var got []string
+	for _, entry := range index.alsoPlayedHere(4, sharedVenueWindow) {
+		line := entry.DateText() + " " + entry.Location + ":"
+		for _, concert := range entry.SameDay {
+			line += " " + concert.ArtistName + " same day"
+		}
+		for _, concert := range entry.Nearby {
+			line += " " + concert.ArtistName + " " + concert.Offset()
+		}
+		got = append(got, line)
+	}
+	expected := []string{
+		"15-05-2020 berlin-germany: Pink Floyd same day",
+		"16-05-2020 paris-france: Pink Floyd 2 days later",
+		"10-06-2021 hamburg-germany: Motörhead same day",
+		"11-06-2021 hamburg-germany: Motörhead 1 day earlier",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Scorpions also played here\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if got := index.alsoPlayedHere(99, sharedVenueWindow); got != nil {
+		t.Errorf("unknown artist shares %v", got)
+	}
+}
+
+func TestHandleFestivals(t *testing.T) {
+	newFakeUpstream(t)
+
+	rr := serve(handleFestivals, "GET", "/festivals")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("HandleFestivals returned %v", rr.Code)
+	}
+	for _, expected := range []string{`id="event_osaka-japan_28-01-2020"`, `Same city within 7 days`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("festivals page does not contain %s", expected)
+		}
+	}
+
+	testCases := []struct {
+		method       string
+		target       string
+		expectedCode int
+	}{
+		{"GET", "/festivals?window=30", http.StatusOK},
+		{"GET", "/festivals?window=0", http.StatusBadRequest},
+		{"GET", "/festivals?window=week", http.StatusBadRequest},
+		{"POST", "/festivals", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range testCases {
+		if rr := serve(handleFestivals, tc.method, tc.target); rr.Code != tc.expectedCode {
+			t.Errorf("%s %s returned %v want %v", tc.method, tc.target, rr.Code, tc.expectedCode)
+		}
+	}
+
+	// The artist page links the artists that shared a city
+	rr = serve(handleArtist, "GET", "/artist/4")
+	if !strings.Contains(rr.Body.String(), `id="shared_paris-france_16-05-2020"`) {
+		t.Errorf("artist page does not show where others also played")
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mymain/backend/api/changes"
+	"mymain/backend/api/datastore"
+	"mymain/backend/api/webhooks"
+)
+
+// Buckets of the webhook store: the hooks keyed by id and the delivery log
+// keyed by deliveryKey, so keys sort by time.
+const (
+	hooksBucket      = "hooks"
+	deliveriesBucket = "deliveries"
+)
+
+// Limits of the webhooks: hooks per account, deliveries kept in the log and
+// deliveries shown per hook.
+const (
+	maxHooksPerOwner = 10
+	maxDeliveries    = 500
+	shownDeliveries  = 10
+)
+
+// webhookStore keeps the hooks and their delivery log and webhookSender
+// posts the payloads. main replaces the store with one kept in the data
+// directory; tests replace the sender with one that reaches local servers.
+var (
+	webhookStore  = memoryDataStore()
+	webhookSender = webhooks.NewSender()
+)
+
+// webhookQueue counts the deliveries in flight, so tests can wait for them.
+var webhookQueue sync.WaitGroup
+
+// webhookWorkers is how many deliveries are sent at the same time, the
+// others wait for a free worker, up to maxQueuedDeliveries. A hook can be
+// pinged once per pingInterval.
+const (
+	webhookWorkers      = 4
+	maxQueuedDeliveries = 100
+	pingInterval        = time.Minute
+)
+
+// lastPings holds when each hook was last pinged.
+var lastPings = struct {
+	sync.Mutex
+	byHook map[string]time.Time
+}{byHook: map[string]time.Time{}}
+
+// webhookPool hands out the delivery workers and counts the deliveries for
+// the admin page.
+var webhookPool = struct {
+	slots   chan struct{}
+	busy    atomic.Int64
+	waiting atomic.Int64
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+}{slots: make(chan struct{}, webhookWorkers)}
+
+// webhookPayload is the JSON body posted to hooks. Concerts are set for
+// "concerts.added", Hook for "ping".
+type webhookPayload struct {
+	Event    string           `json:"event"`
+	Hook     string           `json:"hook,omitempty"`
+	Concerts []webhookConcert `json:"concerts,omitempty"`
+}
+
+// webhookConcert is a new concert in a payload. Country is the slug hooks
+// subscribe to, Place the name to show.
+type webhookConcert struct {
+	ChangeId   int    `json:"change_id"`
+	ArtistId   int    `json:"artist_id"`
+	ArtistName string `json:"artist_name"`
+	Location   string `json:"location"`
+	Country    string `json:"country"`
+	Place      string `json:"place"`
+	Date       string `json:"date"`
+}
+
+// webhookView is a hook on the webhooks page with its last deliveries.
+type webhookView struct {
+	webhooks.Hook
+	ArtistNames  []string
+	CountryNames []string
+	Deliveries   []webhookDelivery
+	CreatedText  string
+}
+
+// webhookDelivery is a delivery on the webhooks page.
+type webhookDelivery struct {
+	webhooks.Delivery
+	CreatedText string
+}
+
+// webhookChoice is an artist or country offered by the registration form.
+type webhookChoice struct {
+	Value string
+	Label string
+}
+
+func deliveryKey(delivery webhooks.Delivery) string {
+	return fmt.Sprintf("%020d-%s", delivery.Created.UnixNano(), delivery.Id)
+}
+
+// listHooks returns the hooks of owner, or of everybody when owner is
+// empty, oldest first.
+func listHooks(owner string) ([]webhooks.Hook, error) {
+	var hooks []webhooks.Hook
+	err := webhookStore.View(func(tx *datastore.Tx) error {
+		return readBucket(tx, hooksBucket, func(record []byte) error {
+			var hook webhooks.Hook
+			if err := json.Unmarshal(record, &hook); err != nil {
+				return err
+			}
+			if owner == "" || hook.Owner == owner {
+				hooks = append(hooks, hook)
+			}
+			return nil
+		})
+	})
+	slices.SortStableFunc(hooks, func(a, b webhooks.Hook) int { return a.Created.Compare(b.Created) })
+	return hooks, err
+}
+
+// findHook returns the hook of owner with the given id.
+func findHook(owner string, id string) (webhooks.Hook, bool, error) {
+	var hook webhooks.Hook
+	var found bool
+	err := webhookStore.View(func(tx *datastore.Tx) error {
+		var err error
+		found, err = tx.GetJson(hooksBucket, id, &hook)
+		return err
+	})
+	if err != nil || !found || hook.Owner != owner {
+		return webhooks.Hook{}, false, err
+	}
+	return hook, true, nil
+}
+
+// recordDelivery adds the delivery to the log and drops the oldest beyond
+// maxDeliveries.
+func recordDelivery(delivery webhooks.Delivery) error {
+	return webhookStore.Update(func(tx *datastore.Tx) error {
+		if _, err := tx.PutJson(deliveriesBucket, deliveryKey(delivery), delivery); err != nil {
+			return err
+		}
+		keys := tx.Keys(deliveriesBucket)
+		for len(keys) > maxDeliveries {
+			if _, err := tx.Delete(deliveriesBucket, keys[0]); err != nil {
+				return err
+			}
+			keys = keys[1:]
+		}
+		return nil
+	})
+}
+
+// recentDeliveries returns the last deliveries to the hook, newest first.
+func recentDeliveries(hookId string, limit int) ([]webhooks.Delivery, error) {
+	var list []webhooks.Delivery
+	err := webhookStore.View(func(tx *datastore.Tx) error {
+		keys := tx.Keys(deliveriesBucket)
+		for i := len(keys) - 1; i >= 0 && len(list) < limit; i-- {
+			var delivery webhooks.Delivery
+			if _, err := tx.GetJson(deliveriesBucket, keys[i], &delivery); err != nil {
+				return err
+			}
+			if delivery.HookId == hookId {
+				list = append(list, delivery)
+			}
+		}
+		return nil
+	})
+	return list, err
+}
+
+// deliver posts the payload to the hook in the background, once one of the
+// webhookWorkers is free, and logs the delivery once it succeeded or every
+// attempt failed. It returns false, sending nothing, when the queue is full.
+func deliver(hook webhooks.Hook, payload webhookPayload) bool {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("encoding webhook payload: %v", err)
+		return false
+	}
+	for {
+		waiting := webhookPool.waiting.Load()
+		if waiting >= maxQueuedDeliveries {
+			webhookPool.dropped.Add(1)
+			log.Printf("webhook %s: %s dropped, %d deliveries are waiting", hook.Id, payload.Event, waiting)
+			return false
+		}
+		if webhookPool.waiting.CompareAndSwap(waiting, waiting+1) {
+			break
+		}
+	}
+	webhookQueue.Add(1)
+	go func() {
+		defer webhookQueue.Done()
+		webhookPool.slots <- struct{}{}
+		webhookPool.waiting.Add(-1)
+		webhookPool.busy.Add(1)
+		delivery := webhookSender.Send(hook, payload.Event, body)
+		webhookPool.busy.Add(-1)
+		<-webhookPool.slots
+		if delivery.Delivered {
+			webhookPool.sent.Add(1)
+		} else {
+			webhookPool.failed.Add(1)
+			log.Printf("webhook %s: delivery %s failed: %s", hook.Id, delivery.Id, delivery.Last().Error)
+		}
+		if err := recordDelivery(delivery); err != nil {
+			log.Printf("logging webhook delivery: %v", err)
+		}
+	}()
+	return true
+}
+
+// allowPing tells whether the hook may be pinged now, and if so counts the
+// ping.
+func allowPing(hookId string) bool {
+	lastPings.Lock()
+	defer lastPings.Unlock()
+	if last, pinged := lastPings.byHook[hookId]; pinged && now().Sub(last) < pingInterval {
+		return false
+	}
+	for id, last := range lastPings.byHook {
+		if now().Sub(last) >= pingInterval {
+			delete(lastPings.byHook, id)
+		}
+	}
+	lastPings.byHook[hookId] = now()
+	return true
+}
+
+// notifyWebhooks sends the concerts a sync added to every hook following
+// their artist or country, one payload per hook.
+func notifyWebhooks(list []changes.Change) {
+	var added []changes.Change
+	for _, change := range list {
+		if change.Kind == changes.ConcertAdded {
+			added = append(added, change)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	hooks, err := listHooks("")
+	if err != nil {
+		log.Printf("reading webhooks: %v", err)
+		return
+	}
+
+	// Payloads show concerts as the pages do, with the local corrections
+	corrections := currentOverrides()
+	for _, hook := range hooks {
+		payload := webhookPayload{Event: "concerts.added"}
+		for _, change := range added {
+			if corrections.Hidden(change.ArtistId) {
+				continue
+			}
+			location := corrections.Location(change.Location)
+			_, country := splitLocation(location)
+			if !hook.Follows(change.ArtistId, country) {
+				continue
+			}
+			payload.Concerts = append(payload.Concerts, webhookConcert{
+				ChangeId:   change.Id,
+				ArtistId:   change.ArtistId,
+				ArtistName: corrections.ArtistName(change.ArtistId, change.ArtistName),
+				Location:   location,
+				Country:    country,
+				Place:      locationName(location),
+				Date:       change.Date,
+			})
+		}
+		if len(payload.Concerts) > 0 {
+			deliver(hook, payload)
+		}
+	}
+}
+
+// webhookChoices lists the artists and countries hooks can follow.
+func webhookChoices(snapshot *dataSnapshot) ([]webhookChoice, []webhookChoice) {
+	var artists, countries []webhookChoice
+	for _, artist := range snapshot.Artists {
+		artists = append(artists, webhookChoice{Value: strconv.Itoa(artist.Id), Label: artist.Name})
+	}
+	for _, place := range buildPlaces(allConcerts(snapshot)) {
+		countries = append(countries, webhookChoice{Value: place.Country, Label: place.Name})
+	}
+	return artists, countries
+}
+
+// parseHook reads a hook from the registration form. The artists and
+// countries must be known to the snapshot.
+func parseHook(r *http.Request, snapshot *dataSnapshot) (webhooks.Hook, error) {
+	hook := webhooks.Hook{Url: strings.TrimSpace(r.PostFormValue("url"))}
+	if err := webhooks.ValidateUrl(hook.Url); err != nil {
+		return hook, err
+	}
+	for _, value := range r.PostForm["artists"] {
+		id, err := strconv.Atoi(value)
+		if _, found := snapshot.findArtist(id); err != nil || !found {
+			return hook, errors.New("unknown artist " + value)
+		}
+		hook.Artists = append(hook.Artists, id)
+	}
+	_, countries := webhookChoices(snapshot)
+	for _, value := range r.PostForm["countries"] {
+		if !slices.ContainsFunc(countries, func(choice webhookChoice) bool { return choice.Value == value }) {
+			return hook, errors.New("unknown country " + value)
+		}
+		hook.Countries = append(hook.Countries, value)
+	}
+	if len(hook.Artists) == 0 && len(hook.Countries) == 0 {
+		return hook, errors.New("follow at least one artist or country")
+	}
+	return hook, nil
+}
+
+// handleWebhooks lets logged in users register webhooks, remove them, send
+// them a ping and read their delivery log. Forms post action (add, delete
+// or ping), the CSRF token and, to add a hook, url, artists and countries.
+func handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		handleErrorPage(w, r, MethodNotAllowedError)
+		return
+	}
+	username, loggedIn := currentUser(r)
+	if !loggedIn {
+		if r.Method == http.MethodPost {
+			handleErrorPage(w, r, ForbiddenError)
+			return
+		}
+		http.Redirect(w, r, "/login?return=/webhooks", http.StatusSeeOther)
+		return
+	}
+
+	snapshot, err := currentSnapshot()
+	if err != nil {
+		handleErrorPage(w, r, upstreamErrorPage())
+		return
+	}
+	if r.Method == http.MethodGet {
+		renderWebhooks(w, r, snapshot, username, http.StatusOK, "", webhooks.Hook{})
+		return
+	}
+
+	if !checkCSRF(r) {
+		handleErrorPage(w, r, ForbiddenError)
+		return
+	}
+	switch r.PostFormValue("action") {
+	case "add":
+		hook, err := parseHook(r, snapshot)
+		if err == nil {
+			err = addHook(username, &hook)
+		}
+		if err != nil {
+			renderWebhooks(w, r, snapshot, username, http.StatusBadRequest, err.Error(), hook)
+			return
+		}
+	case "delete", "ping":
+		hook, found, err := findHook(username, r.PostFormValue("id"))
+		if err != nil {
+			log.Printf("reading webhook: %v", err)
+			handleErrorPage(w, r, InternalServerError)
+			return
+		}
+		if !found {
+			handleErrorPage(w, r, NotFoundError)
+			return
+		}
+		if r.PostFormValue("action") == "ping" {
+			if !allowPing(hook.Id) || !deliver(hook, webhookPayload{Event: "ping", Hook: hook.Id}) {
+				handleErrorPage(w, r, TooManyRequestsError)
+				return
+			}
+			break
+		}
+		err = webhookStore.Update(func(tx *datastore.Tx) error {
+			_, err := tx.Delete(hooksBucket, hook.Id)
+			return err
+		})
+		if err != nil {
+			log.Printf("deleting webhook: %v", err)
+			handleErrorPage(w, r, InternalServerError)
+			return
+		}
+	default:
+		handleErrorPage(w, r, BadRequestError)
+		return
+	}
+	http.Redirect(w, r, "/webhooks", http.StatusSeeOther)
+}
+
+// addHook stores a new hook of owner with a fresh id and secret.
+func addHook(owner string, hook *webhooks.Hook) error {
+	hook.Id, hook.Owner, hook.Secret, hook.Created = webhooks.NewId(), owner, webhooks.NewSecret(), now()
+	return webhookStore.Update(func(tx *datastore.Tx) error {
+		count := 0
+		err := readBucket(tx, hooksBucket, func(record []byte) error {
+			var other webhooks.Hook
+			if err := json.Unmarshal(record, &other); err != nil {
+				return err
+			}
+			if other.Owner == owner {
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if count >= maxHooksPerOwner {
+			return fmt.Errorf("an account can have at most %d webhooks", maxHooksPerOwner)
+		}
+		_, err = tx.PutJson(hooksBucket, hook.Id, hook)
+		return err
+	})
+}
+
+func renderWebhooks(w http.ResponseWriter, r *http.Request, snapshot *dataSnapshot, username string, status int, formError string, form webhooks.Hook) {
+	tmpl, err := parsePage(
+		publicUrl+"webhooks.html",
+		publicUrl+"templates/header.html",
+		publicUrl+"templates/menu.html",
+		publicUrl+"templates/hero.html",
+		publicUrl+"templates/footer.html",
+	)
+	if err != nil {
+		fmt.Println(err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+
+	hooks, err := listHooks(username)
+	if err != nil {
+		log.Printf("reading webhooks: %v", err)
+		handleErrorPage(w, r, InternalServerError)
+		return
+	}
+	var views []webhookView
+	for _, hook := range hooks {
+		view := webhookView{Hook: hook, CreatedText: hook.Created.Format(dateLayout)}
+		for _, id := range hook.Artists {
+			if artist, found := snapshot.findArtist(id); found {
+				view.ArtistNames = append(view.ArtistNames, artist.Name)
+			}
+		}
+		for _, country := range hook.Countries {
+			view.CountryNames = append(view.CountryNames, countryName(country))
+		}
+		deliveries, err := recentDeliveries(hook.Id, shownDeliveries)
+		if err != nil {
+			log.Printf("reading webhook deliveries: %v", err)
+		}
+		for _, delivery := range deliveries {
+			view.Deliveries = append(view.Deliveries, webhookDelivery{Delivery: delivery, CreatedText: delivery.Created.UTC().Format("02-01-2006 15:04:05 MST")})
+		}
+		views = append(views, view)
+	}
+	artists, countries := webhookChoices(snapshot)
+
+	templateData := struct {
+		Hooks     []webhookView
+		Artists   []webhookChoice
+		Countries []webhookChoice
+		Form      webhooks.Hook
+		Error     string
+		CSRF      string
+		Signature string
+	}{
+		Hooks:     views,
+		Artists:   artists,
+		Countries: countries,
+		Form:      form,
+		Error:     formError,
+		CSRF:      csrfToken(r),
+		Signature: webhooks.SignatureHeader,
+	}
+
+	w.WriteHeader(status)
+	tmpl.Execute(w, templateData)
+}
@@ -0,0 +1,242 @@
+// Package webhooks posts signed JSON payloads to the urls integrators
+// register, retrying failed deliveries and logging every attempt.
+//
+// Every request carries the event name, a delivery id, a Unix timestamp and
+// an HMAC-SHA256 signature of "timestamp.body" made with the secret of the
+// hook, so receivers can check that the payload comes from this site and is
+// not replayed. Verify does that check.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of every delivery.
+const (
+	EventHeader     = "X-Groupie-Event"
+	DeliveryHeader  = "X-Groupie-Delivery"
+	TimestampHeader = "X-Groupie-Timestamp"
+	SignatureHeader = "X-Groupie-Signature"
+)
+
+var (
+	ErrInvalidUrl     = errors.New("the webhook url must be an absolute http or https url")
+	ErrBlockedAddress = errors.New("webhooks can not be sent to local or private addresses")
+)
+
+// Hook is a registered url with the artists and countries it follows.
+type Hook struct {
+	Id        string    `json:"id"`
+	Owner     string    `json:"owner"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Artists   []int     `json:"artists,omitempty"`
+	Countries []string  `json:"countries,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+// Follows tells whether the hook wants to hear about a concert of the
+// artist in the country, a country slug such as "new_zealand".
+func (h Hook) Follows(artistId int, country string) bool {
+	return slices.Contains(h.Artists, artistId) || slices.Contains(h.Countries, country)
+}
+
+// Attempt is one try to deliver a payload. Status is zero when no answer
+// came back, Error then says why.
+type Attempt struct {
+	Time     time.Time     `json:"time"`
+	Status   int           `json:"status,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Delivery is a payload sent to a hook with all its attempts.
+type Delivery struct {
+	Id        string    `json:"id"`
+	HookId    string    `json:"hook_id"`
+	Event     string    `json:"event"`
+	Created   time.Time `json:"created"`
+	Attempts  []Attempt `json:"attempts"`
+	Delivered bool      `json:"delivered"`
+}
+
+// Last returns the last attempt of the delivery.
+func (d Delivery) Last() Attempt {
+	if len(d.Attempts) == 0 {
+		return Attempt{}
+	}
+	return d.Attempts[len(d.Attempts)-1]
+}
+
+func randomHex(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// NewId returns a random hook or delivery id.
+func NewId() string {
+	return randomHex(12)
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() string {
+	return randomHex(32)
+}
+
+// ValidateUrl checks that a url can be registered.
+func ValidateUrl(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.User != nil {
+		return ErrInvalidUrl
+	}
+	return nil
+}
+
+// Sign returns the signature of a payload sent at timestamp, in the form
+// of the signature header: "sha256=" and the hex encoded HMAC.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells whether a received request carries a valid signature of
+// body made with secret less than tolerance before now.
+func Verify(secret string, r *http.Request, body []byte, now time.Time, tolerance time.Duration) bool {
+	timestamp := r.Header.Get(TimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(r.Header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body)))
+}
+
+// SafeClient returns a client that refuses to connect to loopback, private
+// and link local addresses, whatever the url resolves to, and does not
+// follow redirects.
+func SafeClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Sender delivers payloads. A delivery is tried up to Attempts times while
+// the receiver can not be reached, answers 429 or a server error; the wait
+// between tries starts at Backoff and doubles.
+type Sender struct {
+	Client   *http.Client
+	Attempts int
+	Backoff  time.Duration
+	Sleep    func(time.Duration)
+	Now      func() time.Time
+}
+
+// NewSender returns a sender that only reaches public addresses and tries
+// five times over about half a minute.
+func NewSender() *Sender {
+	return &Sender{
+		Client:   SafeClient(10 * time.Second),
+		Attempts: 5,
+		Backoff:  2 * time.Second,
+		Sleep:    time.Sleep,
+		Now:      time.Now,
+	}
+}
+
+// Send delivers the payload of the event to the hook and returns the
+// delivery with its attempts.
+func (s *Sender) Send(hook Hook, event string, body []byte) Delivery {
+	delivery := Delivery{Id: NewId(), HookId: hook.Id, Event: event, Created: s.Now()}
+	wait := s.Backoff
+	for try := 1; try <= s.Attempts; try++ {
+		attempt := s.try(hook, delivery, body)
+		delivery.Attempts = append(delivery.Attempts, attempt)
+		if attempt.Status >= 200 && attempt.Status < 300 {
+			delivery.Delivered = true
+			break
+		}
+		retry := attempt.Status == 0 || attempt.Status == http.StatusTooManyRequests || attempt.Status >= 500
+		if !retry || try == s.Attempts {
+			break
+		}
+		s.Sleep(wait)
+		wait *= 2
+	}
+	return delivery
+}
+
+func (s *Sender) try(hook Hook, delivery Delivery, body []byte) Attempt {
+	start := s.Now()
+	attempt := Attempt{Time: start}
+	req, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "groupie-tracker-webhooks")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.Id)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+
+	response, err := s.Client.Do(req)
+	attempt.Duration = s.Now().Sub(start)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	attempt.Status = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		attempt.Error = fmt.Sprintf("unexpected status %s", response.Status)
+	}
+	return attempt
+}
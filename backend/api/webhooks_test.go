@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mymain/backend/api/fakeapi"
+	"mymain/backend/api/webhooks"
+)
+
+// useWebhooks empties the webhook store and sends without pauses to local
+// receivers. Deliveries still running are waited for before the test ends.
+func useWebhooks(t *testing.T) {
+	t.Helper()
+	savedStore, savedSender := webhookStore, webhookSender
+	webhookStore = memoryDataStore()
+	webhookSender = &webhooks.Sender{
+		Client:   &http.Client{Timeout: 5 * time.Second},
+		Attempts: 3,
+		Sleep:    func(time.Duration) {},
+		Now:      time.Now,
+	}
+	t.Cleanup(func() {
+		webhookQueue.Wait()
+		webhookStore, webhookSender = savedStore, savedSender
+	})
+}
+
+// hookReceiver is a local server receiving webhooks. It answers with the
+// given statuses in turn, the last one for all further requests, and keeps
+// the payloads that carried a valid signature of secret.
+type hookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	secret   string
+	statuses []int
+	calls    int
+	payloads []webhookPayload
+}
+
+func newHookReceiver(t *testing.T, statuses ...int) *hookReceiver {
+	receiver := &hookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.calls++
+		status := receiver.statuses[0]
+		if len(receiver.statuses) > 1 {
+			receiver.statuses = receiver.statuses[1:]
+		}
+		var payload webhookPayload
+		if webhooks.Verify(receiver.secret, r, body, time.Now(), time.Minute) && json.Unmarshal(body, &payload) == nil && status < 300 {
+			receiver.payloads = append(receiver.payloads, payload)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+// follow registers a hook of owner for the receiver.
+func (rc *hookReceiver) follow(t *testing.T, owner string, artists []int, countries []string) webhooks.Hook {
+	t.Helper()
+	hook := webhooks.Hook{Url: rc.URL + "/hook", Artists: artists, Countries: countries}
+	if err := addHook(owner, &hook); err != nil {
+		t.Fatal(err)
+	}
+	rc.mu.Lock()
+	rc.secret = hook.Secret
+	rc.mu.Unlock()
+	return hook
+}
+
+func TestSyncNotifiesWebhooks(t *testing.T) {
+	upstream := newFakeUpstream(t)
+	useWebhooks(t)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	queenFan := newHookReceiver(t, http.StatusOK)
+	queenFan.follow(t, "a", []int{1}, nil)
+	germany := newHookReceiver(t, http.StatusBadGateway, http.StatusNoContent)
+	germanyHook := germany.follow(t, "b", nil, []string{"germany"})
+	soja := newHookReceiver(t, http.StatusOK)
+	soja.follow(t, "c", []int{2}, nil)
+
+	// Queen announces Tokyo and Munich, nothing changes for SOJA
+	artists := fakeapi.DefaultArtists()
+	artists[0].Concerts["tokyo-japan"] = []string{"01-03-2020"}
+	artists[0].Concerts["munich-germany"] = []string{"05-03-2020"}
+	upstream.SetArtists(artists)
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	webhookQueue.Wait()
+
+	if len(queenFan.payloads) != 1 || len(queenFan.payloads[0].Concerts) != 2 || queenFan.payloads[0].Event != "concerts.added" {
+		t.Fatalf("Queen hook received %+v", queenFan.payloads)
+	}
+	if len(germany.payloads) != 1 || germany.calls != 2 {
+		t.Fatalf("Germany hook received %+v in %d calls", germany.payloads, germany.calls)
+	}
+	munich := germany.payloads[0].Concerts
+	expected := webhookConcert{ChangeId: munich[0].ChangeId, ArtistId: 1, ArtistName: "Queen", Location: "munich-germany", Country: "germany", Place: "Munich, Germany", Date: "05-03-2020"}
+	if len(munich) != 1 || munich[0] != expected || munich[0].ChangeId == 0 {
+		t.Errorf("Germany hook received %+v", munich)
+	}
+	if soja.calls != 0 {
+		t.Errorf("SOJA hook was called %d times", soja.calls)
+	}
+
+	// The retried delivery is logged with both attempts
+	deliveries, err := recentDeliveries(germanyHook.Id, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Germany hook has %d deliveries, %v", len(deliveries), err)
+	}
+	if delivery := deliveries[0]; !delivery.Delivered || len(delivery.Attempts) != 2 || delivery.Attempts[0].Status != http.StatusBadGateway {
+		t.Errorf("Germany delivery is %+v", delivery)
+	}
+
+	// Another sync without new concerts sends nothing
+	if err := refreshSnapshot(); err != nil {
+		t.Fatal(err)
+	}
+	webhookQueue.Wait()
+	if queenFan.calls != 1 {
+		t.Errorf("Queen hook was called %d times", queenFan.calls)
+	}
+}
+
+func TestFailedDeliveryIsLogged(t *testing.T) {
+	useWebhooks(t)
+	receiver := newHookReceiver(t, http.StatusServiceUnavailable)
+	hook := receiver.follow(t, "a", []int{1}, nil)
+
+	deliver(hook, webhookPayload{Event: "ping", Hook: hook.Id})
+	webhookQueue.Wait()
+	deliveries, _ := recentDeliveries(hook.Id, 10)
+	if receiver.calls != 3 || len(deliveries) != 1 || deliveries[0].Delivered || deliveries[0].Last().Status != http.StatusServiceUnavailable {
+		t.Errorf("failing receiver got %d calls, logged %+v", receiver.calls, deliveries)
+	}
+}
+
+func TestDeliveryQueueIsCapped(t *testing.T) {
+	useWebhooks(t)
+	receiver := newHookReceiver(t, http.StatusOK)
+	hook := receiver.follow(t, "a", []int{1}, nil)
+
+	webhookPool.waiting.Add(maxQueuedDeliveries)
+	queued := deliver(hook, webhookPayload{Event: "ping", Hook: hook.Id})
+	webhookPool.waiting.Add(-maxQueuedDeliveries)
+	if queued {
+		t.Error("a delivery was queued behind a full queue")
+	}
+	if !deliver(hook, webhookPayload{Event: "ping", Hook: hook.Id}) {
+		t.Error("a delivery was dropped with an empty queue")
+	}
+	webhookQueue.Wait()
+	if receiver.calls != 1 {
+		t.Errorf("receiver got %d calls want 1", receiver.calls)
+	}
+}
+
+func TestHandleWebhooks(t *testing.T) {
+	newFakeUpstream(t)
+	useAccounts(t)
+	useWebhooks(t)
+
+	if rr := serve(handleWebhooks, http.MethodGet, "/webhooks"); rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login?return=/webhooks" {
+		t.Errorf("anonymous GET returned %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := postForm(handleWebhooks, "/webhooks", nil, url.Values{"action": {"add"}}); rr.Code != http.StatusForbidden {
+		t.Errorf("anonymous POST returned %d", rr.Code)
+	}
+
+	cookies := registerUser(t, "integrator")
+	receiver := newHookReceiver(t, http.StatusOK)
+	add := url.Values{"action": {"add"}, "url": {receiver.URL}, "artists": {"1", "3"}, "countries": {"japan"}}
+	if rr := postForm(handleWebhooks, "/webhooks", cookies, add); rr.Code != http.StatusSeeOther {
+		t.Fatalf("adding a webhook returned %d: %s", rr.Code, rr.Body.String())
+	}
+	hooks, _ := listHooks("integrator")
+	if len(hooks) != 1 || hooks[0].Url != receiver.URL || len(hooks[0].Artists) != 2 || hooks[0].Countries[0] != "japan" || len(hooks[0].Secret) != 64 {
+		t.Fatalf("stored webhooks are %+v", hooks)
+	}
+	hook := hooks[0]
+
+	body := serveWithCookies(handleWebhooks, "/webhooks", cookies).Body.String()
+	for _, expected := range []string{`id="hook_` + hook.Id + `"`, hook.Secret, ">Queen</span>", ">Pink Floyd</span>", ">Japan</span>", "Nothing sent yet."} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("webhooks page does not contain %q", expected)
+		}
+	}
+
+	// A ping is delivered and shows up in the log
+	receiver.secret = hook.Secret
+	if rr := postForm(handleWebhooks, "/webhooks", cookies, url.Values{"action": {"ping"}, "id": {hook.Id}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("ping returned %d", rr.Code)
+	}
+	webhookQueue.Wait()
+	if len(receiver.payloads) != 1 || receiver.payloads[0].Event != "ping" || receiver.payloads[0].Hook != hook.Id {
+		t.Errorf("receiver got %+v", receiver.payloads)
+	}
+	if body := serveWithCookies(handleWebhooks, "/webhooks", cookies).Body.String(); !strings.Contains(body, "delivered (200)") {
+		t.Error("webhooks page does not show the delivered ping")
+	}
+	// Pings of a hook are a minute apart
+	if rr := postForm(handleWebhooks, "/webhooks", cookies, url.Values{"action": {"ping"}, "id": {hook.Id}}); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("second ping returned %d", rr.Code)
+	}
+	webhookQueue.Wait()
+	if len(receiver.payloads) != 1 {
+		t.Errorf("receiver got %d pings", len(receiver.payloads))
+	}
+
+	testCases := []struct {
+		name         string
+		form         url.Values
+		expectedCode int
+		expectedText string
+	}{
+		{"bad url", url.Values{"action": {"add"}, "url": {"ftp://example.com"}, "artists": {"1"}}, http.StatusBadRequest, "absolute http or https url"},
+		{"nothing followed", url.Values{"action": {"add"}, "url": {"https://example.com"}}, http.StatusBadRequest, "follow at least one"},
+		{"unknown artist", url.Values{"action": {"add"}, "url": {"https://example.com"}, "artists": {"99"}}, http.StatusBadRequest, "unknown artist 99"},
+		{"unknown country", url.Values{"action": {"add"}, "url": {"https://example.com"}, "countries": {"atlantis"}}, http.StatusBadRequest, "unknown country atlantis"},
+		{"unknown action", url.Values{"action": {"edit"}}, http.StatusBadRequest, ""},
+		{"forged token", url.Values{"action": {"delete"}, "id": {hook.Id}, "csrf_token": {"forged"}}, http.StatusForbidden, ""},
+		{"unknown hook", url.Values{"action": {"delete"}, "id": {"nope"}}, http.StatusNotFound, ""},
+	}
+	for _, tc := range testCases {
+		rr := postForm(handleWebhooks, "/webhooks", cookies, tc.form)
+		if rr.Code != tc.expectedCode || !strings.Contains(rr.Body.String(), tc.expectedText) {
+			t.Errorf("%s returned %d", tc.name, rr.Code)
+		}
+	}
+
+	// Other accounts can not see or remove the hook
+	other := registerUser(t, "someone_else")
+	if body := serveWithCookies(handleWebhooks, "/webhooks", other).Body.String(); strings.Contains(body, hook.Id) {
+		t.Error("another account sees the webhook")
+	}
+	if rr := postForm(handleWebhooks, "/webhooks", other, url.Values{"action": {"delete"}, "id": {hook.Id}}); rr.Code != http.StatusNotFound {
+		t.Errorf("deleting the webhook of another account returned %d", rr.Code)
+	}
+
+	if rr := postForm(handleWebhooks, "/webhooks", cookies, url.Values{"action": {"delete"}, "id": {hook.Id}}); rr.Code != http.StatusSeeOther {
+		t.Errorf("deleting returned %d", rr.Code)
+	}
+	if hooks, _ := listHooks("integrator"); len(hooks) != 0 {
+		t.Errorf("webhooks left after deleting: %+v", hooks)
+	}
+}
+
+func TestWebhookLimit(t *testing.T) {
+	useWebhooks(t)
+	for i := 0; i < maxHooksPerOwner; i++ {
+		if err := addHook("a", &webhooks.Hook{Url: "https://example.com", Artists: []int{1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := addHook("a", &webhooks.Hook{Url: "https://example.com", Artists: []int{1}}); err == nil {
+		t.Error("an account got more than the maximum of webhooks")
+	}
+	if err := addHook("b", &webhooks.Hook{Url: "https://example.com", Artists: []int{1}}); err != nil {
+		t.Errorf("the limit of one account stopped another: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sheet is what the tests read back of a written sheet.
+type sheet struct {
+	Rows []struct {
+		Ref   string `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readPart(t *testing.T, archive *zip.Reader, name string) []byte {
+	t.Helper()
+	file, err := archive.Open(name)
+	if err != nil {
+		t.Fatalf("opening %s: %v", name, err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewWriter(&buffer, "Tours: 2020/21")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]any{
+		{"name", "members", "count"},
+		{`Guns "N" Roses & <friends>`, "Axl Rose; Slash", 5},
+		{" Motörhead\n", "Lemmy\x01", 2.5},
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		var any struct{}
+		if err := xml.Unmarshal(readPart(t, archive, name), &any); err != nil {
+			t.Errorf("%s is not XML: %v", name, err)
+		}
+	}
+	if workbook := string(readPart(t, archive, "xl/workbook.xml")); !strings.Contains(workbook, `name="Tours_ 2020_21"`) {
+		t.Errorf("the sheet name is not cleaned: %s", workbook)
+	}
+
+	var got sheet
+	if err := xml.Unmarshal(readPart(t, archive, "xl/worksheets/sheet1.xml"), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rows) != 3 || got.Rows[2].Ref != "3" {
+		t.Fatalf("the sheet has rows %+v", got.Rows)
+	}
+	header := got.Rows[0].Cells[2]
+	if header.Ref != "C1" || header.Style != "1" || header.Inline != "count" {
+		t.Errorf("the header cell is %+v", header)
+	}
+	name := got.Rows[1].Cells[0]
+	if name.Type != "inlineStr" || name.Inline != `Guns "N" Roses & <friends>` {
+		t.Errorf("the name cell is %+v", name)
+	}
+	if count := got.Rows[1].Cells[2]; count.Type != "" || count.Value != "5" || count.Style != "" {
+		t.Errorf("the count cell is %+v", count)
+	}
+	if cells := got.Rows[2].Cells; cells[0].Inline != " Motörhead\n" || cells[1].Inline != "Lemmy�" || cells[2].Value != "2.5" {
+		t.Errorf("the last row is %+v", cells)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	var got []string
+	for _, index := range []int{0, 1, 25, 26, 27, 51, 52, 701, 702} {
+		got = append(got, Column(index))
+	}
+	if want := []string{"A", "B", "Z", "AA", "AB", "AZ", "BA", "ZZ", "AAA"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("columns are %v, want %v", got, want)
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	for name, want := range map[string]string{
+		"artists":                               "artists",
+		"":                                      "Sheet1",
+		"a very long name for a sheet of tours": "a very long name for a sheet of",
+	} {
+		if got := sheetName(name); got != want {
+			t.Errorf("sheetName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
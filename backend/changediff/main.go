@@ -0,0 +1,146 @@
+// Command changediff lists what changed between two copies of the upstream
+// data, the same way the main server fills its changelog.
+//
+// A copy is either a dump written by this command or a data store file of
+// the main server (data/upstream.json). Run it from the root of the project:
+//
+//	go run ./backend/changediff -dump https://groupietrackers.herokuapp.com/api > today.json
+//	go run ./backend/changediff yesterday.json today.json
+//
+// It prints one change per line and exits with status 1 when the copies
+// differ, like diff.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"mymain/backend/api/changes"
+)
+
+func main() {
+	dumpBase := flag.String("dump", "", "write a dump of the upstream api at this base address to stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: changediff old.json new.json")
+		fmt.Fprintln(flag.CommandLine.Output(), "       changediff -dump base-url > dump.json")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *dumpBase != "" {
+		dump, err := fetchDump(strings.TrimSuffix(*dumpBase, "/"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(dump); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	old, err := readDump(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	current, err := readDump(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	list := changes.Diff(old, current)
+	for _, change := range list {
+		fmt.Printf("%-22s %s\n", change.Kind, change)
+	}
+	if len(list) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readDump reads a dump, or the artists and relations buckets of a data
+// store file.
+func readDump(path string) (changes.Dump, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return changes.Dump{}, err
+	}
+	var file struct {
+		Artists   json.RawMessage `json:"artists"`
+		Relations json.RawMessage `json:"relations"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return changes.Dump{}, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var dump changes.Dump
+	if strings.HasPrefix(strings.TrimSpace(string(file.Artists)), "[") {
+		err = json.Unmarshal(content, &dump)
+	} else {
+		err = readStoreFile(file.Artists, file.Relations, &dump)
+	}
+	if err != nil {
+		return changes.Dump{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return dump, nil
+}
+
+// readStoreFile reads the buckets of a data store file, records keyed by
+// artist id.
+func readStoreFile(artistsBucket json.RawMessage, relationsBucket json.RawMessage, dump *changes.Dump) error {
+	var artists map[string]changes.Artist
+	var relations map[string]changes.Relation
+	if err := json.Unmarshal(artistsBucket, &artists); err != nil {
+		return err
+	}
+	if len(relationsBucket) > 0 {
+		if err := json.Unmarshal(relationsBucket, &relations); err != nil {
+			return err
+		}
+	}
+	for _, artist := range artists {
+		dump.Artists = append(dump.Artists, artist)
+	}
+	for _, relation := range relations {
+		dump.Relations.Index = append(dump.Relations.Index, relation)
+	}
+	sort.Slice(dump.Artists, func(i, j int) bool { return dump.Artists[i].Id < dump.Artists[j].Id })
+	sort.Slice(dump.Relations.Index, func(i, j int) bool { return dump.Relations.Index[i].Id < dump.Relations.Index[j].Id })
+	return nil
+}
+
+// fetchDump reads the artists and relations from the upstream api.
+func fetchDump(base string) (changes.Dump, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var dump changes.Dump
+	if err := getJson(client, base+"/artists", &dump.Artists); err != nil {
+		return changes.Dump{}, err
+	}
+	if err := getJson(client, base+"/relation", &dump.Relations); err != nil {
+		return changes.Dump{}, err
+	}
+	return dump, nil
+}
+
+func getJson(client *http.Client, url string, value interface{}) error {
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, response.Status)
+	}
+	return json.NewDecoder(response.Body).Decode(value)
+}
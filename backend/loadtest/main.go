@@ -0,0 +1,220 @@
+// Command loadtest compares the sequential server (backend/api) with the
+// worker-pool server (backend/api/go-routine).
+//
+// Each server is built, started against its own in-process fake upstream
+// with the configured latency and its own temporary data and cache
+// directories, and driven with the same request mix. The report shows
+// throughput, latency percentiles and how many upstream calls every page
+// costs. Run it from the root of the project:
+//
+//	go run ./backend/loadtest -latency 50ms -duration 10s -concurrency 20
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mymain/backend/api/fakeapi"
+)
+
+type server struct {
+	name    string
+	pkg     string
+	address string
+}
+
+type result struct {
+	server        string
+	requests      int
+	errors        int
+	elapsed       time.Duration
+	latencies     []time.Duration
+	upstreamCalls int
+}
+
+func main() {
+	latency := flag.Duration("latency", 20*time.Millisecond, "latency of every upstream response")
+	duration := flag.Duration("duration", 10*time.Second, "how long each server is driven")
+	concurrency := flag.Int("concurrency", 20, "number of concurrent clients")
+	paths := flag.String("paths", "/,/artists,/artist/1,/locations,/dates,/tours,/search?search_text=queen", "comma separated request mix")
+	only := flag.String("servers", "sequential,pool", "servers to test: sequential, pool or both")
+	basePort := flag.Int("port", 18080, "first local port used for the servers")
+	flag.Parse()
+
+	servers := []server{
+		{name: "sequential", pkg: "./backend/api", address: fmt.Sprintf("127.0.0.1:%d", *basePort)},
+		{name: "pool", pkg: "./backend/api/go-routine", address: fmt.Sprintf("127.0.0.1:%d", *basePort+1)},
+	}
+
+	binDir, err := os.MkdirTemp("", "groupie-loadtest")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(binDir)
+
+	var results []result
+	for _, srv := range servers {
+		if !strings.Contains(*only, srv.name) {
+			continue
+		}
+		res, err := runServer(srv, binDir, *latency, *duration, *concurrency, strings.Split(*paths, ","))
+		if err != nil {
+			log.Fatalf("%s: %v", srv.name, err)
+		}
+		results = append(results, res)
+	}
+
+	fmt.Printf("\nupstream latency %s, %d clients, %s per server\n\n", *latency, *concurrency, *duration)
+	printReport(os.Stdout, results)
+}
+
+func runServer(srv server, binDir string, latency, duration time.Duration, concurrency int, paths []string) (result, error) {
+	binary := filepath.Join(binDir, srv.name)
+	build := exec.Command("go", "build", "-o", binary, srv.pkg)
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		return result{}, fmt.Errorf("build: %w", err)
+	}
+
+	upstream := fakeapi.NewServer(fakeapi.DefaultArtists())
+	defer upstream.Close()
+	upstream.SetLatency(latency)
+
+	// The server keeps its data, such as the changelog and the webhooks, and
+	// its image cache away from the real ones
+	stateDir, err := os.MkdirTemp("", "groupie-loadtest-"+srv.name)
+	if err != nil {
+		return result{}, err
+	}
+	defer os.RemoveAll(stateDir)
+
+	cmd := exec.Command(binary)
+	cmd.Env = append(os.Environ(),
+		"GROUPIE_API_BASE="+upstream.URL(),
+		"GROUPIE_ADDR="+srv.address,
+		"GROUPIE_DATA_DIR="+filepath.Join(stateDir, "data"),
+		"GROUPIE_CACHE_DIR="+filepath.Join(stateDir, "cache"),
+	)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return result{}, fmt.Errorf("start: %w", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	baseUrl := "http://" + srv.address
+	if err := waitReady(baseUrl+"/readyz", 30*time.Second); err != nil {
+		return result{}, err
+	}
+	log.Printf("%s: ready, driving %s for %s", srv.name, baseUrl, duration)
+
+	// Only count the calls caused by the load itself
+	upstream.ResetCalls()
+	res := drive(baseUrl, paths, concurrency, duration)
+	res.server = srv.name
+	res.upstreamCalls = upstream.TotalCalls()
+	return res, nil
+}
+
+func waitReady(url string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		res, err := http.Get(url)
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("%s not ready after %s", url, timeout)
+}
+
+// drive sends requests from concurrency clients, cycling through paths,
+// until duration has passed.
+func drive(baseUrl string, paths []string, concurrency int, duration time.Duration) result {
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: concurrency},
+	}
+
+	var mu sync.Mutex
+	var res result
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(duration)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			var latencies []time.Duration
+			errors := 0
+			for n := offset; time.Now().Before(deadline); n++ {
+				begin := time.Now()
+				resp, err := client.Get(baseUrl + paths[n%len(paths)])
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				latencies = append(latencies, time.Since(begin))
+				if err != nil || resp.StatusCode != http.StatusOK {
+					errors++
+				}
+			}
+
+			mu.Lock()
+			res.latencies = append(res.latencies, latencies...)
+			res.errors += errors
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	res.elapsed = time.Since(start)
+	res.requests = len(res.latencies)
+	return res
+}
+
+// percentile returns the p-th percentile (0-100) of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(index, 0)]
+}
+
+func printReport(w io.Writer, results []result) {
+	fmt.Fprintf(w, "%-12s %9s %7s %10s %10s %10s %10s %10s %10s %9s\n",
+		"server", "requests", "errors", "req/s", "p50", "p90", "p99", "max", "upstream", "calls/req")
+	for _, res := range results {
+		sort.Slice(res.latencies, func(i, j int) bool { return res.latencies[i] < res.latencies[j] })
+		throughput, callsPerRequest := 0.0, 0.0
+		if res.requests > 0 {
+			throughput = float64(res.requests) / res.elapsed.Seconds()
+			callsPerRequest = float64(res.upstreamCalls) / float64(res.requests)
+		}
+		fmt.Fprintf(w, "%-12s %9d %7d %10.1f %10s %10s %10s %10s %10d %9.2f\n",
+			res.server, res.requests, res.errors, throughput,
+			percentile(res.latencies, 50).Round(time.Microsecond),
+			percentile(res.latencies, 90).Round(time.Microsecond),
+			percentile(res.latencies, 99).Round(time.Microsecond),
+			percentile(res.latencies, 100).Round(time.Microsecond),
+			res.upstreamCalls, callsPerRequest)
+	}
+}
@@ -48,6 +48,32 @@ $select.select2({
 
 $("#concerts_locations").val('').change();
 
+// Filters applied on the server are restored from the url, and the search,
+// sort order and page size are kept when the filter form is submitted
+const listingParams = new URLSearchParams(window.location.search);
+['creation_date_start', 'creation_date_end', 'first_album_date_start', 'first_album_date_end'].forEach(function(name) {
+  if (listingParams.has(name)) {
+    $('#' + name).val(listingParams.get(name));
+  }
+});
+if (listingParams.get('concerts_locations')) {
+  $select.val(listingParams.get('concerts_locations')).change();
+}
+if (listingParams.has('members[]')) {
+  const members = listingParams.getAll('members[]');
+  $('input[name="members[]"]').each(function() {
+    this.checked = members.includes(this.value);
+  });
+}
+['search_text', 'sort', 'order', 'size'].forEach(function(name) {
+  if (listingParams.has(name)) {
+    $('<input>', {type: 'hidden', name: name, value: listingParams.get(name)}).appendTo('#filter_form');
+  }
+});
+if (listingParams.toString() !== '') {
+  filter_result();
+}
+
 function filter_result() {
 
   creation_date_end_value.textContent = creation_date_end.value;
@@ -61,6 +87,10 @@ function filter_result() {
   const selectedMemberCounts = Array.from(checkboxes)
     .filter(checkbox => checkbox.checked)
     .map(checkbox => parseInt(checkbox.value, 10));
+  // Genre and origin come from the artist metadata, absent without one
+  const selectedGenre = $('#genre').val() || '';
+  const selectedOrigin = $('#origin').val() || '';
+  const selectedTag = $('#tag').val() || '';
 
   $.each(JSON.parse(allArtists), function( index, value ) {
     const dateString = value.firstAlbum
@@ -78,11 +108,17 @@ function filter_result() {
         }
       }) 
     }
+    const metadata = value.metadata || {};
+    const showArtistForGenre = selectedGenre === ''
+      || (metadata.genres || []).some(genre => genre.trim().toLowerCase() === selectedGenre);
+    const showArtistForOrigin = selectedOrigin === '' || metadata.origin === selectedOrigin;
+    const showArtistForTag = selectedTag === '' || (value.tags || []).some(tag => tag.slug === selectedTag);
     
     if(value.creationDate >= creation_date_start.value && value.creationDate <= creation_date_end.value
       && year >= first_album_date_start.value && year <= first_album_date_end.value
       && showArtistForLocationFilter
       && selectedMemberCounts.includes(membersCount)
+      && showArtistForGenre && showArtistForOrigin && showArtistForTag
     ) {
       $('#artist_' + value.id).show()
     } else {
@@ -94,6 +130,9 @@ function filter_result() {
 function resetForm(){
 
   $("#concerts_locations").val('').change();
+  $('#genre').val('');
+  $('#origin').val('');
+  $('#tag').val('');
 
   $('input[name="members[]"]').prop('checked', true);
 
@@ -103,4 +142,15 @@ function resetForm(){
   $('#first_album_date_end').val(2020).change();
 
   $('[id^="artist"]').show();
-}
\ No newline at end of file
+}
+// Artists selected for /compare: at most 4, the button needs at least 2
+const maxCompare = 4;
+
+function update_compare() {
+  const checked = $('input.compare-checkbox:checked').length;
+  $('input.compare-checkbox:not(:checked)').prop('disabled', checked >= maxCompare);
+  $('#compare_button').prop('disabled', checked < 2).text('Compare selected (' + checked + ')');
+}
+
+$('input.compare-checkbox').on('change', update_compare);
+update_compare();
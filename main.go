@@ -15,15 +15,15 @@ func main() {
 	}
 	fmt.Println("Current working directory:", cwd)
 
-	// Start the backend service (running main.go in backend/api)
+	// Start the backend service (running the backend/api package)
 	fmt.Println("Starting backend...")
-	cmdBackend := exec.Command("go", "run", "backend/api/main.go")
+	cmdBackend := exec.Command("go", "run", "./backend/api")
 	cmdBackend.Stdout = os.Stdout
 	cmdBackend.Stderr = os.Stderr
 
-	// Start the second backend service (running go-routine/main.go)
+	// Start the second backend service (running the go-routine package)
 	fmt.Println("Starting backend with go-routine...")
-	cmdBackendRoutine := exec.Command("go", "run", "backend/api/go-routine/main.go")
+	cmdBackendRoutine := exec.Command("go", "run", "./backend/api/go-routine")
 	cmdBackendRoutine.Stdout = os.Stdout
 	cmdBackendRoutine.Stderr = os.Stderr
 